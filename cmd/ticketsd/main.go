@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,22 +11,73 @@ import (
 	"syscall"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
+	_ "github.com/lib/pq"
+	"github.com/mroobert/tixer-tickets"
 	"github.com/mroobert/tixer-tickets/gcfirestore"
 	"github.com/mroobert/tixer-tickets/http"
+	"github.com/mroobert/tixer-tickets/inmem"
+	"github.com/mroobert/tixer-tickets/payment"
+	"github.com/mroobert/tixer-tickets/postgres"
+	"github.com/mroobert/tixer-tickets/search"
+	"github.com/mroobert/tixer-tickets/sqlite"
 	"golang.org/x/exp/slog"
 )
 
 var (
 	ErrFirebaseProjectIdNotProvided = errors.New("firebase-project-id not provided")
+	ErrCursorSecretNotProvided      = errors.New("cursor-secret not provided")
 	ErrInitFirebaseApp              = errors.New("could not initialize firebase app")
 	ErrInitFireStoreClient          = errors.New("could not initialize firestore client")
+	ErrMigrateCounter               = errors.New("could not migrate tickets counter")
+	ErrPostgresDSNNotProvided       = errors.New("postgres-dsn not provided")
+	ErrInitPostgres                 = errors.New("could not initialize postgres")
+	ErrInitSQLite                   = errors.New("could not initialize sqlite")
+	ErrUnknownStore                 = errors.New("unknown store")
+	ErrUnknownPaymentProvider       = errors.New("unknown payment provider")
+	ErrWebhookSecretNotProvided     = errors.New("payment-webhook-secret not provided")
 )
 
 func main() {
 	ctx := context.Background()
 
-	app, err := BuildApplication(ctx)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "recount":
+			if err := Recount(ctx, os.Args[2:]); err != nil {
+				fmt.Println("error recounting tickets: ", err)
+				os.Exit(1)
+			}
+			return
+		case "reindex":
+			if err := Reindex(ctx, os.Args[2:]); err != nil {
+				fmt.Println("error reindexing tickets: ", err)
+				os.Exit(1)
+			}
+			return
+		case "migrate-prices":
+			if err := MigratePrices(ctx, os.Args[2:]); err != nil {
+				fmt.Println("error migrating ticket prices: ", err)
+				os.Exit(1)
+			}
+			return
+		case "purge":
+			if err := Purge(ctx, os.Args[2:]); err != nil {
+				fmt.Println("error purging deleted tickets: ", err)
+				os.Exit(1)
+			}
+			return
+		case "migrate-statuses":
+			if err := MigrateStatuses(ctx, os.Args[2:]); err != nil {
+				fmt.Println("error migrating ticket statuses: ", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	app, err := BuildApplication(ctx, os.Args[1:])
 	if err != nil {
 		fmt.Println("error building application: ", err)
 		os.Exit(1)
@@ -64,22 +116,51 @@ func main() {
 // We will read in these configuration settings from command-line
 // flags when the application starts.
 type Config struct {
-	Env string
-	Web struct {
+	Env   string
+	Store string
+	Web   struct {
 		IdleTimeout     time.Duration
 		WriteTimeout    time.Duration
 		ReadTimeout     time.Duration
 		ShutdownTimeout time.Duration
+		IdempotencyTTL  time.Duration
+		CursorSecret    string
 		APIHost         string
 		DebugHost       string
 	}
 	Firebase struct {
 		ProjectID string
 		Firestore struct {
-			CollectionName string
-			CounterDocID   string
+			CollectionName            string
+			StatsCollectionName       string
+			CounterDocID              string
+			CounterShards             int
+			RecountInterval           time.Duration
+			IdempotencyCollectionName string
+			OrdersCollectionName      string
 		}
 	}
+	Postgres struct {
+		DSN          string
+		MaxOpenConns int
+	}
+	SQLite struct {
+		Path string
+	}
+	Search struct {
+		IndexPath       string
+		RefreshInterval time.Duration
+	}
+	Holds struct {
+		SweepInterval time.Duration
+	}
+	Orders struct {
+		TTL time.Duration
+	}
+	Payments struct {
+		Provider      string
+		WebhookSecret string
+	}
 }
 
 // Application holds the dependencies for this app.
@@ -87,59 +168,72 @@ type Application struct {
 	Config     Config
 	Logger     *slog.Logger
 	HTTPServer *http.Server
+
+	// Recounter is nil when the selected store has no counter to repair.
+	Recounter Recounter
+
+	SearchIndex *search.Index
+
+	// Reservations is nil when the selected store cannot hold tickets.
+	Reservations tixer.ReservationService
+
+	// storedTickets is the ticket service of the store, which does not
+	// update the search index.
+	storedTickets tixer.TicketService
+
+	stopJobs context.CancelFunc
 }
 
 // BuildApplication creates a new configured Application.
-func BuildApplication(ctx context.Context) (*Application, error) {
+func BuildApplication(ctx context.Context, args []string) (*Application, error) {
 	var (
 		app Application
 		cfg Config
 	)
 	app = Application{}
 
-	flag.StringVar(&cfg.Env, "env", "local", "Environment (local|development|staging|production)")
-
-	// Web
-	flag.StringVar(&cfg.Web.APIHost, "api-host", "0.0.0.0:8080", "API Host")
-	flag.StringVar(&cfg.Web.DebugHost, "debug-host", "0.0.0.0:3000", "Debug Host")
-	flag.DurationVar(&cfg.Web.IdleTimeout, "idle-timeout", 120*time.Second, "Idle Timeout")
-	flag.DurationVar(&cfg.Web.WriteTimeout, "write-timeout", 10*time.Second, "Write Timeout")
-	flag.DurationVar(&cfg.Web.ReadTimeout, "read-timeout", 5*time.Second, "Read Timeout")
-	flag.DurationVar(&cfg.Web.ShutdownTimeout, "shutdown-timeout", 20*time.Second, "Shutdown Timeout")
-
-	// Firebase
-	flag.StringVar(&cfg.Firebase.ProjectID, "firebase-project-id", "", "Firebase project ID")
-	flag.StringVar(&cfg.Firebase.Firestore.CollectionName, "firestore-collection-name", "tickets", "Tickets collection name")
-	flag.StringVar(&cfg.Firebase.Firestore.CounterDocID, "firestore-stats-doc-ID", "--counter--", "Document ID which stores tickets counter")
-
-	flag.Parse()
+	fs := flag.NewFlagSet("ticketsd", flag.ExitOnError)
+	registerConfigFlags(fs, &cfg)
+	fs.Parse(args)
 	app.Config = cfg
 
-	// Init Store client.
-	if app.Config.Firebase.ProjectID == "" {
-		return nil, ErrFirebaseProjectIdNotProvided
+	// A random secret is only fit for a single local instance,
+	// as the cursors signed by an instance would be rejected by the others.
+	if cfg.Env != "local" && cfg.Web.CursorSecret == "" {
+		return nil, ErrCursorSecretNotProvided
 	}
 
-	fbTicketsApp, err := firebase.NewApp(ctx, &firebase.Config{
-		ProjectID: app.Config.Firebase.ProjectID,
-	})
+	// Init store.
+	services, err := app.buildServices(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("%q: %w", err.Error(), ErrInitFirebaseApp)
+		return nil, err
 	}
+	app.Recounter = services.Recounter
 
-	storeClient, err := fbTicketsApp.Firestore(ctx)
+	app.SetLogger()
+
+	// Init search index, kept in sync with the writes of the tickets.
+	app.SearchIndex, err = app.buildSearchIndex(ctx, services.Tickets)
 	if err != nil {
-		return nil, fmt.Errorf("%q: %w", err.Error(), ErrInitFireStoreClient)
+		return nil, err
+	}
+	app.storedTickets = services.Tickets
+	tickets := search.NewTicketService(services.Tickets, app.SearchIndex)
+	if services.Reservations != nil {
+		app.Reservations = search.NewReservationService(services.Reservations, app.SearchIndex)
+	}
+	var orders tixer.OrderService
+	if services.Orders != nil {
+		orders = search.NewOrderService(services.Orders, services.Tickets, app.SearchIndex)
 	}
 
-	storer := gcfirestore.NewStorer(
-		storeClient,
-		app.Config.Firebase.Firestore.CollectionName,
-		app.Config.Firebase.Firestore.CounterDocID,
-	)
+	// Init payment provider.
+	payments, err := app.buildPaymentProvider()
+	if err != nil {
+		return nil, err
+	}
 
 	// Instantiate HTTP Server.
-	app.SetLogger()
 	app.HTTPServer = http.NewServer(
 		http.WithAddr(app.Config.Web.APIHost),
 		http.WithIdleTimeout(app.Config.Web.IdleTimeout),
@@ -147,15 +241,232 @@ func BuildApplication(ctx context.Context) (*Application, error) {
 		http.WithReadTimeout(app.Config.Web.ReadTimeout),
 		http.WithWriteTimeout(app.Config.Web.WriteTimeout),
 		http.WithShutdownTimeout(app.Config.Web.ShutdownTimeout),
+		http.WithIdempotencyTTL(app.Config.Web.IdempotencyTTL),
+		http.WithCursorSecret([]byte(app.Config.Web.CursorSecret)),
+		http.WithOrderTTL(app.Config.Orders.TTL),
 	)
-	app.HTTPServer.TicketService = storer
+	app.HTTPServer.TicketService = tickets
+	app.HTTPServer.SearchService = app.SearchIndex
+	app.HTTPServer.IdempotencyService = services.Idempotency
+	app.HTTPServer.OrderService = orders
+	app.HTTPServer.PaymentProvider = payments
 	app.HTTPServer.AttachRoutesV1()
 
 	return &app, nil
 }
 
+// registerConfigFlags registers the flags of the configuration settings,
+// which are shared by the server and the subcommands.
+func registerConfigFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Env, "env", "local", "Environment (local|development|staging|production)")
+	fs.StringVar(&cfg.Store, "store", "firestore", "Ticket store (firestore|postgres|sqlite|memory)")
+
+	// Web
+	fs.StringVar(&cfg.Web.APIHost, "api-host", "0.0.0.0:8080", "API Host")
+	fs.StringVar(&cfg.Web.DebugHost, "debug-host", "0.0.0.0:3000", "Debug Host")
+	fs.DurationVar(&cfg.Web.IdleTimeout, "idle-timeout", 120*time.Second, "Idle Timeout")
+	fs.DurationVar(&cfg.Web.WriteTimeout, "write-timeout", 10*time.Second, "Write Timeout")
+	fs.DurationVar(&cfg.Web.ReadTimeout, "read-timeout", 5*time.Second, "Read Timeout")
+	fs.DurationVar(&cfg.Web.ShutdownTimeout, "shutdown-timeout", 20*time.Second, "Shutdown Timeout")
+	fs.DurationVar(&cfg.Web.IdempotencyTTL, "idempotency-ttl", 24*time.Hour, "How long idempotency keys are kept")
+	fs.StringVar(&cfg.Web.CursorSecret, "cursor-secret", "", "Secret signing the pagination cursors, shared by all instances (required outside of local, random when empty)")
+
+	// Firebase
+	fs.StringVar(&cfg.Firebase.ProjectID, "firebase-project-id", "", "Firebase project ID")
+	fs.StringVar(&cfg.Firebase.Firestore.CollectionName, "firestore-collection-name", "tickets", "Tickets collection name")
+	fs.StringVar(&cfg.Firebase.Firestore.StatsCollectionName, "firestore-stats-collection-name", "stats", "Stats collection name")
+	fs.StringVar(&cfg.Firebase.Firestore.CounterDocID, "firestore-stats-doc-ID", "--counter--", "Document ID which stores tickets counter")
+	fs.IntVar(&cfg.Firebase.Firestore.CounterShards, "firestore-counter-shards", gcfirestore.DefaultCounterShards, "Number of shards of the tickets counter")
+	fs.DurationVar(&cfg.Firebase.Firestore.RecountInterval, "firestore-recount-interval", 0, "How often the tickets counter is repaired in the background (0 disables it)")
+	fs.StringVar(&cfg.Firebase.Firestore.IdempotencyCollectionName, "firestore-idempotency-collection-name", "idempotency-keys", "Idempotency keys collection name")
+	fs.StringVar(&cfg.Firebase.Firestore.OrdersCollectionName, "firestore-orders-collection-name", "orders", "Orders collection name")
+
+	// Postgres
+	fs.StringVar(&cfg.Postgres.DSN, "postgres-dsn", "", "PostgreSQL data source name")
+	fs.IntVar(&cfg.Postgres.MaxOpenConns, "postgres-max-open-conns", 25, "PostgreSQL max open connections")
+
+	// SQLite
+	fs.StringVar(&cfg.SQLite.Path, "sqlite-path", "tickets.db", "SQLite database file path")
+
+	// Search
+	fs.StringVar(&cfg.Search.IndexPath, "search-index-path", "", "Search index file path (kept in memory and rebuilt on startup when empty)")
+	fs.DurationVar(&cfg.Search.RefreshInterval, "search-refresh-interval", 5*time.Minute, "How often the search index is rebuilt from the store in the background (0 disables it)")
+
+	// Holds
+	fs.DurationVar(&cfg.Holds.SweepInterval, "hold-sweep-interval", time.Minute, "How often the expired ticket holds are released in the background (0 disables it)")
+
+	// Orders
+	fs.DurationVar(&cfg.Orders.TTL, "order-ttl", tixer.DefaultOrderTTL, "How long a pending order holds its tickets")
+
+	// Payments
+	fs.StringVar(&cfg.Payments.Provider, "payment-provider", "", "Payment provider (fake, orders cannot be paid when empty)")
+	fs.StringVar(&cfg.Payments.WebhookSecret, "payment-webhook-secret", "", "Secret verifying the signature of the payment webhooks (required with a payment provider)")
+}
+
+// Services holds the services backed by the selected store.
+type Services struct {
+	Tickets     tixer.TicketService
+	Idempotency tixer.IdempotencyService
+
+	// Recounter is only set for Firestore, the other stores update
+	// their counter within the same SQL transaction as the tickets.
+	Recounter Recounter
+
+	// Reservations and Orders are only set for Firestore and memory.
+	Reservations tixer.ReservationService
+	Orders       tixer.OrderService
+}
+
+// buildServices creates the services implementations selected
+// through the "store" flag.
+//
+// Only Firestore can store idempotency keys, the other stores keep them in memory.
+// Only Firestore and memory can hold tickets, and so store orders.
+func (a *Application) buildServices(ctx context.Context) (Services, error) {
+	switch a.Config.Store {
+	case "firestore":
+		storeClient, err := a.newFirestoreClient(ctx)
+		if err != nil {
+			return Services{}, err
+		}
+
+		tickets := a.newFirestoreStorer(storeClient)
+
+		// The count of a counter kept in the tickets collection is moved into the stats collection.
+		if err := tickets.MigrateCounter(ctx); err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrMigrateCounter)
+		}
+
+		return Services{
+			Tickets: tickets,
+			Idempotency: gcfirestore.NewIdempotencyStorer(
+				storeClient,
+				a.Config.Firebase.Firestore.IdempotencyCollectionName,
+			),
+			Recounter:    tickets,
+			Reservations: tickets,
+			Orders: gcfirestore.NewOrderStorer(
+				storeClient,
+				a.Config.Firebase.Firestore.OrdersCollectionName,
+				tickets,
+			),
+		}, nil
+	case "postgres":
+		if a.Config.Postgres.DSN == "" {
+			return Services{}, ErrPostgresDSNNotProvided
+		}
+
+		db, err := sql.Open("postgres", a.Config.Postgres.DSN)
+		if err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrInitPostgres)
+		}
+		db.SetMaxOpenConns(a.Config.Postgres.MaxOpenConns)
+
+		if err := db.PingContext(ctx); err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrInitPostgres)
+		}
+		if err := postgres.Migrate(ctx, db); err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrInitPostgres)
+		}
+
+		return Services{
+			Tickets:     postgres.NewStorer(db),
+			Idempotency: inmem.NewIdempotencyStorer(),
+		}, nil
+	case "sqlite":
+		db, err := sqlite.Open(a.Config.SQLite.Path)
+		if err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrInitSQLite)
+		}
+
+		if err := sqlite.Migrate(ctx, db); err != nil {
+			return Services{}, fmt.Errorf("%q: %w", err.Error(), ErrInitSQLite)
+		}
+
+		return Services{
+			Tickets:     sqlite.NewStorer(db),
+			Idempotency: inmem.NewIdempotencyStorer(),
+		}, nil
+	case "memory":
+		tickets := inmem.NewStorer()
+
+		return Services{
+			Tickets:      tickets,
+			Idempotency:  inmem.NewIdempotencyStorer(),
+			Reservations: tickets,
+			Orders:       inmem.NewOrderStorer(tickets),
+		}, nil
+	default:
+		return Services{}, fmt.Errorf("%q: %w", a.Config.Store, ErrUnknownStore)
+	}
+}
+
+// newFirestoreClient creates a Firestore client for the configured Firebase project.
+func (a *Application) newFirestoreClient(ctx context.Context) (*firestore.Client, error) {
+	if a.Config.Firebase.ProjectID == "" {
+		return nil, ErrFirebaseProjectIdNotProvided
+	}
+
+	fbTicketsApp, err := firebase.NewApp(ctx, &firebase.Config{
+		ProjectID: a.Config.Firebase.ProjectID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", err.Error(), ErrInitFirebaseApp)
+	}
+
+	storeClient, err := fbTicketsApp.Firestore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", err.Error(), ErrInitFireStoreClient)
+	}
+
+	return storeClient, nil
+}
+
+func (a *Application) newFirestoreStorer(client *firestore.Client) *gcfirestore.Storer {
+	return gcfirestore.NewStorer(
+		client,
+		a.Config.Firebase.Firestore.CollectionName,
+		a.Config.Firebase.Firestore.StatsCollectionName,
+		a.Config.Firebase.Firestore.CounterDocID,
+		a.Config.Firebase.Firestore.CounterShards,
+	)
+}
+
+// buildPaymentProvider creates the payment provider selected through the
+// "payment-provider" flag. It returns nil when none is selected.
+//
+// Every provider has an adapter in the payment package.
+func (a *Application) buildPaymentProvider() (tixer.PaymentProvider, error) {
+	if a.Config.Payments.Provider == "" {
+		return nil, nil
+	}
+	if a.Config.Payments.WebhookSecret == "" {
+		return nil, ErrWebhookSecretNotProvided
+	}
+
+	switch a.Config.Payments.Provider {
+	case "fake":
+		return payment.NewFake([]byte(a.Config.Payments.WebhookSecret)), nil
+	default:
+		return nil, fmt.Errorf("%q: %w", a.Config.Payments.Provider, ErrUnknownPaymentProvider)
+	}
+}
+
 // Run performs the startup sequence.
 func (a *Application) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	a.stopJobs = cancel
+
+	if a.Recounter != nil && a.Config.Firebase.Firestore.RecountInterval > 0 {
+		go a.runRecountJob(ctx, a.Config.Firebase.Firestore.RecountInterval)
+	}
+	if a.Config.Search.RefreshInterval > 0 {
+		go a.runSearchRefreshJob(ctx, a.Config.Search.RefreshInterval)
+	}
+	if a.Reservations != nil && a.Config.Holds.SweepInterval > 0 {
+		go a.runHoldSweepJob(ctx, a.Config.Holds.SweepInterval)
+	}
+
 	a.Logger.Info("starting the server", "addr", a.HTTPServer.Addr, "env", a.Config.Env)
 	if err := a.HTTPServer.Open(); err != nil {
 		return err
@@ -166,6 +477,10 @@ func (a *Application) Run(ctx context.Context) error {
 
 // Shutdown performs the gracefull shutdown sequence.
 func (a *Application) Shutdown() error {
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
+
 	if a.HTTPServer != nil {
 		if err := a.HTTPServer.Shutdown(); err != nil {
 			a.HTTPServer.Close()
@@ -173,6 +488,13 @@ func (a *Application) Shutdown() error {
 		}
 	}
 
+	// The index is saved once no more tickets can be written.
+	if a.SearchIndex != nil && a.Config.Search.IndexPath != "" {
+		if err := a.SearchIndex.Save(a.Config.Search.IndexPath); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/mroobert/tixer-tickets/gcfirestore"
+)
+
+var ErrMigrationNotSupported = errors.New("migrations are only supported by the firestore store")
+
+// MigratePrices runs the "migrate-prices" subcommand: it converts the prices
+// stored by earlier versions as floating point numbers into exact amounts.
+func MigratePrices(ctx context.Context, args []string) error {
+	return runMigration(ctx, "migrate-prices", args, (*gcfirestore.Storer).MigratePrices)
+}
+
+// MigrateStatuses runs the "migrate-statuses" subcommand: it sets the status
+// of the tickets stored by earlier versions, which are on sale.
+func MigrateStatuses(ctx context.Context, args []string) error {
+	return runMigration(ctx, "migrate-statuses", args, (*gcfirestore.Storer).MigrateStatuses)
+}
+
+// runMigration runs the migration subcommand of the given name over the Firestore tickets.
+//
+// The SQL stores are migrated by their schema migrations, on startup.
+// It accepts the same flags as the server, and can be run again when
+// some batches conflicted with concurrent writes.
+func runMigration(ctx context.Context, name string, args []string, migrate func(*gcfirestore.Storer, context.Context) (gcfirestore.Migration, error)) error {
+	var (
+		app Application
+		cfg Config
+	)
+
+	fs := flag.NewFlagSet("ticketsd "+name, flag.ExitOnError)
+	registerConfigFlags(fs, &cfg)
+	fs.Parse(args)
+	app.Config = cfg
+	app.SetLogger()
+
+	if cfg.Store != "firestore" {
+		return fmt.Errorf("%q: %w", cfg.Store, ErrMigrationNotSupported)
+	}
+
+	client, err := app.newFirestoreClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	migration, err := migrate(app.newFirestoreStorer(client), ctx)
+	if err != nil {
+		return err
+	}
+
+	app.Logger.Info("tickets migrated",
+		"migration", name,
+		"converted", migration.Converted,
+		"skipped", migration.Skipped,
+		"conflicted", migration.Conflicted,
+		"failed_batches", migration.FailedBatches,
+	)
+	if migration.FailedBatches > 0 {
+		app.Logger.Warn(fmt.Sprintf("some batches conflicted with concurrent writes, run %s again to migrate them", name))
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrPurgeOlderThanNotPositive = errors.New("older-than must be positive")
+
+// Purge runs the "purge" subcommand: it removes for good the tickets deleted
+// for longer than "-older-than", such as "30d".
+//
+// It accepts the same flags as the server. The total tickets are left as is,
+// as the tickets were no longer counted once they were deleted.
+func Purge(ctx context.Context, args []string) error {
+	var (
+		app       Application
+		cfg       Config
+		olderThan = days(30 * 24 * time.Hour)
+	)
+
+	fs := flag.NewFlagSet("ticketsd purge", flag.ExitOnError)
+	registerConfigFlags(fs, &cfg)
+	fs.Var(&olderThan, "older-than", "How long the deleted tickets are kept before being purged, in days (30d) or as a duration (720h)")
+	fs.Parse(args)
+	app.Config = cfg
+	app.SetLogger()
+
+	if olderThan <= 0 {
+		return ErrPurgeOlderThanNotPositive
+	}
+
+	services, err := app.buildServices(ctx)
+	if err != nil {
+		return err
+	}
+
+	deletedBefore := time.Now().Add(-time.Duration(olderThan))
+	purged, err := services.Tickets.PurgeTickets(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	app.Logger.Info("deleted tickets purged", "deleted_before", deletedBefore, "purged", purged)
+
+	return nil
+}
+
+// days is a duration flag which also accepts a number of days, such as "30d".
+type days time.Duration
+
+func (d *days) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *days) Set(s string) error {
+	if strings.HasSuffix(s, "d") {
+		v, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return fmt.Errorf("invalid number of days %q", s)
+		}
+		*d = days(time.Duration(v) * 24 * time.Hour)
+
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = days(v)
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets/gcfirestore"
+)
+
+var ErrRecountNotSupported = errors.New("recount is only supported by the firestore store")
+
+// Recounter repairs the drift of a tickets counter.
+//
+// The lease elects the only instance of the service which repairs the counter.
+type Recounter interface {
+	Recount(ctx context.Context, apply bool) (gcfirestore.CounterDrift, error)
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+}
+
+// Recount runs the "recount" subcommand: it counts the stored tickets,
+// reports the drift of the tickets counter and fixes it when "-apply" is set.
+//
+// It accepts the same flags as the server.
+func Recount(ctx context.Context, args []string) error {
+	var (
+		app   Application
+		cfg   Config
+		apply bool
+	)
+
+	fs := flag.NewFlagSet("ticketsd recount", flag.ExitOnError)
+	registerConfigFlags(fs, &cfg)
+	fs.BoolVar(&apply, "apply", false, "Fix the tickets counter, otherwise only report its drift")
+	fs.Parse(args)
+	app.Config = cfg
+	app.SetLogger()
+
+	if cfg.Store != "firestore" {
+		return fmt.Errorf("%q: %w", cfg.Store, ErrRecountNotSupported)
+	}
+
+	client, err := app.newFirestoreClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	tickets := app.newFirestoreStorer(client)
+	if err := tickets.MigrateCounter(ctx); err != nil {
+		return fmt.Errorf("%q: %w", err.Error(), ErrMigrateCounter)
+	}
+
+	drift, err := tickets.Recount(ctx, apply)
+	if err != nil {
+		return err
+	}
+
+	app.Logger.Info("tickets recounted",
+		"counted", drift.Counted,
+		"counter", drift.Total,
+		"drift", drift.Diff(),
+		"skipped", drift.Skipped,
+		"applied", apply && drift.Diff() != 0,
+	)
+
+	return nil
+}
+
+// runRecountJob repairs the tickets counter every interval, until ctx is done.
+//
+// Only the instance holding the recount lease repairs the counter. It renews the
+// lease on every run, and another instance takes it over when it is not renewed
+// for two intervals, such as when the holding instance is stopped.
+func (a *Application) runRecountJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	holder := uuid.NewString()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leased, err := a.Recounter.AcquireLease(ctx, "recount", holder, 2*interval)
+		if err != nil {
+			a.Logger.Error("recount lease error", err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+
+		drift, err := a.Recounter.Recount(ctx, true)
+		if err != nil {
+			a.Logger.Error("recount error", err)
+			continue
+		}
+		if drift.Diff() != 0 {
+			a.Logger.Warn("tickets counter repaired",
+				"counted", drift.Counted,
+				"counter", drift.Total,
+				"drift", drift.Diff(),
+			)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runHoldSweepJob removes the expired holds every interval, until ctx is done.
+//
+// Every instance sweeps the holds, as releasing an expired hold twice does nothing:
+// the instances which find the same expired hold release it once between them.
+func (a *Application) runHoldSweepJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		released, err := a.Reservations.ReleaseExpired(ctx, time.Now())
+		if err != nil {
+			a.Logger.Error("hold sweep error", err)
+			continue
+		}
+		if released > 0 {
+			a.Logger.Debug("expired holds released", "holds", released)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/search"
+)
+
+var (
+	ErrSearchIndexPathNotProvided = errors.New("search-index-path not provided")
+	ErrLoadSearchIndex            = errors.New("could not load search index")
+	ErrRebuildSearchIndex         = errors.New("could not rebuild search index")
+)
+
+// buildSearchIndex loads the search index from its file. The index is rebuilt
+// from the tickets when it is only kept in memory, when its file does not exist yet,
+// or when its file was saved in the format of another version of the service.
+func (a *Application) buildSearchIndex(ctx context.Context, tickets tixer.TicketService) (*search.Index, error) {
+	index := search.NewIndex()
+
+	if path := a.Config.Search.IndexPath; path != "" {
+		err := index.Load(path)
+		if err == nil {
+			a.Logger.Info("search index loaded", "path", path, "tickets", index.Len())
+			return index, nil
+		}
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case errors.Is(err, search.ErrIndexFormat):
+			a.Logger.Warn("search index file outdated", "path", path, "error", err)
+		default:
+			return nil, fmt.Errorf("%q: %w", err.Error(), ErrLoadSearchIndex)
+		}
+	}
+
+	if err := index.Rebuild(ctx, tickets); err != nil {
+		return nil, fmt.Errorf("%q: %w", err.Error(), ErrRebuildSearchIndex)
+	}
+	a.Logger.Info("search index rebuilt", "tickets", index.Len())
+
+	return index, nil
+}
+
+// Reindex runs the "reindex" subcommand: it rebuilds the search index
+// from the stored tickets and saves it to "-search-index-path".
+//
+// It accepts the same flags as the server, which must not be running
+// with the same index file, as it would overwrite it on shutdown.
+func Reindex(ctx context.Context, args []string) error {
+	var (
+		app Application
+		cfg Config
+	)
+
+	fs := flag.NewFlagSet("ticketsd reindex", flag.ExitOnError)
+	registerConfigFlags(fs, &cfg)
+	fs.Parse(args)
+	app.Config = cfg
+	app.SetLogger()
+
+	if cfg.Search.IndexPath == "" {
+		return ErrSearchIndexPathNotProvided
+	}
+
+	services, err := app.buildServices(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := search.NewIndex()
+	if err := index.Rebuild(ctx, services.Tickets); err != nil {
+		return fmt.Errorf("%q: %w", err.Error(), ErrRebuildSearchIndex)
+	}
+	if err := index.Save(cfg.Search.IndexPath); err != nil {
+		return err
+	}
+
+	app.Logger.Info("search index rebuilt", "path", cfg.Search.IndexPath, "tickets", index.Len())
+
+	return nil
+}
+
+// runSearchRefreshJob rebuilds the search index from the store every interval,
+// until ctx is done, so that it catches up with the writes made by the other
+// instances of the service, or to the store directly.
+func (a *Application) runSearchRefreshJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.SearchIndex.Rebuild(ctx, a.storedTickets); err != nil {
+			a.Logger.Error("search index refresh error", err)
+			continue
+		}
+		a.Logger.Debug("search index refreshed", "tickets", a.SearchIndex.Len())
+	}
+}
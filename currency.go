@@ -0,0 +1,59 @@
+package tixer
+
+// Currency represents an ISO 4217 currency code, such as "EUR".
+type Currency string
+
+// currencies lists the supported currencies, along with the number of
+// decimals of their minor unit.
+var currencies = map[Currency]int{
+	"AUD": 2,
+	"BGN": 2,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"CZK": 2,
+	"DKK": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"HUF": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"MXN": 2,
+	"NOK": 2,
+	"NZD": 2,
+	"PLN": 2,
+	"RON": 2,
+	"SEK": 2,
+	"SGD": 2,
+	"USD": 2,
+	"ZAR": 2,
+}
+
+// Valid reports whether the currency is a supported ISO 4217 code.
+func (c Currency) Valid() bool {
+	_, ok := currencies[c]
+	return ok
+}
+
+// MinorUnits returns the number of decimals of the minor unit of the currency,
+// such as 2 for the cents of EUR. It is 2 for the unsupported currencies.
+func (c Currency) MinorUnits() int {
+	if units, ok := currencies[c]; ok {
+		return units
+	}
+
+	return 2
+}
+
+// scale returns the number of minor units in a major unit of the currency.
+func (c Currency) scale() int64 {
+	scale := int64(1)
+	for i := 0; i < c.MinorUnits(); i++ {
+		scale *= 10
+	}
+
+	return scale
+}
@@ -2,4 +2,36 @@ package tixer
 
 import "errors"
 
-var ErrTicketNotFound = errors.New("ticket not found")
+var (
+	ErrTicketNotFound      = errors.New("ticket not found")
+	ErrTicketAlreadyExists = errors.New("ticket already exists")
+	ErrTicketDeleted       = errors.New("ticket deleted")
+	ErrVersionConflict     = errors.New("ticket version conflict")
+	ErrInvalidTransition   = errors.New("invalid ticket status transition")
+
+	ErrAlreadyHeld     = errors.New("ticket already held")
+	ErrSoldOut         = errors.New("not enough tickets left")
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	ErrHoldNotFound    = errors.New("hold not found")
+	ErrTicketNotOnSale = errors.New("ticket not on sale")
+
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrOrderAlreadyExists     = errors.New("order already exists")
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+	ErrOrderExpired           = errors.New("order expired")
+
+	ErrRefundNotFound          = errors.New("refund not found")
+	ErrInvalidRefundTransition = errors.New("invalid refund status transition")
+
+	ErrPaymentDeclined         = errors.New("payment declined")
+	ErrPaymentNotFound         = errors.New("payment not found")
+	ErrInvalidPaymentAmount    = errors.New("invalid payment amount")
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+
+	ErrIdempotencyKeyReused     = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("idempotency key in use by a request in progress")
+	ErrIdempotencyKeyLost       = errors.New("idempotency key reservation expired")
+)
@@ -0,0 +1,154 @@
+package gcfirestore
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCounterShards is the number of shards used when none is configured.
+const DefaultCounterShards = 10
+
+// shardsCollection is the subcollection of the counter document holding its shards.
+const shardsCollection = "shards"
+
+// counter is a distributed counter of the total tickets.
+//
+// A Firestore document sustains about one write per second, so the count
+// is split across shard documents: every write increments a random shard
+// and the total is the sum of all of them. A shard can go negative when
+// the tickets it counted are deleted through other shards.
+//
+// Before it was sharded, the count was held by the totalTickets field of a
+// document of the tickets collection, which is moved by migrate.
+type counter struct {
+	client *firestore.Client
+	ref    *firestore.DocumentRef
+	shards int
+}
+
+func newCounter(client *firestore.Client, ref *firestore.DocumentRef, shards int) counter {
+	if shards < 1 {
+		shards = DefaultCounterShards
+	}
+
+	return counter{
+		client,
+		ref,
+		shards,
+	}
+}
+
+// incrementTx increments a random shard by n within a transaction.
+func (c counter) incrementTx(tx *firestore.Transaction, n int) error {
+	return tx.Set(c.shardRef(rand.Intn(c.shards)), map[string]any{
+		"count": firestore.Increment(n),
+	}, firestore.MergeAll)
+}
+
+// total sums all the shards.
+//
+// Every shard document is read, not only the configured ones,
+// so the total stays correct when the number of shards is lowered.
+func (c counter) total(ctx context.Context) (int, error) {
+	shards, err := c.ref.Collection(shardsCollection).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	return sumShards(shards)
+}
+
+// totalTx sums all the shards the same way total does, within a transaction.
+func (c counter) totalTx(tx *firestore.Transaction) (int, error) {
+	shards, err := tx.Documents(c.ref.Collection(shardsCollection)).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	return sumShards(shards)
+}
+
+// migrate moves the count of the legacy counter document into the shards,
+// then deletes it. The legacy count is the sum of its totalTickets field,
+// from before the counter was sharded, and of its own shards.
+//
+// It uses a transaction so that the count is neither lost nor counted twice,
+// and does nothing when there is no legacy counter left.
+func (c counter) migrate(ctx context.Context, legacy *firestore.DocumentRef) error {
+	return c.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(legacy)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		shards, err := tx.Documents(legacy.Collection(shardsCollection)).GetAll()
+		if err != nil {
+			return err
+		}
+
+		if !doc.Exists() && len(shards) == 0 {
+			return nil
+		}
+
+		total, err := sumShards(shards)
+		if err != nil {
+			return err
+		}
+		if doc.Exists() {
+			cnt, err := docToPersistedCounter(doc)
+			if err != nil {
+				return err
+			}
+			total += cnt.TotalTickets
+		}
+
+		if err := c.incrementTx(tx, total); err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			if err := tx.Delete(shard.Ref); err != nil {
+				return err
+			}
+		}
+		if doc.Exists() {
+			return tx.Delete(legacy)
+		}
+
+		return nil
+	})
+}
+
+func sumShards(shards []*firestore.DocumentSnapshot) (int, error) {
+	var total int
+	for _, shard := range shards {
+		s, err := docToPersistedShard(shard)
+		if err != nil {
+			return 0, err
+		}
+		total += s.Count
+	}
+
+	return total, nil
+}
+
+func (c counter) shardRef(i int) *firestore.DocumentRef {
+	return c.ref.Collection(shardsCollection).Doc(strconv.Itoa(i))
+}
+
+// persistedShard represents a stored shard of the total tickets counter.
+type persistedShard struct {
+	Count int `firestore:"count"`
+}
+
+func docToPersistedShard(doc *firestore.DocumentSnapshot) (persistedShard, error) {
+	var s persistedShard
+	if err := doc.DataTo(&s); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
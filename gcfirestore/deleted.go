@@ -0,0 +1,211 @@
+package gcfirestore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// deletedDocID is the ID of the document of the tickets collection
+// under which the deleted tickets are kept.
+const deletedDocID = "--deleted--"
+
+// deletedTickets returns the collection of the deleted tickets.
+//
+// It is a subcollection named after the tickets collection, so that
+// the composite indexes of the tickets apply to it as well, under a
+// document which is never written: the queries of the tickets collection
+// neither list nor count the deleted tickets.
+func (s *Storer) deletedTickets() *firestore.CollectionRef {
+	return s.client.Collection(s.collection).Doc(deletedDocID).Collection(s.collection)
+}
+
+// moveToDeletedTx moves a ticket document to the deleted tickets within a transaction,
+// along with its deletion date and its next version.
+func (s *Storer) moveToDeletedTx(tx *firestore.Transaction, doc *firestore.DocumentSnapshot, version int) error {
+	data := doc.Data()
+	data["version"] = version + 1
+	data["dateDeleted"] = firestore.ServerTimestamp
+
+	if err := tx.Create(s.deletedTickets().Doc(doc.Ref.ID), data); err != nil {
+		return err
+	}
+
+	return tx.Delete(doc.Ref)
+}
+
+// ReadDeletedTicket reads a ticket from the deleted tickets.
+func (s *Storer) ReadDeletedTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
+	doc, err := s.deletedTickets().Doc(id.String()).Get(ctx)
+	if err != nil {
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return tixer.Ticket{}, tixer.ErrTicketNotFound
+		default:
+			return tixer.Ticket{}, err
+		}
+	}
+
+	tck, err := docToPersistedTicket(doc)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return toDomainTicket(tck), nil
+}
+
+// RestoreTicket restores a deleted ticket in Firestore, by moving it
+// back to the tickets collection.
+//
+// It uses a transaction to ensure atomicity regarding the restoration
+// of the ticket and the increment of the tickets counter.
+//
+// It makes an extra read to retrieve the restored ticket.
+func (s *Storer) RestoreTicket(ctx context.Context, id tixer.TicketID, version int) (tixer.Ticket, error) {
+	deletedRef := s.deletedTickets().Doc(id.String())
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(deletedRef)
+		if err != nil {
+			switch {
+			case status.Code(err) == codes.NotFound:
+				return tixer.ErrTicketNotFound
+			default:
+				return err
+			}
+		}
+
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return err
+		}
+		if err := toDomainTicket(tck).CheckVersion(version); err != nil {
+			return err
+		}
+
+		data := doc.Data()
+		delete(data, "dateDeleted")
+		data["version"] = tck.Version + 1
+		data["dateUpdated"] = firestore.ServerTimestamp
+
+		if err := tx.Create(s.client.Collection(s.collection).Doc(id.String()), data); err != nil {
+			return err
+		}
+		if err := tx.Delete(deletedRef); err != nil {
+			return err
+		}
+
+		return s.counter.incrementTx(tx, 1)
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return tixer.Ticket{}, tixer.ErrTicketAlreadyExists
+	}
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return s.readTicket(ctx, id)
+}
+
+// PurgeTickets removes the tickets deleted before a date from Firestore.
+//
+// The tickets counter is left as is, as it was decremented when the
+// tickets were deleted. A ticket restored, or deleted again, while it is
+// purged is kept, and is not counted as purged.
+func (s *Storer) PurgeTickets(ctx context.Context, deletedBefore time.Time) (int, error) {
+	docs, err := s.deletedTickets().Where("dateDeleted", "<", deletedBefore).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := s.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+	for _, doc := range docs {
+		job, err := bw.Delete(doc.Ref, firestore.LastUpdateTime(doc.UpdateTime))
+		if err != nil {
+			bw.End()
+			return 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	var purged int
+	for _, job := range jobs {
+		_, err := job.Results()
+		switch {
+		case err == nil:
+			purged++
+		case status.Code(err) == codes.NotFound, status.Code(err) == codes.FailedPrecondition:
+		default:
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// mergeTickets merges the pages of the tickets and of the deleted tickets read
+// for the filter into a single page, in the order of the filter.
+func mergeTickets(tt, deleted []tixer.Ticket, filter tixer.Filter) []tixer.Ticket {
+	orders := filterOrders(filter)
+	_, desc := filter.Sort.Field()
+
+	merged := append(tt, deleted...)
+	sort.Slice(merged, func(i, j int) bool {
+		c := compareTickets(merged[i], merged[j], orders)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	switch {
+	case filter.Limit <= 0 || len(merged) <= filter.Limit:
+	case filter.Before != nil && filter.After == nil:
+		merged = merged[len(merged)-filter.Limit:]
+	default:
+		merged = merged[:filter.Limit]
+	}
+
+	return merged
+}
+
+// compareTickets compares the tickets by the ordered fields of a query,
+// in the ascending order, and returns -1, 0 or +1.
+func compareTickets(a, b tixer.Ticket, orders []string) int {
+	for _, f := range orders {
+		var c int
+		switch f {
+		case "title":
+			c = compareValues(a.Title < b.Title, a.Title > b.Title)
+		case "price":
+			c = compareValues(a.Price.Amount < b.Price.Amount, a.Price.Amount > b.Price.Amount)
+		case "dateCreated":
+			c = compareValues(a.DateCreated.Before(b.DateCreated), a.DateCreated.After(b.DateCreated))
+		case firestore.DocumentID:
+			c = compareValues(a.ID.String() < b.ID.String(), a.ID.String() > b.ID.String())
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func compareValues(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
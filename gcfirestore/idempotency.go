@@ -0,0 +1,187 @@
+package gcfirestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IdempotencyStorer persists idempotency keys in Firestore.
+//
+// Expired keys are overwritten when they are reserved again. A TTL policy
+// on the dateExpires field can be configured to have Firestore delete them.
+type IdempotencyStorer struct {
+	client     *firestore.Client
+	collection string
+}
+
+func NewIdempotencyStorer(client *firestore.Client, collection string) *IdempotencyStorer {
+	return &IdempotencyStorer{
+		client,
+		collection,
+	}
+}
+
+// ReserveKey reserves an idempotency key in Firestore.
+//
+// It uses a transaction to ensure that concurrent requests made with the
+// same key cannot both reserve it.
+func (s *IdempotencyStorer) ReserveKey(ctx context.Context, record tixer.IdempotencyRecord) (tixer.IdempotencyRecord, bool, error) {
+	dRef := s.client.Collection(s.collection).Doc(idempotencyDocID(record.Key))
+
+	var (
+		stored   tixer.IdempotencyRecord
+		reserved bool
+	)
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		reserved = false
+
+		doc, err := tx.Get(dRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if doc.Exists() {
+			var rec persistedIdempotencyRecord
+			if err := doc.DataTo(&rec); err != nil {
+				return err
+			}
+
+			stored = toDomainIdempotencyRecord(rec)
+			if !stored.Expired(time.Now()) {
+				switch {
+				case stored.Fingerprint != record.Fingerprint:
+					return tixer.ErrIdempotencyKeyReused
+				case stored.Response == nil:
+					return tixer.ErrIdempotencyKeyInProgress
+				}
+
+				return nil
+			}
+		}
+
+		// The expiry date is stored to the microsecond, so the reservation
+		// must hold the same date to be recognized by CompleteKey and ReleaseKey.
+		record.Response = nil
+		record.DateExpires = record.DateExpires.Truncate(time.Microsecond)
+		stored, reserved = record, true
+
+		return tx.Set(dRef, toPersistedIdempotencyRecord(record))
+	})
+	if err != nil {
+		return tixer.IdempotencyRecord{}, false, err
+	}
+
+	return stored, reserved, nil
+}
+
+// CompleteKey stores the response of the request in Firestore.
+//
+// It uses a transaction to ensure that the key is still reserved
+// by the request, and was not reserved again once its reservation expired.
+func (s *IdempotencyStorer) CompleteKey(ctx context.Context, reservation, record tixer.IdempotencyRecord) error {
+	dRef := s.client.Collection(s.collection).Doc(idempotencyDocID(reservation.Key))
+
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := s.checkReservationTx(tx, dRef, reservation); err != nil {
+			return err
+		}
+
+		return tx.Set(dRef, toPersistedIdempotencyRecord(record))
+	})
+}
+
+// ReleaseKey deletes the reservation of the key from Firestore.
+//
+// It uses a transaction to ensure that the key is still reserved
+// by the request, and was not reserved again once its reservation expired.
+func (s *IdempotencyStorer) ReleaseKey(ctx context.Context, reservation tixer.IdempotencyRecord) error {
+	dRef := s.client.Collection(s.collection).Doc(idempotencyDocID(reservation.Key))
+
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := s.checkReservationTx(tx, dRef, reservation); err != nil {
+			return err
+		}
+
+		return tx.Delete(dRef)
+	})
+}
+
+// checkReservationTx returns ErrIdempotencyKeyLost when the stored record
+// is not the reservation anymore, or was deleted.
+func (s *IdempotencyStorer) checkReservationTx(tx *firestore.Transaction, dRef *firestore.DocumentRef, reservation tixer.IdempotencyRecord) error {
+	doc, err := tx.Get(dRef)
+	if status.Code(err) == codes.NotFound {
+		return tixer.ErrIdempotencyKeyLost
+	}
+	if err != nil {
+		return err
+	}
+
+	var rec persistedIdempotencyRecord
+	if err := doc.DataTo(&rec); err != nil {
+		return err
+	}
+	if !toDomainIdempotencyRecord(rec).Reserves(reservation) {
+		return tixer.ErrIdempotencyKeyLost
+	}
+
+	return nil
+}
+
+// idempotencyDocID derives a valid document ID from a client provided key,
+// which could contain characters that are not allowed in document IDs.
+func idempotencyDocID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// persistedIdempotencyRecord represents a stored idempotency key in Firestore.
+type persistedIdempotencyRecord struct {
+	Key         string              `firestore:"key"`
+	Fingerprint string              `firestore:"fingerprint"`
+	Completed   bool                `firestore:"completed"`
+	StatusCode  int                 `firestore:"statusCode"`
+	Header      map[string][]string `firestore:"header"`
+	Body        []byte              `firestore:"body"`
+	DateExpires time.Time           `firestore:"dateExpires"`
+}
+
+func toPersistedIdempotencyRecord(r tixer.IdempotencyRecord) persistedIdempotencyRecord {
+	rec := persistedIdempotencyRecord{
+		Key:         r.Key,
+		Fingerprint: r.Fingerprint,
+		DateExpires: r.DateExpires,
+	}
+	if r.Response != nil {
+		rec.Completed = true
+		rec.StatusCode = r.Response.StatusCode
+		rec.Header = r.Response.Header
+		rec.Body = r.Response.Body
+	}
+
+	return rec
+}
+
+func toDomainIdempotencyRecord(r persistedIdempotencyRecord) tixer.IdempotencyRecord {
+	rec := tixer.IdempotencyRecord{
+		Key:         r.Key,
+		Fingerprint: r.Fingerprint,
+		DateExpires: r.DateExpires,
+	}
+	if r.Completed {
+		rec.Response = &tixer.IdempotentResponse{
+			StatusCode: r.StatusCode,
+			Header:     r.Header,
+			Body:       r.Body,
+		}
+	}
+
+	return rec
+}
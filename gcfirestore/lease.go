@@ -0,0 +1,55 @@
+package gcfirestore
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// persistedLease is the lease document, kept in the stats collection.
+type persistedLease struct {
+	Holder      string    `firestore:"holder"`
+	DateExpires time.Time `firestore:"dateExpires"`
+}
+
+// AcquireLease acquires, or renews, the lease of the given name for the holder,
+// until ttl from now. It reports false when another holder owns an unexpired lease.
+//
+// It lets a single instance of the service run a background job: the instance
+// which acquires the lease runs the job, and renews the lease before it expires.
+func (s *Storer) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	dRef := s.client.Collection(s.statsCollection).Doc("--" + name + "-lease--")
+
+	var acquired bool
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+
+		doc, err := tx.Get(dRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		now := time.Now()
+		if doc.Exists() {
+			var lease persistedLease
+			if err := doc.DataTo(&lease); err != nil {
+				return err
+			}
+			if lease.Holder != holder && now.Before(lease.DateExpires) {
+				return nil
+			}
+		}
+
+		acquired = true
+
+		return tx.Set(dRef, persistedLease{Holder: holder, DateExpires: now.Add(ttl)})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return acquired, nil
+}
@@ -0,0 +1,56 @@
+package gcfirestore
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Migration reports the outcome of a migration of the ticket documents,
+// such as MigratePrices.
+//
+// Skipped is the number of documents of the tickets collection
+// that were not migrated because they are not tickets. Conflicted is
+// the number of tickets left unconverted because their batch conflicted
+// with a concurrent write, and FailedBatches the number of these batches.
+type Migration struct {
+	Converted     int
+	Skipped       int
+	Conflicted    int
+	FailedBatches int
+}
+
+// migrateDocs applies the updates to the ticket documents, in batches.
+//
+// Each batch only applies when none of its documents was written since
+// it was read, so that a concurrent write is not overwritten. A batch which
+// conflicts is reported in the migration and the next ones are still applied.
+func (s *Storer) migrateDocs(ctx context.Context, docs []*firestore.DocumentSnapshot, updates [][]firestore.Update, migration *Migration) error {
+	for start := 0; start < len(docs); start += tixer.MaxBatchSize {
+		end := start + tixer.MaxBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		batch := s.client.Batch()
+		for i := start; i < end; i++ {
+			batch.Update(docs[i].Ref, updates[i], firestore.LastUpdateTime(docs[i].UpdateTime))
+		}
+
+		_, err := batch.Commit(ctx)
+		switch {
+		case err == nil:
+			migration.Converted += end - start
+		case status.Code(err) == codes.FailedPrecondition:
+			migration.Conflicted += end - start
+			migration.FailedBatches++
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
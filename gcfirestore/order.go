@@ -0,0 +1,578 @@
+package gcfirestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrMalformedOrder is returned for a document of the orders collection that is not an order.
+var ErrMalformedOrder = errors.New("malformed order document")
+
+// OrderStorer persists orders in Firestore.
+//
+// The units held by the orders are kept in the holds of the ticket documents of
+// the tickets storer, see persistedHold, so that an order and its holds are written
+// in the same transaction, and compete for the tickets with the other holders.
+type OrderStorer struct {
+	client     *firestore.Client
+	collection string
+	tickets    *Storer
+}
+
+func NewOrderStorer(client *firestore.Client, collection string, tickets *Storer) *OrderStorer {
+	return &OrderStorer{
+		client,
+		collection,
+		tickets,
+	}
+}
+
+// CreateOrder creates an order in Firestore, holding the units of its tickets.
+//
+// It uses a transaction to ensure atomicity regarding the creation of the order
+// and the holds of all its tickets, which are read and written back the same way
+// Storer.Hold does: when any ticket cannot be held, nothing is written.
+//
+// The dates are set by the storer rather than through server timestamps,
+// so that the order expires along with its holds.
+func (s *OrderStorer) CreateOrder(ctx context.Context, order tixer.Order, ttl time.Duration) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(order.ID.String())
+	refs := s.ticketRefs(order.Items)
+
+	var created tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+
+		// Firestore stores the dates to the microsecond.
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		created = order
+		created.Status = tixer.OrderPending
+		created.Version = 1
+		created.DateCreated = now
+		created.DateUpdated = now
+		created.DateExpires = now.Add(ttl)
+
+		tickets := make([]tixer.Ticket, len(docs))
+		updates := make([][]firestore.Update, len(docs))
+		for i, doc := range docs {
+			tck, holds, err := docToTicketHolds(doc)
+			if err == nil {
+				_, err = holdTicket(tck, holds, created.Holder(), order.Items[i].Quantity, now, created.DateExpires)
+			}
+			if err != nil {
+				return fmt.Errorf("ticket %s: %w", order.Items[i].TicketID, err)
+			}
+
+			tickets[i] = tck
+			updates[i] = holdsUpdates(holds)
+		}
+
+		created, err = created.WithPrices(tickets)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(oRef, toPersistedOrder(created)); err != nil {
+			return err
+		}
+		for i, ref := range refs {
+			if err := tx.Update(ref, updates[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return tixer.Order{}, tixer.ErrOrderAlreadyExists
+	}
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return created, nil
+}
+
+func (s *OrderStorer) ReadOrder(ctx context.Context, id tixer.OrderID) (tixer.Order, error) {
+	doc, err := s.client.Collection(s.collection).Doc(id.String()).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return docToOrder(doc)
+}
+
+// CancelOrder cancels a pending order in Firestore, releasing the units it holds.
+//
+// It uses a transaction to ensure atomicity regarding the change of status of
+// the order and the release of its holds. The holds which expired and were
+// removed in the meantime, and the tickets which were deleted, are skipped.
+func (s *OrderStorer) CancelOrder(ctx context.Context, id tixer.OrderID) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(id.String())
+
+	var cancelled tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		order, err := s.orderTx(tx, oRef)
+		if err != nil {
+			return err
+		}
+		if err := order.CheckTransition(tixer.OrderCancelled); err != nil {
+			return err
+		}
+
+		refs := s.ticketRefs(order.Items)
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+
+		for i, doc := range docs {
+			_, holds, err := docToTicketHolds(doc)
+			if errors.Is(err, tixer.ErrTicketNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, ok := holds[order.Holder()]; !ok {
+				continue
+			}
+
+			delete(holds, order.Holder())
+			if err := tx.Update(refs[i], holdsUpdates(holds)); err != nil {
+				return err
+			}
+		}
+
+		cancelled = order
+		cancelled.Status = tixer.OrderCancelled
+		cancelled.Version++
+		cancelled.DateUpdated = time.Now().UTC().Truncate(time.Microsecond)
+
+		return tx.Update(oRef, []firestore.Update{
+			{Path: "status", Value: string(cancelled.Status)},
+			{Path: "version", Value: cancelled.Version},
+			{Path: "dateUpdated", Value: cancelled.DateUpdated},
+		})
+	})
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return cancelled, nil
+}
+
+// PayOrder sells the units held by a pending order in Firestore, and marks it paid.
+//
+// It uses a transaction to ensure atomicity regarding the change of status of the
+// order and the sale of its holds, which are read and written back the same way
+// Storer.Confirm does: when any hold expired, nothing is written.
+func (s *OrderStorer) PayOrder(ctx context.Context, id tixer.OrderID, payment tixer.Payment) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(id.String())
+
+	var paid tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		order, err := s.orderTx(tx, oRef)
+		if err != nil {
+			return err
+		}
+		if order.PaidBy(payment) {
+			paid = order
+			return nil
+		}
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		if err := order.CheckPayment(payment, now); err != nil {
+			return err
+		}
+
+		refs := s.ticketRefs(order.Items)
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+
+		updates := make([][]firestore.Update, len(docs))
+		for i, doc := range docs {
+			item := order.Items[i]
+			tck, holds, err := docToTicketHolds(doc)
+			if err != nil {
+				return fmt.Errorf("ticket %s: %w", item.TicketID, err)
+			}
+			if tck.Status != tixer.StatusOnSale {
+				return fmt.Errorf("ticket %s: %w", item.TicketID, tixer.ErrTicketNotOnSale)
+			}
+			hold, ok := holds[order.Holder()]
+			if !ok || hold.Expired(now) {
+				return fmt.Errorf("ticket %s: %w", item.TicketID, tixer.ErrOrderExpired)
+			}
+
+			delete(holds, order.Holder())
+			updates[i] = sellUpdates(tck.Sell(hold.Quantity), holds)
+		}
+
+		for i, ref := range refs {
+			if err := tx.Update(ref, updates[i]); err != nil {
+				return err
+			}
+		}
+
+		paid = order.Pay(payment, now)
+
+		return tx.Update(oRef, []firestore.Update{
+			{Path: "status", Value: string(paid.Status)},
+			{Path: "paymentId", Value: string(paid.PaymentID)},
+			{Path: "captured", Value: paid.Captured.Amount},
+			{Path: "version", Value: paid.Version},
+			{Path: "dateUpdated", Value: paid.DateUpdated},
+		})
+	})
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return paid, nil
+}
+
+// RefundOrder adds a pending refund to the ledger of a paid order in Firestore.
+//
+// It uses a transaction to ensure that the refund is checked against the ledger it is
+// added to, so that concurrent refunds never reserve more than the order bought.
+func (s *OrderStorer) RefundOrder(ctx context.Context, id tixer.OrderID, refund tixer.OrderRefund) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(id.String())
+
+	var refunded tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		order, err := s.orderTx(tx, oRef)
+		if err != nil {
+			return err
+		}
+		if order.RefundedBy(refund.ID) {
+			refunded = order
+			return nil
+		}
+		if err := order.CheckRefund(refund); err != nil {
+			return err
+		}
+
+		refunded = order.Refund(refund, time.Now().UTC().Truncate(time.Microsecond))
+
+		return s.updateRefundsTx(tx, oRef, refunded)
+	})
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return refunded, nil
+}
+
+// ConfirmRefund marks a pending refund of an order in Firestore succeeded, releasing its units.
+//
+// It uses a transaction to ensure atomicity regarding the refund and the release of its
+// units: the order and the ticket documents are read, checked and written back together.
+// The tickets which were deleted, and moved out of the collection, are skipped.
+func (s *OrderStorer) ConfirmRefund(ctx context.Context, id tixer.OrderID, refundID, paymentRefundID string) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(id.String())
+
+	var confirmed tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		order, err := s.orderTx(tx, oRef)
+		if err != nil {
+			return err
+		}
+		refund, err := order.FindRefund(refundID)
+		if err != nil {
+			return err
+		}
+		if refund.Status == tixer.RefundSucceeded {
+			confirmed = order
+			return nil
+		}
+		if confirmed, err = order.ConfirmRefund(refundID, paymentRefundID, time.Now().UTC().Truncate(time.Microsecond)); err != nil {
+			return err
+		}
+
+		refs := s.ticketRefs(refund.Items)
+		var docs []*firestore.DocumentSnapshot
+		if len(refs) > 0 {
+			if docs, err = tx.GetAll(refs); err != nil {
+				return err
+			}
+		}
+
+		for i, doc := range docs {
+			tck, _, err := docToTicketHolds(doc)
+			if errors.Is(err, tixer.ErrTicketNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			tck = tck.Restock(refund.Items[i].Quantity)
+			if err := tx.Update(refs[i], []firestore.Update{
+				{Path: "sold", Value: tck.Sold},
+				{Path: "status", Value: string(tck.Status)},
+				{Path: "dateUpdated", Value: firestore.ServerTimestamp},
+				{Path: "version", Value: firestore.Increment(1)},
+			}); err != nil {
+				return err
+			}
+		}
+
+		return s.updateRefundsTx(tx, oRef, confirmed)
+	})
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return confirmed, nil
+}
+
+// CancelRefund marks a pending refund of an order in Firestore failed.
+func (s *OrderStorer) CancelRefund(ctx context.Context, id tixer.OrderID, refundID string) (tixer.Order, error) {
+	oRef := s.client.Collection(s.collection).Doc(id.String())
+
+	var cancelled tixer.Order
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		order, err := s.orderTx(tx, oRef)
+		if err != nil {
+			return err
+		}
+		refund, err := order.FindRefund(refundID)
+		if err != nil {
+			return err
+		}
+		if refund.Status == tixer.RefundFailed {
+			cancelled = order
+			return nil
+		}
+		if cancelled, err = order.CancelRefund(refundID, time.Now().UTC().Truncate(time.Microsecond)); err != nil {
+			return err
+		}
+
+		return s.updateRefundsTx(tx, oRef, cancelled)
+	})
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return cancelled, nil
+}
+
+// updateRefundsTx writes the ledger of the order, along with its status and version, in the transaction.
+func (s *OrderStorer) updateRefundsTx(tx *firestore.Transaction, oRef *firestore.DocumentRef, order tixer.Order) error {
+	return tx.Update(oRef, []firestore.Update{
+		{Path: "status", Value: string(order.Status)},
+		{Path: "refunds", Value: toPersistedRefunds(order.Refunds)},
+		{Path: "version", Value: order.Version},
+		{Path: "dateUpdated", Value: order.DateUpdated},
+	})
+}
+
+// orderTx reads an order document in the transaction.
+// It returns ErrOrderNotFound when the order does not exist.
+func (s *OrderStorer) orderTx(tx *firestore.Transaction, oRef *firestore.DocumentRef) (tixer.Order, error) {
+	doc, err := tx.Get(oRef)
+	if status.Code(err) == codes.NotFound {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	return docToOrder(doc)
+}
+
+// ticketRefs returns the references of the ticket documents of the line items.
+func (s *OrderStorer) ticketRefs(items []tixer.OrderItem) []*firestore.DocumentRef {
+	refs := make([]*firestore.DocumentRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, s.client.Collection(s.tickets.collection).Doc(item.TicketID.String()))
+	}
+
+	return refs
+}
+
+type (
+	// persistedOrder represents a stored order in Firestore.
+	//
+	// The amounts of the prices, of the total, of the captured payment and of the
+	// refunds are stored as integer amounts of minor units of the currency of the order.
+	// The payment is only set for the orders which were paid, and the refunds for the
+	// orders which were refunded.
+	persistedOrder struct {
+		Items       []persistedOrderItem `firestore:"items"`
+		Total       int64                `firestore:"total"`
+		Currency    string               `firestore:"currency"`
+		Status      string               `firestore:"status"`
+		PaymentID   string               `firestore:"paymentId,omitempty"`
+		Captured    int64                `firestore:"captured,omitempty"`
+		Refunds     []persistedRefund    `firestore:"refunds,omitempty"`
+		Version     int                  `firestore:"version"`
+		DateCreated time.Time            `firestore:"dateCreated"`
+		DateUpdated time.Time            `firestore:"dateUpdated"`
+		DateExpires time.Time            `firestore:"dateExpires"`
+	}
+
+	// persistedOrderItem represents a stored line item of an order.
+	persistedOrderItem struct {
+		TicketID string `firestore:"ticketId"`
+		Quantity int    `firestore:"quantity"`
+		Price    int64  `firestore:"price"`
+	}
+
+	// persistedRefund represents a stored refund of the ledger of an order.
+	//
+	// The refunds stored by earlier versions have no status: they were stored once
+	// the payment gateway refunded them, under the ID of the gateway.
+	persistedRefund struct {
+		ID              string               `firestore:"id"`
+		Status          string               `firestore:"status,omitempty"`
+		PaymentRefundID string               `firestore:"paymentRefundId,omitempty"`
+		Amount          int64                `firestore:"amount"`
+		Items           []persistedOrderItem `firestore:"items"`
+		DateCreated     time.Time            `firestore:"dateCreated"`
+		DateUpdated     time.Time            `firestore:"dateUpdated"`
+	}
+)
+
+func toPersistedOrder(o tixer.Order) persistedOrder {
+	return persistedOrder{
+		Items:       toPersistedOrderItems(o.Items),
+		Total:       o.Total.Amount,
+		Currency:    string(o.Total.Currency),
+		Status:      string(o.Status),
+		PaymentID:   string(o.PaymentID),
+		Captured:    o.Captured.Amount,
+		Refunds:     toPersistedRefunds(o.Refunds),
+		Version:     o.Version,
+		DateCreated: o.DateCreated,
+		DateUpdated: o.DateUpdated,
+		DateExpires: o.DateExpires,
+	}
+}
+
+func toPersistedOrderItems(items []tixer.OrderItem) []persistedOrderItem {
+	persisted := make([]persistedOrderItem, 0, len(items))
+	for _, item := range items {
+		persisted = append(persisted, persistedOrderItem{
+			TicketID: item.TicketID.String(),
+			Quantity: item.Quantity,
+			Price:    item.Price.Amount,
+		})
+	}
+
+	return persisted
+}
+
+func toPersistedRefunds(refunds []tixer.OrderRefund) []persistedRefund {
+	var persisted []persistedRefund
+	for _, r := range refunds {
+		persisted = append(persisted, persistedRefund{
+			ID:              r.ID,
+			Status:          string(r.Status),
+			PaymentRefundID: r.PaymentRefundID,
+			Amount:          r.Amount.Amount,
+			Items:           toPersistedOrderItems(r.Items),
+			DateCreated:     r.DateCreated,
+			DateUpdated:     r.DateUpdated,
+		})
+	}
+
+	return persisted
+}
+
+// docToOrder decodes an order document.
+//
+// It returns ErrMalformedOrder for a document that is not an order,
+// the same way docToPersistedTicket does for the tickets.
+func docToOrder(doc *firestore.DocumentSnapshot) (tixer.Order, error) {
+	id, err := uuid.Parse(doc.Ref.ID)
+	if err != nil {
+		return tixer.Order{}, fmt.Errorf("document %q: %w", doc.Ref.ID, ErrMalformedOrder)
+	}
+
+	var o persistedOrder
+	if err := doc.DataTo(&o); err != nil {
+		return tixer.Order{}, fmt.Errorf("document %q: %v: %w", doc.Ref.ID, err, ErrMalformedOrder)
+	}
+
+	currency := tixer.Currency(o.Currency)
+	items, err := toDomainOrderItems(o.Items, currency)
+	if err != nil {
+		return tixer.Order{}, fmt.Errorf("document %q: %w", doc.Ref.ID, err)
+	}
+
+	var refunds []tixer.OrderRefund
+	for _, r := range o.Refunds {
+		refundItems, err := toDomainOrderItems(r.Items, currency)
+		if err != nil {
+			return tixer.Order{}, fmt.Errorf("document %q: refund %q: %w", doc.Ref.ID, r.ID, err)
+		}
+
+		refund := tixer.OrderRefund{
+			ID:              r.ID,
+			Status:          tixer.RefundStatus(r.Status),
+			PaymentRefundID: r.PaymentRefundID,
+			Amount:          tixer.Money{Amount: r.Amount, Currency: currency},
+			Items:           refundItems,
+			DateCreated:     r.DateCreated,
+			DateUpdated:     r.DateUpdated,
+		}
+		if refund.Status == "" {
+			refund.Status = tixer.RefundSucceeded
+			refund.PaymentRefundID = r.ID
+			refund.DateUpdated = r.DateCreated
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return tixer.Order{
+		ID:          tixer.OrderID(id),
+		Items:       items,
+		Total:       tixer.Money{Amount: o.Total, Currency: currency},
+		Status:      tixer.OrderStatus(o.Status),
+		PaymentID:   tixer.PaymentID(o.PaymentID),
+		Captured:    tixer.Money{Amount: o.Captured, Currency: currency},
+		Refunds:     refunds,
+		Version:     o.Version,
+		DateCreated: o.DateCreated,
+		DateUpdated: o.DateUpdated,
+		DateExpires: o.DateExpires,
+	}, nil
+}
+
+// toDomainOrderItems decodes the stored line items of an order, priced in its currency.
+// It returns ErrMalformedOrder for a ticket ID which is not a UUID.
+func toDomainOrderItems(persisted []persistedOrderItem, currency tixer.Currency) ([]tixer.OrderItem, error) {
+	items := make([]tixer.OrderItem, 0, len(persisted))
+	for _, item := range persisted {
+		ticketID, err := uuid.Parse(item.TicketID)
+		if err != nil {
+			return nil, fmt.Errorf("ticket %q: %w", item.TicketID, ErrMalformedOrder)
+		}
+
+		items = append(items, tixer.OrderItem{
+			TicketID: tixer.TicketID(ticketID),
+			Quantity: item.Quantity,
+			Price:    tixer.Money{Amount: item.Price, Currency: currency},
+		})
+	}
+
+	return items, nil
+}
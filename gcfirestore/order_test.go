@@ -0,0 +1,20 @@
+package gcfirestore_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/gcfirestore"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+// TestOrderStorer runs the orders conformance suite against the emulator.
+func TestOrderStorer(t *testing.T) {
+	client := newEmulatorClient(t)
+
+	tixertest.RunOrderServiceSuite(t, func(t *testing.T) (tixer.TicketService, tixer.OrderService) {
+		tickets, _, _ := newStorer(client)
+		return tickets, gcfirestore.NewOrderStorer(client, "orders-"+uuid.NewString(), tickets)
+	})
+}
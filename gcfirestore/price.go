@@ -0,0 +1,66 @@
+package gcfirestore
+
+import (
+	"context"
+	"math"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// MigratePrices rewrites the prices stored as a floating point amount of
+// major units, by earlier versions, as an integer amount of minor units.
+//
+// The tickets are converted in batches, each of them only applying when none of
+// its tickets was written since it was read, so that a concurrent write is not
+// overwritten. A batch which conflicts is reported and the next ones are still
+// applied. It does nothing for the tickets already converted, so it can be run
+// again to convert the tickets of the failed batches.
+func (s *Storer) MigratePrices(ctx context.Context) (Migration, error) {
+	docs, err := s.client.Collection(s.collection).Select("price", "currency").Documents(ctx).GetAll()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	var (
+		migration Migration
+		legacy    []*firestore.DocumentSnapshot
+		updates   [][]firestore.Update
+	)
+	for _, doc := range docs {
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			migration.Skipped++
+			continue
+		}
+		if price, ok := tck.Price.(float64); ok {
+			legacy = append(legacy, doc)
+			updates = append(updates, []firestore.Update{
+				{Path: "price", Value: legacyAmount(price, tixer.Currency(tck.Currency))},
+			})
+		}
+	}
+
+	err = s.migrateDocs(ctx, legacy, updates, &migration)
+
+	return migration, err
+}
+
+// storedPrice returns the price of a ticket document, whose amount is either
+// an integer amount of minor units, or a legacy floating point amount of major units.
+func storedPrice(price any, currency tixer.Currency) tixer.Money {
+	switch p := price.(type) {
+	case float64:
+		return tixer.Money{Amount: legacyAmount(p, currency), Currency: currency}
+	case int64:
+		return tixer.Money{Amount: p, Currency: currency}
+	default:
+		return tixer.Money{Currency: currency}
+	}
+}
+
+// legacyAmount converts a floating point amount of major units to minor units,
+// rounded to the nearest one.
+func legacyAmount(price float64, currency tixer.Currency) int64 {
+	return int64(math.Round(price * math.Pow10(currency.MinorUnits())))
+}
@@ -0,0 +1,75 @@
+package gcfirestore
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+)
+
+// CounterDrift reports how far the tickets counter is from
+// the number of tickets actually stored.
+//
+// Skipped is the number of documents of the tickets collection
+// that were not counted because they are not tickets.
+type CounterDrift struct {
+	Counted int
+	Total   int
+	Skipped int
+}
+
+// Diff returns the correction to apply to the counter.
+// It is positive when the counter is behind the stored tickets.
+func (d CounterDrift) Diff() int {
+	return d.Counted - d.Total
+}
+
+// Recount counts the ticket documents of the collection and compares the
+// result with the tickets counter. When apply is set, the counter is fixed.
+//
+// The tickets and the counter are read in a read-only transaction, from a
+// consistent snapshot which does not lock the tickets. The correction is then
+// applied in a transaction of its own, which only writes a counter shard: the
+// tickets created or deleted meanwhile increment the counter as well, so the
+// correction computed from the snapshot still holds.
+func (s *Storer) Recount(ctx context.Context, apply bool) (CounterDrift, error) {
+	// Only the document names are needed to count the tickets.
+	query := s.client.Collection(s.collection).Select()
+
+	var drift CounterDrift
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		drift = CounterDrift{}
+
+		docs, err := tx.Documents(query).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if _, err := uuid.Parse(doc.Ref.ID); err != nil {
+				drift.Skipped++
+				continue
+			}
+			drift.Counted++
+		}
+
+		drift.Total, err = s.counter.totalTx(tx)
+
+		return err
+	}, firestore.ReadOnly)
+	if err != nil {
+		return CounterDrift{}, err
+	}
+
+	if !apply || drift.Diff() == 0 {
+		return drift, nil
+	}
+
+	err = s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return s.counter.incrementTx(tx, drift.Diff())
+	})
+	if err != nil {
+		return CounterDrift{}, err
+	}
+
+	return drift, nil
+}
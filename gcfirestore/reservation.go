@@ -0,0 +1,274 @@
+package gcfirestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// persistedHold represents a hold of a ticket, which is kept in the "holds"
+// map of the ticket document, by holder.
+//
+// The document also holds "holdsExpire", the date the first of its holds expires,
+// which the expired holds are queried by. Both fields are deleted once the ticket
+// has no holds left.
+type persistedHold struct {
+	Quantity    int       `firestore:"quantity"`
+	DateExpires time.Time `firestore:"dateExpires"`
+}
+
+// Hold holds units of a ticket for the holder in Firestore, or replaces its hold.
+//
+// It uses a transaction to ensure that the units sold and held never exceed the
+// capacity of the ticket: the holds are read from the ticket document and written
+// back to it. The expired holds of the other holders are removed along the way.
+//
+// As every hold and sale of a ticket writes its document, a ticket sustains about
+// one of them per second, and the concurrent ones are retried by the transactions.
+// The capacity of a large event is better split across several ticket types.
+func (s *Storer) Hold(ctx context.Context, ticketID tixer.TicketID, holder string, quantity int, ttl time.Duration) (tixer.Hold, error) {
+	tRef := s.client.Collection(s.collection).Doc(ticketID.String())
+
+	var hold tixer.Hold
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		tck, holds, err := s.ticketHoldsTx(tx, tRef)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		hold, err = holdTicket(tck, holds, holder, quantity, now, now.Add(ttl))
+		if err != nil {
+			return err
+		}
+
+		return tx.Update(tRef, holdsUpdates(holds))
+	})
+	if err != nil {
+		return tixer.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// Release removes the hold of the holder on a ticket in Firestore.
+func (s *Storer) Release(ctx context.Context, ticketID tixer.TicketID, holder string) error {
+	tRef := s.client.Collection(s.collection).Doc(ticketID.String())
+
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		_, holds, err := s.ticketHoldsTx(tx, tRef)
+		if err != nil {
+			if errors.Is(err, tixer.ErrTicketNotFound) {
+				return tixer.ErrHoldNotFound
+			}
+			return err
+		}
+
+		if _, ok := holds[holder]; !ok {
+			return tixer.ErrHoldNotFound
+		}
+		delete(holds, holder)
+
+		return tx.Update(tRef, holdsUpdates(holds))
+	})
+}
+
+// Confirm sells the units of a ticket held by the holder in Firestore.
+//
+// It uses a transaction to ensure that the hold is still held when its units are
+// sold, and that the hold is removed along with it.
+//
+// It makes an extra read to retrieve the updated ticket.
+func (s *Storer) Confirm(ctx context.Context, ticketID tixer.TicketID, holder string) (tixer.Ticket, error) {
+	tRef := s.client.Collection(s.collection).Doc(ticketID.String())
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		tck, holds, err := s.ticketHoldsTx(tx, tRef)
+		if err != nil {
+			return err
+		}
+		if tck.Status != tixer.StatusOnSale {
+			return tixer.ErrTicketNotOnSale
+		}
+
+		hold, ok := holds[holder]
+		if !ok || hold.Expired(time.Now()) {
+			return tixer.ErrHoldNotFound
+		}
+		delete(holds, holder)
+
+		return tx.Update(tRef, sellUpdates(tck.Sell(hold.Quantity), holds))
+	})
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return s.readTicket(ctx, ticketID)
+}
+
+// ReleaseExpired removes the holds expired at the given time from Firestore.
+//
+// The tickets holding an expired hold are queried by the date their first hold
+// expires, and each of them is released in a transaction of its own, which reads
+// the holds again so that a hold extended in the meantime is kept.
+func (s *Storer) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	docs, err := s.client.Collection(s.collection).Where("holdsExpire", "<=", now).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var released int
+	for _, doc := range docs {
+		n, err := s.releaseExpired(ctx, doc.Ref, now)
+		if err != nil {
+			return released, err
+		}
+		released += n
+	}
+
+	return released, nil
+}
+
+// releaseExpired removes the holds of a ticket expired at the given time,
+// and returns how many were removed.
+func (s *Storer) releaseExpired(ctx context.Context, tRef *firestore.DocumentRef, now time.Time) (int, error) {
+	var released int
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		released = 0
+
+		_, holds, err := s.ticketHoldsTx(tx, tRef)
+		if errors.Is(err, tixer.ErrTicketNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for holder, hold := range holds {
+			if hold.Expired(now) {
+				delete(holds, holder)
+				released++
+			}
+		}
+		if released == 0 {
+			return nil
+		}
+
+		return tx.Update(tRef, holdsUpdates(holds))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return released, nil
+}
+
+// ticketHoldsTx reads a ticket document in the transaction, and returns the ticket
+// along with its holds. It returns ErrTicketNotFound when the ticket does not exist.
+func (s *Storer) ticketHoldsTx(tx *firestore.Transaction, tRef *firestore.DocumentRef) (tixer.Ticket, map[string]tixer.Hold, error) {
+	doc, err := tx.Get(tRef)
+	if status.Code(err) == codes.NotFound {
+		return tixer.Ticket{}, nil, tixer.ErrTicketNotFound
+	}
+	if err != nil {
+		return tixer.Ticket{}, nil, err
+	}
+
+	return docToTicketHolds(doc)
+}
+
+// docToTicketHolds decodes a ticket document along with its holds.
+// It returns ErrTicketNotFound when the document does not exist.
+func docToTicketHolds(doc *firestore.DocumentSnapshot) (tixer.Ticket, map[string]tixer.Hold, error) {
+	if !doc.Exists() {
+		return tixer.Ticket{}, nil, tixer.ErrTicketNotFound
+	}
+
+	tck, err := docToPersistedTicket(doc)
+	if err != nil {
+		return tixer.Ticket{}, nil, err
+	}
+
+	return toDomainTicket(tck), storedHolds(tck.ID, tck.Holds), nil
+}
+
+// holdTicket holds quantity units of a ticket read in a transaction for the holder,
+// until the given expiry date, by adding the hold to the holds of the ticket.
+// The holds are left to be written by the caller, see holdsUpdates.
+func holdTicket(tck tixer.Ticket, holds map[string]tixer.Hold, holder string, quantity int, now, expires time.Time) (tixer.Hold, error) {
+	if tck.Status != tixer.StatusOnSale {
+		return tixer.Hold{}, tixer.ErrTicketNotOnSale
+	}
+	if err := tck.CheckHold(holds, holder, quantity, now); err != nil {
+		return tixer.Hold{}, err
+	}
+
+	hold := tixer.Hold{TicketID: tck.ID, Holder: holder, Quantity: quantity, DateExpires: expires}
+	holds[holder] = hold
+
+	return hold, nil
+}
+
+// holdsUpdates returns the Firestore updates storing the holds of a ticket,
+// along with the date the first of them expires.
+func holdsUpdates(holds map[string]tixer.Hold) []firestore.Update {
+	if len(holds) == 0 {
+		return []firestore.Update{
+			{Path: "holds", Value: firestore.Delete},
+			{Path: "holdsExpire", Value: firestore.Delete},
+		}
+	}
+
+	var first time.Time
+	persisted := make(map[string]persistedHold, len(holds))
+	for holder, hold := range holds {
+		persisted[holder] = persistedHold{Quantity: hold.Quantity, DateExpires: hold.DateExpires}
+		if first.IsZero() || hold.DateExpires.Before(first) {
+			first = hold.DateExpires
+		}
+	}
+
+	return []firestore.Update{
+		{Path: "holds", Value: persisted},
+		{Path: "holdsExpire", Value: first},
+	}
+}
+
+// sellUpdates returns the Firestore updates storing the units sold of a ticket
+// along with its holds, from which the hold sold was removed.
+func sellUpdates(tck tixer.Ticket, holds map[string]tixer.Hold) []firestore.Update {
+	return append(holdsUpdates(holds),
+		firestore.Update{Path: "sold", Value: tck.Sold},
+		firestore.Update{Path: "status", Value: string(tck.Status)},
+		firestore.Update{Path: "dateUpdated", Value: firestore.ServerTimestamp},
+		firestore.Update{Path: "version", Value: firestore.Increment(1)},
+	)
+}
+
+// storedHolds returns the holds of a ticket document, by holder.
+//
+// Each hold is either a persistedHold, or the date it expires for the holds
+// written before tickets had a capacity, which hold a single unit.
+func storedHolds(ticketID tixer.TicketID, holds map[string]any) map[string]tixer.Hold {
+	stored := make(map[string]tixer.Hold, len(holds))
+	for holder, h := range holds {
+		hold := tixer.Hold{TicketID: ticketID, Holder: holder, Quantity: 1}
+		switch h := h.(type) {
+		case time.Time:
+			hold.DateExpires = h
+		case map[string]any:
+			if quantity, ok := h["quantity"].(int64); ok {
+				hold.Quantity = int(quantity)
+			}
+			hold.DateExpires, _ = h["dateExpires"].(time.Time)
+		}
+		stored[holder] = hold
+	}
+
+	return stored
+}
@@ -0,0 +1,44 @@
+package gcfirestore
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// MigrateStatuses sets the status of the tickets written before statuses
+// existed, which are on sale, so that they can be filtered by status.
+//
+// The tickets are migrated in batches, the same way MigratePrices converts them,
+// so it can be run again to migrate the tickets of the failed batches.
+func (s *Storer) MigrateStatuses(ctx context.Context) (Migration, error) {
+	docs, err := s.client.Collection(s.collection).Select("price", "status").Documents(ctx).GetAll()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	var (
+		migration Migration
+		legacy    []*firestore.DocumentSnapshot
+		updates   [][]firestore.Update
+	)
+	for _, doc := range docs {
+		if _, err := docToPersistedTicket(doc); err != nil {
+			migration.Skipped++
+			continue
+		}
+		if _, err := doc.DataAt("status"); err == nil {
+			continue
+		}
+
+		legacy = append(legacy, doc)
+		updates = append(updates, []firestore.Update{
+			{Path: "status", Value: string(tixer.StatusOnSale)},
+		})
+	}
+
+	err = s.migrateDocs(ctx, legacy, updates, &migration)
+
+	return migration, err
+}
@@ -4,6 +4,7 @@ package gcfirestore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -13,46 +14,113 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-var ErrCounterNotFound = errors.New("counter not found")
+// ErrMalformedTicket is returned for a document of the tickets collection that is not a ticket.
+var ErrMalformedTicket = errors.New("malformed ticket document")
 
 // Storer persists tickets in Firestore.
+//
+// The total tickets are counted by a counter sharded across counterShards
+// documents, see counter. The counter is kept in the counterDocID document
+// of the stats collection, so that the tickets collection only holds tickets.
+//
+// The deleted tickets are moved out of the tickets collection, see deletedTickets,
+// so that neither the listings nor the counter have to tell them apart.
 type Storer struct {
-	client       *firestore.Client
-	collection   string
-	counterDocID string
+	client          *firestore.Client
+	collection      string
+	statsCollection string
+	counterDocID    string
+	counter         counter
 }
 
-func NewStorer(client *firestore.Client, collection, counterDocID string) *Storer {
+func NewStorer(client *firestore.Client, collection, statsCollection, counterDocID string, counterShards int) *Storer {
 	return &Storer{
 		client,
 		collection,
+		statsCollection,
 		counterDocID,
+		newCounter(client, client.Collection(statsCollection).Doc(counterDocID), counterShards),
 	}
 }
 
+// MigrateCounter moves the count of a counter stored in the tickets collection,
+// where it was kept before it had a collection of its own, into the stats collection.
+// It is safe to run more than once.
+func (s *Storer) MigrateCounter(ctx context.Context) error {
+	return s.counter.migrate(ctx, s.client.Collection(s.collection).Doc(s.counterDocID))
+}
+
 // CreateTicket creates a ticket in Firestore.
 //
 // It uses a transaction to ensure atomicity regarding
-// the creation of the ticket and the increment of the totalTickets field.
+// the creation of the ticket and the increment of the tickets counter.
+// A deleted ticket of the same ID is read in the transaction, so that
+// it cannot be created again.
 func (s *Storer) CreateTicket(ctx context.Context, ticket tixer.Ticket) error {
 	tRef := s.client.Collection(s.collection).Doc(ticket.ID.String())
-	cRef := s.client.Collection(s.collection).Doc(s.counterDocID)
 
 	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		err := tx.Create(tRef, createTicket{
-			Title: ticket.Title,
-			Price: ticket.Price,
-		})
+		deleted, err := tx.Get(s.deletedTickets().Doc(ticket.ID.String()))
+		switch {
+		case err == nil && deleted.Exists():
+			return tixer.ErrTicketAlreadyExists
+		case err != nil && status.Code(err) != codes.NotFound:
+			return err
+		}
+
+		err = tx.Create(tRef, newCreateTicket(ticket))
 		if err != nil {
 			return err
 		}
 
-		err = tx.Update(cRef, []firestore.Update{
-			{Path: "totalTickets", Value: firestore.Increment(1)},
-		})
+		return s.counter.incrementTx(tx, 1)
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return tixer.ErrTicketAlreadyExists
+	}
+
+	return err
+}
+
+// CreateTickets creates tickets in Firestore.
+//
+// It uses a transaction to ensure atomicity regarding the creation
+// of the tickets and a single increment of the tickets counter.
+// The deleted tickets of the same IDs are read in the transaction,
+// the same way CreateTicket does.
+func (s *Storer) CreateTickets(ctx context.Context, tickets []tixer.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
 
-		return err
+	deletedRefs := make([]*firestore.DocumentRef, 0, len(tickets))
+	for _, ticket := range tickets {
+		deletedRefs = append(deletedRefs, s.deletedTickets().Doc(ticket.ID.String()))
+	}
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		deleted, err := tx.GetAll(deletedRefs)
+		if err != nil {
+			return err
+		}
+		for _, doc := range deleted {
+			if doc.Exists() {
+				return tixer.ErrTicketAlreadyExists
+			}
+		}
+
+		for _, ticket := range tickets {
+			err := tx.Create(s.client.Collection(s.collection).Doc(ticket.ID.String()), newCreateTicket(ticket))
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.counter.incrementTx(tx, len(tickets))
 	})
+	if status.Code(err) == codes.AlreadyExists {
+		return tixer.ErrTicketAlreadyExists
+	}
 
 	return err
 }
@@ -63,13 +131,16 @@ func (s *Storer) ReadTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticke
 
 // UpdateTicket updates a ticket in Firestore.
 //
-// It uses a transaction to ensure no data races occur.
+// It uses a transaction to ensure no data races occur: the version read
+// inside the transaction is checked against the expected one and incremented.
+//
+// Only the fields provided by the update are written.
 //
 // It makes an extra read to retrieve the updated ticket.
-func (s *Storer) UpdateTicket(ctx context.Context, ticket tixer.Ticket) (tixer.Ticket, error) {
-	dRef := s.client.Collection(s.collection).Doc(ticket.ID.String())
+func (s *Storer) UpdateTicket(ctx context.Context, update tixer.TicketUpdate) (tixer.Ticket, error) {
+	dRef := s.client.Collection(s.collection).Doc(update.ID.String())
 	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		_, err := tx.Get(dRef)
+		doc, err := tx.Get(dRef)
 		if err != nil {
 			switch {
 			case status.Code(err) == codes.NotFound:
@@ -79,121 +150,547 @@ func (s *Storer) UpdateTicket(ctx context.Context, ticket tixer.Ticket) (tixer.T
 			}
 		}
 
-		updates := []firestore.Update{
-			{Path: "dateUpdated", Value: firestore.ServerTimestamp},
-		}
-		if ticket.Title != "" {
-			updates = append(updates, firestore.Update{
-				Path:  "title",
-				Value: ticket.Title,
-			})
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return err
 		}
-		if ticket.Price != 0 {
-			updates = append(updates, firestore.Update{
-				Path:  "price",
-				Value: ticket.Price,
-			})
+		if err := toDomainTicket(tck).CheckVersion(update.Version); err != nil {
+			return err
 		}
 
-		return tx.Update(dRef, updates)
+		return tx.Update(dRef, ticketUpdates(update))
 	})
 	if err != nil {
 		return tixer.Ticket{}, err
 	}
 
-	return s.readTicket(ctx, ticket.ID)
+	return s.readTicket(ctx, update.ID)
 }
 
-func (s *Storer) DeleteTicket(ctx context.Context, id tixer.TicketID) error {
-	tRef := s.client.Collection(s.collection).Doc(id.String())
-	cRef := s.client.Collection(s.collection).Doc(s.counterDocID)
+// UpdateTickets updates a batch of tickets in Firestore.
+//
+// It uses a transaction to ensure no data races occur, the same way
+// UpdateTicket does, and to apply all the valid updates together.
+//
+// It makes an extra read to retrieve the updated tickets.
+func (s *Storer) UpdateTickets(ctx context.Context, updates []tixer.TicketUpdate) ([]tixer.TicketResult, error) {
+	refs := make([]*firestore.DocumentRef, 0, len(updates))
+	for _, update := range updates {
+		refs = append(refs, s.client.Collection(s.collection).Doc(update.ID.String()))
+	}
 
+	results := make([]tixer.TicketResult, len(updates))
 	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		err := tx.Delete(tRef)
+		docs, err := tx.GetAll(refs)
 		if err != nil {
 			return err
 		}
 
-		err = tx.Update(cRef, []firestore.Update{
-			{Path: "totalTickets", Value: firestore.Increment(-1)},
-		})
+		for i, doc := range docs {
+			results[i] = tixer.TicketResult{}
+			if !doc.Exists() {
+				results[i].Err = tixer.ErrTicketNotFound
+				continue
+			}
+
+			tck, err := docToPersistedTicket(doc)
+			if err != nil {
+				return err
+			}
+			if err := toDomainTicket(tck).CheckVersion(updates[i].Version); err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			if err := tx.Update(refs[i], ticketUpdates(updates[i])); err != nil {
+				return err
+			}
+		}
 
-		return err
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	docs, err := s.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range docs {
+		if results[i].Err != nil {
+			continue
+		}
+
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return nil, err
+		}
+		results[i].Ticket = toDomainTicket(tck)
+	}
+
+	return results, nil
 }
 
-func (s *Storer) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
-	query := s.client.Collection(s.collection).OrderBy("dateCreated", firestore.Desc).Limit(filter.Limit)
+// ReplaceTicket replaces the editable fields of a ticket in Firestore,
+// or creates the ticket when it does not exist.
+//
+// It uses a transaction to ensure atomicity regarding the creation
+// of the ticket and the increment of the tickets counter, the same
+// way CreateTicket does.
+//
+// It makes an extra read to retrieve the stored ticket.
+func (s *Storer) ReplaceTicket(ctx context.Context, ticket tixer.Ticket) (tixer.Ticket, bool, error) {
+	tRef := s.client.Collection(s.collection).Doc(ticket.ID.String())
+	deletedRef := s.deletedTickets().Doc(ticket.ID.String())
+
+	var created bool
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		created = false
+
+		docs, err := tx.GetAll([]*firestore.DocumentRef{tRef, deletedRef})
+		if err != nil {
+			return err
+		}
+
+		doc := docs[0]
+		if !doc.Exists() {
+			if docs[1].Exists() {
+				return tixer.ErrTicketDeleted
+			}
+			if ticket.Version != tixer.AnyVersion {
+				return tixer.ErrVersionConflict
+			}
+
+			err = tx.Create(tRef, newCreateTicket(ticket))
+			if err != nil {
+				return err
+			}
+
+			created = true
+			return s.counter.incrementTx(tx, 1)
+		}
+
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return err
+		}
+		if err := toDomainTicket(tck).CheckVersion(ticket.Version); err != nil {
+			return err
+		}
+
+		return tx.Update(tRef, replaceUpdates(ticket))
+	})
+	if err != nil {
+		return tixer.Ticket{}, false, err
+	}
 
-	if filter.After.String() != uuid.Nil.String() {
-		afterDoc, err := s.client.Collection(s.collection).Doc(filter.After.String()).Get(ctx)
+	tck, err := s.readTicket(ctx, ticket.ID)
+	if err != nil {
+		return tixer.Ticket{}, false, err
+	}
+
+	return tck, created, nil
+}
+
+// TransitionTicket moves a ticket to the next status in Firestore.
+//
+// It uses a transaction to ensure no data races occur: the version and the status
+// read inside the transaction are checked before the status is changed.
+//
+// It makes an extra read to retrieve the updated ticket.
+func (s *Storer) TransitionTicket(ctx context.Context, id tixer.TicketID, next tixer.TicketStatus, version int) (tixer.Ticket, error) {
+	dRef := s.client.Collection(s.collection).Doc(id.String())
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(dRef)
 		if err != nil {
 			switch {
 			case status.Code(err) == codes.NotFound:
-				return nil, tixer.Metadata{}, tixer.ErrTicketNotFound
+				return tixer.ErrTicketNotFound
 			default:
-				return nil, tixer.Metadata{}, err
+				return err
 			}
 		}
-		query = query.StartAfter(afterDoc)
+
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return err
+		}
+		stored := toDomainTicket(tck)
+		if err := stored.CheckVersion(version); err != nil {
+			return err
+		}
+		if err := stored.CheckTransition(next); err != nil {
+			return err
+		}
+
+		return tx.Update(dRef, []firestore.Update{
+			{Path: "status", Value: string(next)},
+			{Path: "dateUpdated", Value: firestore.ServerTimestamp},
+			{Path: "version", Value: firestore.Increment(1)},
+		})
+	})
+	if err != nil {
+		return tixer.Ticket{}, err
 	}
-	if filter.Before.String() != uuid.Nil.String() {
-		beforeDoc, err := s.client.Collection(s.collection).Doc(filter.Before.String()).Get(ctx)
+
+	return s.readTicket(ctx, id)
+}
+
+// DeleteTicket soft deletes a ticket in Firestore, by moving it
+// to the deleted tickets.
+//
+// It uses a transaction to ensure atomicity regarding
+// the deletion of the ticket and the decrement of the tickets counter.
+// The ticket is read first so that deleting a missing ticket
+// does not decrement the counter, and so that its version can be checked.
+func (s *Storer) DeleteTicket(ctx context.Context, id tixer.TicketID, version int) error {
+	tRef := s.client.Collection(s.collection).Doc(id.String())
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(tRef)
 		if err != nil {
 			switch {
 			case status.Code(err) == codes.NotFound:
-				return nil, tixer.Metadata{}, tixer.ErrTicketNotFound
+				return tixer.ErrTicketNotFound
 			default:
-				return nil, tixer.Metadata{}, err
+				return err
 			}
 		}
-		query = query.EndBefore(beforeDoc)
-	}
 
-	docs, err := query.Documents(ctx).GetAll()
-	if err != nil {
-		return nil, tixer.Metadata{}, err
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			return err
+		}
+		if err := toDomainTicket(tck).CheckVersion(version); err != nil {
+			return err
+		}
+
+		err = s.moveToDeletedTx(tx, doc, tck.Version)
+		if err != nil {
+			return err
+		}
+
+		return s.counter.incrementTx(tx, -1)
+	})
+
+	return err
+}
+
+// DeleteTickets soft deletes a batch of tickets in Firestore, the same way
+// DeleteTicket does.
+//
+// It uses a transaction to ensure atomicity regarding the deletion of the
+// tickets and the decrement of the tickets counter, which is decremented
+// by the number of tickets that actually existed. A batch of more than
+// tixer.MaxDeleteBatchSize tickets exceeds the writes of a transaction.
+func (s *Storer) DeleteTickets(ctx context.Context, ids []tixer.TicketID) ([]error, error) {
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, s.client.Collection(s.collection).Doc(id.String()))
 	}
 
-	var tt []tixer.Ticket
-	for _, doc := range docs {
-		tck, err := docToPersistedTicket(doc)
+	results := make([]error, len(ids))
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(refs)
 		if err != nil {
-			return nil, tixer.Metadata{}, err
+			return err
 		}
 
-		tt = append(tt, toDomainTicket(tck))
+		var deleted int
+		for i, doc := range docs {
+			results[i] = nil
+			if !doc.Exists() {
+				results[i] = tixer.ErrTicketNotFound
+				continue
+			}
+
+			tck, err := docToPersistedTicket(doc)
+			if err != nil {
+				return err
+			}
+			if err := s.moveToDeletedTx(tx, doc, tck.Version); err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		if deleted == 0 {
+			return nil
+		}
+
+		return s.counter.incrementTx(tx, -deleted)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	counterDoc, err := s.client.Collection(s.collection).Doc(s.counterDocID).Get(ctx)
+	return results, nil
+}
+
+// ReadTickets reads a page of the tickets matching the filter, in the order of filter.Sort.
+//
+// Every criterion of the filter is applied by Firestore. Combining the sort with
+// criteria on other fields requires the composite indexes of firestore.indexes.json.
+//
+// The cursors are turned into the values of the ordered fields, so the
+// tickets they were taken from are not read.
+//
+// The deleted tickets are read with the same query, and merged with
+// the others, when the filter includes them.
+func (s *Storer) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
+	tt, err := s.readTickets(ctx, s.client.Collection(s.collection).Query, filter)
 	if err != nil {
-		switch {
-		case status.Code(err) == codes.NotFound:
-			return nil, tixer.Metadata{}, ErrCounterNotFound
-		default:
+		return nil, tixer.Metadata{}, err
+	}
+	if filter.IncludeDeleted {
+		deleted, err := s.readTickets(ctx, s.deletedTickets().Query, filter)
+		if err != nil {
 			return nil, tixer.Metadata{}, err
 		}
+		tt = mergeTickets(tt, deleted, filter)
 	}
-	cnt, err := docToPersistedCounter(counterDoc)
+
+	total, err := s.counter.total(ctx)
 	if err != nil {
 		return nil, tixer.Metadata{}, err
 	}
 
-	var after, before tixer.TicketID
-	if len(tt) > 0 {
-		before = tt[0].ID
-		after = tt[len(tt)-1].ID
-	}
+	before, after := tixer.PageCursors(tt)
 
 	return tt, tixer.Metadata{
 		After:  after,
 		Before: before,
-		Total:  cnt.TotalTickets,
+		Total:  total,
 	}, nil
 }
 
+// readTickets reads a page of the tickets of a collection matching the filter.
+func (s *Storer) readTickets(ctx context.Context, collection firestore.Query, filter tixer.Filter) ([]tixer.Ticket, error) {
+	query, orders := filterQuery(collection, filter)
+
+	if filter.After != nil {
+		query = query.StartAfter(cursorValues(*filter.After, orders)...)
+	}
+	if filter.Before != nil {
+		query = query.EndBefore(cursorValues(*filter.Before, orders)...)
+	}
+
+	switch {
+	case filter.Limit <= 0:
+	case filter.Before != nil && filter.After == nil:
+		query = query.LimitToLast(filter.Limit)
+	default:
+		query = query.Limit(filter.Limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var tt []tixer.Ticket
+	for _, doc := range docs {
+		tck, err := docToPersistedTicket(doc)
+		if err != nil {
+			// A document that is not a ticket must not break the whole listing.
+			if errors.Is(err, ErrMalformedTicket) {
+				continue
+			}
+			return nil, err
+		}
+
+		tt = append(tt, toDomainTicket(tck))
+	}
+
+	return tt, nil
+}
+
+// filterQuery returns the query applying the criteria and the order of the filter,
+// along with the fields it is ordered by.
+//
+// When the criteria are ranges over fields other than the sorted one, Firestore
+// orders by these fields before the document name, so the query orders by them
+// explicitly for the cursors to match the order. The ties of the sorted field
+// are then broken by these fields first, in the direction of the sort.
+func filterQuery(query firestore.Query, filter tixer.Filter) (firestore.Query, []string) {
+	if filter.TitlePrefix != "" {
+		// The titles starting with the prefix sort between the prefix
+		// and the prefix followed by the highest code point.
+		query = query.
+			Where("title", ">=", filter.TitlePrefix).
+			Where("title", "<", filter.TitlePrefix+"\U0010FFFF")
+	}
+	if filter.Status != "" {
+		query = query.Where("status", "==", string(filter.Status))
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency", "==", string(filter.Currency))
+	}
+	if filter.PriceMin != nil {
+		query = query.Where("price", ">=", *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		query = query.Where("price", "<=", *filter.PriceMax)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("dateCreated", ">", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("dateCreated", "<", filter.CreatedBefore)
+	}
+
+	_, desc := filter.Sort.Field()
+	dir := firestore.Asc
+	if desc {
+		dir = firestore.Desc
+	}
+
+	orders := filterOrders(filter)
+	for _, f := range orders {
+		query = query.OrderBy(f, dir)
+	}
+
+	return query, orders
+}
+
+// filterOrders returns the fields the query of the filter is ordered by, see filterQuery.
+func filterOrders(filter tixer.Filter) []string {
+	ranges := map[string]bool{
+		"title":       filter.TitlePrefix != "",
+		"price":       filter.PriceMin != nil || filter.PriceMax != nil,
+		"dateCreated": !filter.CreatedAfter.IsZero() || !filter.CreatedBefore.IsZero(),
+	}
+
+	field, _ := filter.Sort.Field()
+	orders := []string{field}
+	// Firestore orders by the other range fields in lexicographic order.
+	for _, f := range []string{"dateCreated", "price", "title"} {
+		if ranges[f] && f != field {
+			orders = append(orders, f)
+		}
+	}
+
+	return append(orders, firestore.DocumentID)
+}
+
+// cursorValues returns the values of the cursor for the ordered fields.
+func cursorValues(c tixer.Cursor, orders []string) []any {
+	values := make([]any, 0, len(orders))
+	for _, f := range orders {
+		switch f {
+		case "title":
+			values = append(values, c.Title)
+		case "price":
+			values = append(values, c.Price)
+		case "dateCreated":
+			values = append(values, c.DateCreated)
+		case firestore.DocumentID:
+			values = append(values, c.ID.String())
+		}
+	}
+
+	return values
+}
+
+// ticketUpdates returns the Firestore updates applying the fields provided by the update.
+func ticketUpdates(update tixer.TicketUpdate) []firestore.Update {
+	updates := []firestore.Update{
+		{Path: "dateUpdated", Value: firestore.ServerTimestamp},
+		{Path: "version", Value: firestore.Increment(1)},
+	}
+	if update.EventID != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "eventId",
+			Value: *update.EventID,
+		})
+	}
+	if update.Title != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "title",
+			Value: *update.Title,
+		})
+	}
+	if update.Description != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "description",
+			Value: *update.Description,
+		})
+	}
+	if update.Venue != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "venue",
+			Value: *update.Venue,
+		})
+	}
+	if update.StartsAt != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "startsAt",
+			Value: timeValue(*update.StartsAt),
+		})
+	}
+	if update.Section != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "section",
+			Value: *update.Section,
+		})
+	}
+	if update.Row != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "row",
+			Value: *update.Row,
+		})
+	}
+	if update.Seat != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "seat",
+			Value: *update.Seat,
+		})
+	}
+	if update.Price != nil {
+		updates = append(updates,
+			firestore.Update{
+				Path:  "price",
+				Value: update.Price.Amount,
+			},
+			firestore.Update{
+				Path:  "currency",
+				Value: string(update.Price.Currency),
+			},
+		)
+	}
+
+	return updates
+}
+
+// replaceUpdates returns the Firestore updates replacing the editable fields of the ticket.
+func replaceUpdates(ticket tixer.Ticket) []firestore.Update {
+	return []firestore.Update{
+		{Path: "eventId", Value: ticket.EventID},
+		{Path: "title", Value: ticket.Title},
+		{Path: "description", Value: ticket.Description},
+		{Path: "venue", Value: ticket.Venue},
+		{Path: "startsAt", Value: timeValue(ticket.StartsAt)},
+		{Path: "section", Value: ticket.Section},
+		{Path: "row", Value: ticket.Row},
+		{Path: "seat", Value: ticket.Seat},
+		{Path: "price", Value: ticket.Price.Amount},
+		{Path: "currency", Value: string(ticket.Price.Currency)},
+		{Path: "dateUpdated", Value: firestore.ServerTimestamp},
+		{Path: "version", Value: firestore.Increment(1)},
+	}
+}
+
+// timeValue returns the Firestore value of an optional date,
+// which deletes the field when the date is not set.
+func timeValue(t time.Time) any {
+	if t.IsZero() {
+		return firestore.Delete
+	}
+
+	return t
+}
+
 func (s *Storer) readTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
 	ticketDoc, err := s.client.Collection(s.collection).Doc(id.String()).Get(ctx)
 	if err != nil {
@@ -215,43 +712,131 @@ func (s *Storer) readTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticke
 
 type (
 	// persistedTicket represents a stored ticket in Firestore.
+	//
+	// The fields describing the event and the seating were added later,
+	// so they are missing from older documents, which decode them as zero values.
+	//
+	// The price is stored as an integer amount of minor units of the currency,
+	// along with the currency. The documents written before were storing it
+	// as a floating point amount of major units, which is converted on read
+	// until MigratePrices has rewritten them.
+	//
+	// The status is missing from the documents written before it existed,
+	// whose tickets are on sale, until MigrateStatuses has set it.
+	//
+	// The deletion date is only set for the deleted tickets, see deletedTickets.
+	//
+	// The capacity is missing from the documents written before it existed,
+	// whose tickets are a single unit. The holds are only set for the tickets
+	// which are held, see storedHolds.
 	persistedTicket struct {
-		ID          string    `firestore:"id"`
-		Title       string    `firestore:"title"`
-		Price       float64   `firestore:"price"`
-		DateCreated time.Time `firestore:"dateCreated"`
-		DateUpdated time.Time `firestore:"dateUpdated"`
+		ID          tixer.TicketID `firestore:"-"`
+		EventID     string         `firestore:"eventId"`
+		Title       string         `firestore:"title"`
+		Description string         `firestore:"description"`
+		Venue       string         `firestore:"venue"`
+		StartsAt    time.Time      `firestore:"startsAt"`
+		Section     string         `firestore:"section"`
+		Row         string         `firestore:"row"`
+		Seat        string         `firestore:"seat"`
+		Price       any            `firestore:"price"`
+		Currency    string         `firestore:"currency"`
+		Status      string         `firestore:"status"`
+		Capacity    int            `firestore:"capacity"`
+		Sold        int            `firestore:"sold"`
+		Holds       map[string]any `firestore:"holds"`
+		Version     int            `firestore:"version"`
+		DateCreated time.Time      `firestore:"dateCreated"`
+		DateUpdated time.Time      `firestore:"dateUpdated"`
+		DateDeleted time.Time      `firestore:"dateDeleted"`
 	}
 
-	// counter represents the total tickets counter.
+	// persistedCounter represents the legacy tickets counter, see counter.migrate.
 	persistedCounter struct {
 		TotalTickets int `firestore:"totalTickets"`
 	}
 
 	// createTicket contains the data needed to create a Ticket in Firestore.
 	createTicket struct {
+		EventID     string    `firestore:"eventId,omitempty"`
 		Title       string    `firestore:"title"`
-		Price       float64   `firestore:"price"`
+		Description string    `firestore:"description,omitempty"`
+		Venue       string    `firestore:"venue,omitempty"`
+		StartsAt    time.Time `firestore:"startsAt,omitempty"`
+		Section     string    `firestore:"section,omitempty"`
+		Row         string    `firestore:"row,omitempty"`
+		Seat        string    `firestore:"seat,omitempty"`
+		Price       int64     `firestore:"price"`
+		Currency    string    `firestore:"currency"`
+		Status      string    `firestore:"status"`
+		Capacity    int       `firestore:"capacity"`
+		Version     int       `firestore:"version"`
 		DateCreated time.Time `firestore:"dateCreated,serverTimestamp"`
 	}
 )
 
+func newCreateTicket(t tixer.Ticket) createTicket {
+	return createTicket{
+		EventID:     t.EventID,
+		Title:       t.Title,
+		Description: t.Description,
+		Venue:       t.Venue,
+		StartsAt:    t.StartsAt,
+		Section:     t.Section,
+		Row:         t.Row,
+		Seat:        t.Seat,
+		Price:       t.Price.Amount,
+		Currency:    string(t.Price.Currency),
+		Status:      string(t.Status.OrDefault()),
+		Capacity:    t.CapacityOrDefault(),
+		Version:     1,
+	}
+}
+
 func toDomainTicket(t persistedTicket) tixer.Ticket {
 	return tixer.Ticket{
-		ID:          tixer.TicketID(uuid.MustParse(t.ID)),
+		ID:          t.ID,
+		EventID:     t.EventID,
 		Title:       t.Title,
-		Price:       t.Price,
+		Description: t.Description,
+		Venue:       t.Venue,
+		StartsAt:    t.StartsAt,
+		Section:     t.Section,
+		Row:         t.Row,
+		Seat:        t.Seat,
+		Price:       storedPrice(t.Price, tixer.Currency(t.Currency)),
+		Status:      tixer.TicketStatus(t.Status).OrDefault(),
+		Capacity:    tixer.Ticket{Capacity: t.Capacity}.CapacityOrDefault(),
+		Sold:        t.Sold,
+		Held:        tixer.HeldUnits(storedHolds(t.ID, t.Holds)),
+		Version:     t.Version,
 		DateCreated: t.DateCreated,
 		DateUpdated: t.DateUpdated,
+		DateDeleted: t.DateDeleted,
 	}
 }
 
+// docToPersistedTicket decodes a ticket document.
+//
+// It returns ErrMalformedTicket for a document that is not a ticket,
+// such as one added from the console, whose ID is not a ticket ID
+// or whose fields cannot be decoded.
 func docToPersistedTicket(doc *firestore.DocumentSnapshot) (persistedTicket, error) {
 	var tck persistedTicket
+
+	id, err := uuid.Parse(doc.Ref.ID)
+	if err != nil {
+		return tck, fmt.Errorf("document %q: %w", doc.Ref.ID, ErrMalformedTicket)
+	}
 	if err := doc.DataTo(&tck); err != nil {
-		return tck, err
+		return tck, fmt.Errorf("document %q: %v: %w", doc.Ref.ID, err, ErrMalformedTicket)
+	}
+	switch tck.Price.(type) {
+	case int64, float64:
+	default:
+		return tck, fmt.Errorf("document %q: price %v: %w", doc.Ref.ID, tck.Price, ErrMalformedTicket)
 	}
-	tck.ID = doc.Ref.ID
+	tck.ID = tixer.TicketID(id)
 
 	return tck, nil
 }
@@ -0,0 +1,250 @@
+package gcfirestore_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/gcfirestore"
+	"github.com/mroobert/tixer-tickets/tixertest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const counterDocID = "--counter--"
+
+// TestStorer runs the conformance suite against the Firestore emulator.
+//
+// The emulator address is read from FIRESTORE_EMULATOR_HOST, the same
+// variable the Firestore client uses, and the test is skipped when it is not set.
+func TestStorer(t *testing.T) {
+	client := newEmulatorClient(t)
+
+	tixertest.RunTicketServiceSuite(t, func(t *testing.T) tixer.TicketService {
+		svc, _, _ := newStorer(client)
+		return svc
+	})
+}
+
+// TestStorerReservations runs the reservations conformance suite against the emulator.
+func TestStorerReservations(t *testing.T) {
+	client := newEmulatorClient(t)
+
+	tixertest.RunReservationServiceSuite(t, func(t *testing.T) (tixer.TicketService, tixer.ReservationService) {
+		svc, _, _ := newStorer(client)
+		return svc, svc
+	})
+}
+
+// TestStorerMigrateCounter checks that the count held by a counter kept in
+// the tickets collection, sharded or not, is kept through the migration.
+func TestStorerMigrateCounter(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+
+	svc, collection, _ := newStorer(client)
+
+	legacy := client.Collection(collection).Doc(counterDocID)
+	if _, err := legacy.Set(ctx, map[string]any{"totalTickets": 4}); err != nil {
+		t.Fatalf("could not create the legacy counter document: %v", err)
+	}
+	if _, err := legacy.Collection("shards").Doc("0").Set(ctx, map[string]any{"count": 1}); err != nil {
+		t.Fatalf("could not create the legacy counter shard: %v", err)
+	}
+
+	err := svc.CreateTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 15000, Currency: "EUR"}})
+	if err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+
+	// Migrating twice must not count the legacy total twice.
+	for i := 0; i < 2; i++ {
+		if err := svc.MigrateCounter(ctx); err != nil {
+			t.Fatalf("MigrateCounter: %v", err)
+		}
+
+		tt, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 10})
+		if err != nil {
+			t.Fatalf("ReadTickets: %v", err)
+		}
+		if met.Total != 6 || len(tt) != 1 {
+			t.Fatalf("Got %d tickets out of %d after %d migrations, want 1 out of 6", len(tt), met.Total, i+1)
+		}
+	}
+
+	if _, err := legacy.Get(ctx); status.Code(err) != codes.NotFound {
+		t.Errorf("Got error %v, want the legacy counter document deleted", err)
+	}
+}
+
+// TestStorerRecount checks that a drift of the counter is reported,
+// and only fixed when asked to.
+func TestStorerRecount(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+
+	svc, _, stats := newStorer(client)
+	for i := 0; i < 2; i++ {
+		err := svc.CreateTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 15000, Currency: "EUR"}})
+		if err != nil {
+			t.Fatalf("CreateTicket: %v", err)
+		}
+	}
+
+	// A manual edit of the counter, which makes it drift by 3.
+	_, err := client.Collection(stats).Doc(counterDocID).Collection("shards").Doc("99").Set(ctx, map[string]any{
+		"count": 3,
+	})
+	if err != nil {
+		t.Fatalf("could not edit the counter: %v", err)
+	}
+
+	for _, apply := range []bool{false, true} {
+		drift, err := svc.Recount(ctx, apply)
+		if err != nil {
+			t.Fatalf("Recount: %v", err)
+		}
+		if drift.Counted != 2 || drift.Total != 5 || drift.Diff() != -3 {
+			t.Errorf("Got drift %+v with apply=%t, want 2 tickets counted as 5", drift, apply)
+		}
+	}
+
+	drift, err := svc.Recount(ctx, false)
+	if err != nil {
+		t.Fatalf("Recount: %v", err)
+	}
+	if drift.Diff() != 0 {
+		t.Errorf("Got drift %+v, want the counter fixed", drift)
+	}
+}
+
+// TestStorerSkipsMalformedDocuments checks that a document of the tickets
+// collection that is not a ticket is neither listed nor counted.
+func TestStorerSkipsMalformedDocuments(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+
+	svc, collection, _ := newStorer(client)
+	err := svc.CreateTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 15000, Currency: "EUR"}})
+	if err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+
+	_, err = client.Collection(collection).Doc("not-a-ticket").Set(ctx, map[string]any{
+		"title":       "Concert",
+		"dateCreated": time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("could not create the malformed document: %v", err)
+	}
+
+	tt, _, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	if len(tt) != 1 {
+		t.Errorf("Got %d tickets, want the malformed document skipped", len(tt))
+	}
+
+	drift, err := svc.Recount(ctx, false)
+	if err != nil {
+		t.Fatalf("Recount: %v", err)
+	}
+	if drift.Counted != 1 || drift.Skipped != 1 {
+		t.Errorf("Got drift %+v, want 1 ticket counted and 1 document skipped", drift)
+	}
+}
+
+// TestStorerDeletesAMaxSizeBatch checks that a batch of tixer.MaxDeleteBatchSize
+// tickets is deleted within the writes of a single transaction.
+func TestStorerDeletesAMaxSizeBatch(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+
+	svc, _, _ := newStorer(client)
+	tt := make([]tixer.Ticket, tixer.MaxDeleteBatchSize)
+	ids := make([]tixer.TicketID, 0, len(tt))
+	for i := range tt {
+		tt[i] = tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 15000, Currency: "EUR"}}
+		ids = append(ids, tt[i].ID)
+	}
+	if err := svc.CreateTickets(ctx, tt); err != nil {
+		t.Fatalf("CreateTickets: %v", err)
+	}
+
+	results, err := svc.DeleteTickets(ctx, ids)
+	if err != nil {
+		t.Fatalf("DeleteTickets: %v", err)
+	}
+	for i, err := range results {
+		if err != nil {
+			t.Fatalf("Got error %v for ticket %d, want it deleted", err, i)
+		}
+	}
+
+	_, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 1})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	if met.Total != 0 {
+		t.Errorf("Got total %d, want 0", met.Total)
+	}
+}
+
+// TestStorerAcquireLease checks that a lease is only held by one holder
+// until it expires, and can be renewed by its holder.
+func TestStorerAcquireLease(t *testing.T) {
+	client := newEmulatorClient(t)
+	ctx := context.Background()
+
+	svc, _, _ := newStorer(client)
+	steps := []struct {
+		holder string
+		ttl    time.Duration
+		want   bool
+	}{
+		{"first", time.Hour, true},
+		{"second", time.Hour, false},
+		{"first", -time.Second, true},
+		{"second", time.Hour, true},
+		{"first", time.Hour, false},
+	}
+	for i, step := range steps {
+		acquired, err := svc.AcquireLease(ctx, "recount", step.holder, step.ttl)
+		if err != nil {
+			t.Fatalf("AcquireLease: %v", err)
+		}
+		if acquired != step.want {
+			t.Errorf("Got acquired=%t for %s at step %d, want %t", acquired, step.holder, i, step.want)
+		}
+	}
+}
+
+// newStorer creates a storer over collections of its own, so that tests do not
+// share tickets, and returns the names of its tickets and stats collections.
+func newStorer(client *firestore.Client) (*gcfirestore.Storer, string, string) {
+	collection := "tickets-" + uuid.NewString()
+	stats := "stats-" + uuid.NewString()
+
+	return gcfirestore.NewStorer(client, collection, stats, counterDocID, 3), collection, stats
+}
+
+func newEmulatorClient(t *testing.T) *firestore.Client {
+	t.Helper()
+
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	client, err := firestore.NewClient(context.Background(), "tixer-test")
+	if err != nil {
+		t.Fatalf("could not create the firestore client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the payload of the pagination cursors handed out to clients.
+//
+// It holds the sort key values of the cursor ticket, so that a page can be read
+// without looking the ticket up, and the scope it was issued for, such as the
+// sort of the list, so that it cannot be replayed against another list.
+type cursorToken struct {
+	Scope       string    `json:"s"`
+	ID          uuid.UUID `json:"i"`
+	DateCreated time.Time `json:"d"`
+	Price       int64     `json:"p"`
+	Title       string    `json:"t"`
+	Score       float64   `json:"r,omitempty"`
+}
+
+// encodeCursor returns the opaque token of the cursor:
+// the base64 encoded payload and its HMAC-SHA256 signature, joined by a dot.
+func encodeCursor(secret []byte, c *tixer.Cursor, scope string) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(cursorToken{
+		Scope:       scope,
+		ID:          uuid.UUID(c.ID),
+		DateCreated: c.DateCreated,
+		Price:       c.Price,
+		Title:       c.Title,
+		Score:       c.Score,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(signCursor(secret, payload)), nil
+}
+
+// decodeCursor verifies the signature of the token and returns its cursor.
+// It returns nil when the token is empty.
+//
+// A token that was not signed with secret, or that was issued for another scope,
+// is rejected with ErrInvalidCursor.
+func decodeCursor(secret []byte, token string, scope string) (*tixer.Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(encSig)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !hmac.Equal(sig, signCursor(secret, payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var tok cursorToken
+	if err := json.Unmarshal(payload, &tok); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if tok.Scope != scope {
+		return nil, ErrInvalidCursor
+	}
+
+	return &tixer.Cursor{
+		ID:          tixer.TicketID(tok.ID),
+		Title:       tok.Title,
+		Price:       tok.Price,
+		DateCreated: tok.DateCreated,
+		Score:       tok.Score,
+	}, nil
+}
+
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
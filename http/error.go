@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mroobert/tixer-pkgs/web"
+	"golang.org/x/exp/slog"
+)
+
+// preconditionFailedResponse method will be used to send a 412 Precondition Failed.
+func preconditionFailedResponse(log *slog.Logger, w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since it was last read, please read it again"
+	errorResponse(log, w, r, http.StatusPreconditionFailed, message)
+}
+
+// conflictResponse method will be used to send a 409 Conflict.
+func conflictResponse(log *slog.Logger, w http.ResponseWriter, r *http.Request, message string) {
+	errorResponse(log, w, r, http.StatusConflict, message)
+}
+
+// errorResponse method is a generic helper for sending JSON-formatted error
+// messages to the client with a given status code.
+//
+// It mirrors the helper of the web package for the responses that package does not provide.
+func errorResponse(log *slog.Logger, w http.ResponseWriter, r *http.Request, status int, message any) {
+	err := web.WriteJSON(w, status, web.Envelope{"error": message}, nil)
+	if err != nil {
+		log.Error("internal error", err, "request_method", r.Method, "request_url", r.URL.String())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
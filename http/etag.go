@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// ticketETag returns the entity tag of a ticket, derived from its version.
+func ticketETag(tck tixer.Ticket) string {
+	return strconv.Quote(strconv.Itoa(tck.Version))
+}
+
+// expectedVersion returns the version a ticket must have for the request
+// to be applied, based on the If-Match header.
+//
+// It returns tixer.AnyVersion when the header is absent or is "*".
+// When the header lists several entity tags, the ticket is read to find out
+// which one of them is current. ErrVersionConflict is returned when none can match.
+func (s *Server) expectedVersion(ctx context.Context, r *http.Request, id tixer.TicketID) (int, error) {
+	return expectedVersionOf(ctx, r, id, s.TicketService.ReadTicket)
+}
+
+// expectedVersionOf returns the expected version of a ticket the same way
+// expectedVersion does, reading the ticket with read when it is needed,
+// such as tixer.TicketService.ReadDeletedTicket for a deleted ticket.
+func expectedVersionOf(ctx context.Context, r *http.Request, id tixer.TicketID, read func(context.Context, tixer.TicketID) (tixer.Ticket, error)) (int, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return tixer.AnyVersion, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return tixer.AnyVersion, nil
+		}
+
+		// Weak entity tags never match, as If-Match uses the strong comparison.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		v, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err != nil || v <= 0 {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, tixer.ErrVersionConflict
+	case 1:
+		return versions[0], nil
+	}
+
+	tck, err := read(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range versions {
+		if v == tck.Version {
+			return v, nil
+		}
+	}
+
+	return 0, tixer.ErrVersionConflict
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+const (
+	// idempotencyKeyMaxLength is the maximum length of an Idempotency-Key header.
+	idempotencyKeyMaxLength = 255
+
+	// idempotencyLockTimeout is how long a key stays reserved by a request that
+	// did not complete, for example because the instance crashed while processing it.
+	idempotencyLockTimeout = time.Minute
+
+	// idempotencyStoreTimeout bounds the calls made to store the outcome of a request,
+	// which are not bound to the request context so that they are not cancelled with it.
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// idempotent makes the handler honor the Idempotency-Key header.
+//
+// The first request made with a key is processed and its response is stored
+// for IdempotencyTTL. Retries with the same key and the same request replay
+// that response, while reusing the key for a different request is rejected.
+// Server errors are not stored, so the request can be retried.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || s.IdempotencyService == nil {
+			next(w, r)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLength {
+			web.BadRequestResponse(s.Logger, w, r, errors.New("idempotency key must not be longer than 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			web.BadRequestResponse(s.Logger, w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec, reserved, err := s.IdempotencyService.ReserveKey(r.Context(), tixer.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: requestFingerprint(r, body),
+			DateExpires: time.Now().Add(idempotencyLockTimeout),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, tixer.ErrIdempotencyKeyReused):
+				errorResponse(s.Logger, w, r, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, tixer.ErrIdempotencyKeyInProgress):
+				conflictResponse(s.Logger, w, r, err.Error())
+			default:
+				web.ServerErrorResponse(s.Logger, w, r, err)
+			}
+
+			return
+		}
+
+		if !reserved {
+			replayResponse(w, rec.Response)
+			return
+		}
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+
+		if rw.status >= http.StatusInternalServerError {
+			if err := s.IdempotencyService.ReleaseKey(ctx, rec); err != nil {
+				s.Logger.Error("could not release the idempotency key", err, "key", key)
+			}
+			return
+		}
+
+		completed := rec
+		completed.Response = &tixer.IdempotentResponse{
+			StatusCode: rw.status,
+			Header:     w.Header().Clone(),
+			Body:       rw.body.Bytes(),
+		}
+		completed.DateExpires = time.Now().Add(s.IdempotencyTTL)
+
+		if err := s.IdempotencyService.CompleteKey(ctx, rec, completed); err != nil {
+			s.Logger.Error("could not store the idempotent response", err, "key", key)
+		}
+	}
+}
+
+// requestFingerprint identifies a request by its method, its path and its body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayResponse writes a stored response, flagging it as replayed.
+func replayResponse(w http.ResponseWriter, resp *tixer.IdempotentResponse) {
+	for key, value := range resp.Header {
+		w.Header()[key] = value
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// responseRecorder writes a response while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
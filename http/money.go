@@ -0,0 +1,54 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// amount is a decimal amount of money, sent as a JSON string such as "12.50".
+//
+// JSON numbers are accepted as well, as the prices were sent as numbers before
+// they were exact. Their text is parsed the same way as a string, so that
+// no precision is lost to floating point numbers.
+type amount string
+
+func (a *amount) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = amount(s)
+
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*a = amount(n)
+
+	return nil
+}
+
+// money returns the amount in the currency. An amount which is not a decimal
+// number with at most the decimals of the currency is reported to the validator
+// for the key, and an empty amount is left for the validation of the ticket.
+func (a amount) money(currency tixer.Currency, key string, vld *validate.Validator) tixer.Money {
+	if a == "" {
+		return tixer.Money{Currency: currency}
+	}
+
+	m, err := tixer.ParseMoney(string(a), currency)
+	if err != nil {
+		vld.AddError(key, fmt.Sprintf("must be a decimal amount with at most %d decimals", currency.MinorUnits()))
+		return tixer.Money{Currency: currency}
+	}
+
+	return m
+}
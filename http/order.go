@@ -0,0 +1,212 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+func (s *Server) registerOrdersRoutesV1(router *httprouter.Router, customMethods *customMethodRouter) {
+	router.HandlerFunc(http.MethodPost, "/v1/orders", s.idempotent(s.handleCreateOrder))
+
+	router.HandlerFunc(http.MethodGet, "/v1/orders/:id", s.handleReadOrder)
+
+	customMethods.HandlerFunc(http.MethodPost, "/v1/orders/:id", "cancel", s.handleCancelOrder)
+}
+
+// handleCreateOrder creates a pending order, holding the units of its tickets
+// for OrderTTL.
+//
+// When any ticket cannot be held the order is not created: a ticket which does not
+// exist or is priced in another currency is rejected with a 422 Unprocessable Entity,
+// and a ticket which is not on sale or has not enough units left with a 409 Conflict.
+func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	var input createOrder
+	err := web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	vld := validate.NewValidator()
+	order := input.order(tixer.NewOrderID(), vld)
+	if order.Validate(vld); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	order, err = s.OrderService.CreateOrder(r.Context(), order, s.OrderTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrTicketNotFound), errors.Is(err, tixer.ErrCurrencyMismatch):
+			errorResponse(s.Logger, w, r, http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, tixer.ErrTicketNotOnSale), errors.Is(err, tixer.ErrSoldOut), errors.Is(err, tixer.ErrAlreadyHeld):
+			conflictResponse(s.Logger, w, r, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/orders/%s", order.ID))
+
+	err = web.WriteJSON(w, http.StatusCreated, web.Envelope{"order": mapOrderToResponse(order)}, headers)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+func (s *Server) handleReadOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	order, err := s.OrderService.ReadOrder(r.Context(), tixer.OrderID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"order": mapOrderToResponse(order)}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// handleCancelOrder cancels a pending order, releasing its tickets.
+// An order which is not pending is rejected with a 409 Conflict.
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	order, err := s.OrderService.CancelOrder(r.Context(), tixer.OrderID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrInvalidOrderTransition):
+			conflictResponse(s.Logger, w, r, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"order": mapOrderToResponse(order)}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+type (
+	// createOrder contains the information needed to create a new Order.
+	// The prices are not provided, they are the prices of the tickets.
+	createOrder struct {
+		Items []createOrderItem `json:"items"`
+	}
+
+	// createOrderItem contains the information needed to add a line item to a new Order.
+	createOrderItem struct {
+		TicketID string `json:"ticket_id"`
+		Quantity int    `json:"quantity"`
+	}
+)
+
+type (
+	// orderResponse contains the information about an Order that we want to
+	// return to clients. The amounts are in the currency of the order.
+	//
+	// DateExpires is only set for the pending orders, whose tickets are held until then,
+	// PaymentID for the orders which were paid, and Refunded and Refunds for the orders
+	// which were refunded, in full or in part.
+	orderResponse struct {
+		ID          string              `json:"id"`
+		Items       []orderItemResponse `json:"items"`
+		Total       string              `json:"total"`
+		Currency    string              `json:"currency"`
+		Status      string              `json:"status"`
+		PaymentID   string              `json:"payment_id,omitempty"`
+		Refunded    string              `json:"refunded,omitempty"`
+		Refunds     []refundResponse    `json:"refunds,omitempty"`
+		Version     int                 `json:"version"`
+		DateCreated time.Time           `json:"date_created"`
+		DateExpires *time.Time          `json:"date_expires,omitempty"`
+	}
+
+	// orderItemResponse contains the information about a line item of an Order.
+	orderItemResponse struct {
+		TicketID string `json:"ticket_id"`
+		Quantity int    `json:"quantity"`
+		Price    string `json:"price"`
+	}
+)
+
+// order returns the order described by the input. A ticket ID which
+// is not a UUID is reported to the validator.
+func (input createOrder) order(id tixer.OrderID, vld *validate.Validator) tixer.Order {
+	items := make([]tixer.OrderItem, 0, len(input.Items))
+	for i, item := range input.Items {
+		ticketID, err := uuid.Parse(item.TicketID)
+		if err != nil {
+			vld.AddError(fmt.Sprintf("items.%d.ticket_id", i), "must be a valid UUID")
+		}
+
+		items = append(items, tixer.OrderItem{TicketID: tixer.TicketID(ticketID), Quantity: item.Quantity})
+	}
+
+	return tixer.Order{ID: id, Items: items}
+}
+
+func mapOrderToResponse(order tixer.Order) orderResponse {
+	items := make([]orderItemResponse, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, orderItemResponse{
+			TicketID: item.TicketID.String(),
+			Quantity: item.Quantity,
+			Price:    item.Price.Decimal(),
+		})
+	}
+
+	res := orderResponse{
+		ID:          order.ID.String(),
+		Items:       items,
+		Total:       order.Total.Decimal(),
+		Currency:    string(order.Total.Currency),
+		Status:      string(order.Status),
+		PaymentID:   string(order.PaymentID),
+		Version:     order.Version,
+		DateCreated: order.DateCreated,
+	}
+	if order.Status == tixer.OrderPending {
+		res.DateExpires = &order.DateExpires
+	}
+	if len(order.Refunds) > 0 {
+		res.Refunded = order.Refunded().Decimal()
+		for _, refund := range order.Refunds {
+			res.Refunds = append(res.Refunds, mapRefundToResponse(refund))
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,129 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateOrder_HoldsTheTicketsAndRespondsWithTheTotal(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	ticket := createTicket(t, srv, `{"title":"Festival","price":"80.00","currency":"EUR","capacity":5}`)
+	ticketID := strings.TrimPrefix(ticket, "/v1/tickets/")
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(fmt.Sprintf(`{"items":[{"ticket_id":%q,"quantity":3}]}`, ticketID))))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if location := rec.Header().Get("Location"); !strings.HasPrefix(location, "/v1/orders/") {
+		t.Errorf("Got location %q, want the order", location)
+	}
+
+	var body struct {
+		Order struct {
+			Total       string  `json:"total"`
+			Currency    string  `json:"currency"`
+			Status      string  `json:"status"`
+			DateExpires *string `json:"date_expires"`
+		} `json:"order"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if got := body.Order; got.Total != "240.00" || got.Currency != "EUR" || got.Status != "pending" || got.DateExpires == nil {
+		t.Errorf("Got order %+v, want a pending order of 240.00 EUR with an expiry date", got)
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"held":3`) {
+		t.Errorf("Got availability %s, want 3 tickets held", got)
+	}
+
+	// The 2 units left cannot be ordered by 3.
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(fmt.Sprintf(`{"items":[{"ticket_id":%q,"quantity":3}]}`, ticketID))))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestCreateOrder_RespondsWithValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	tests := []string{
+		`{"items":[]}`,
+		`{"items":[{"ticket_id":"not-a-uuid","quantity":1}]}`,
+		`{"items":[{"ticket_id":"5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11","quantity":0}]}`,
+		`{"items":[{"ticket_id":"5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11","quantity":1},{"ticket_id":"5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11","quantity":1}]}`,
+	}
+	for _, body := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body)))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Got status code %d for %s, want %d", rec.Code, body, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestCreateOrder_RespondsWithUnprocessableEntityForAMissingTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(`{"items":[{"ticket_id":"5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11","quantity":1}]}`)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCancelOrder_ReleasesTheTicketsOnce(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	ticket := createTicket(t, srv, `{"title":"Concert","price":"150.00","currency":"EUR"}`)
+	order := createOrder(t, srv, ticket, 1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":cancel", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), `"status":"cancelled"`) {
+		t.Errorf("Got order %s, want it cancelled", rec.Body)
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"available":1`) {
+		t.Errorf("Got availability %s, want the ticket available again", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":cancel", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Got status code %d cancelling twice, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestReadOrder_RespondsWithNotFoundForAMissingOrder(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/orders/5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// webhookMaxBytes is the maximum size of the payload of a payment webhook.
+const webhookMaxBytes = 1_048_576
+
+// reversalTimeout bounds the void or the refund of a payment whose order could not be
+// paid, which is not canceled along with the request.
+const reversalTimeout = 10 * time.Second
+
+func (s *Server) registerPaymentsRoutesV1(router *httprouter.Router, customMethods *customMethodRouter) {
+	customMethods.HandlerFunc(http.MethodPost, "/v1/orders/:id", "pay", s.idempotent(s.handlePayOrder))
+
+	router.HandlerFunc(http.MethodPost, "/v1/orders/:id/refunds", s.idempotent(s.handleRefundOrder))
+
+	router.HandlerFunc(http.MethodPost, "/v1/payments/webhook", s.handlePaymentWebhook)
+}
+
+// handlePayOrder pays a pending order: its total is authorized and captured by the
+// payment provider, then the units it holds are sold.
+//
+// An order which is not pending or expired is rejected with a 409 Conflict before
+// anything is charged, and a payment declined by the provider with a 402 Payment
+// Required. When the payment cannot be captured it is voided, and when the order
+// cannot be paid once it is captured it is refunded, see authorizeOrder for retries.
+func (s *Server) handlePayOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	order, err := s.OrderService.ReadOrder(r.Context(), tixer.OrderID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+	if err := order.CheckTransition(tixer.OrderPaid); err != nil {
+		conflictResponse(s.Logger, w, r, err.Error())
+		return
+	}
+	if order.Expired(time.Now()) {
+		conflictResponse(s.Logger, w, r, tixer.ErrOrderExpired.Error())
+		return
+	}
+
+	payment, err := s.authorizeOrder(r.Context(), order)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrPaymentDeclined):
+			errorResponse(s.Logger, w, r, http.StatusPaymentRequired, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	if payment.Status == tixer.PaymentAuthorized {
+		authorized := payment
+		payment, err = s.PaymentProvider.Capture(r.Context(), authorized.ID, order.Total)
+		if err != nil {
+			s.reversePayment(order.ID, authorized)
+			web.ServerErrorResponse(s.Logger, w, r, err)
+			return
+		}
+	}
+
+	paid, err := s.OrderService.PayOrder(r.Context(), order.ID, payment)
+	if err != nil {
+		s.reversePayment(order.ID, payment)
+
+		switch {
+		case isUnpayable(err):
+			conflictResponse(s.Logger, w, r, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"order": mapOrderToResponse(paid)}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// handlePaymentWebhook processes an event notified by the payment provider.
+//
+// The payload must be signed by the provider, and is rejected with a 400 Bad Request
+// otherwise. The events are processed once: the provider may deliver an event again,
+// which is keyed by its ID the same way a request is keyed by its Idempotency-Key, and
+// replays the response of the first delivery. Paying an order is idempotent for a given
+// payment as well, so the events are still processed once without an idempotency service.
+//
+// A captured payment pays its order, or is refunded when the order cannot be paid by it.
+// The other events, and the payments of orders which do not exist, are acknowledged and
+// ignored, so that the provider does not deliver them again.
+func (s *Server) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBytes))
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	event, err := s.PaymentProvider.VerifyWebhook(payload, r.Header)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrInvalidWebhookSignature):
+			web.BadRequestResponse(s.Logger, w, r, err)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Idempotency-Key", "payment-event:"+event.ID)
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+
+	s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		s.processPaymentEvent(w, r, event)
+	})(w, r)
+}
+
+// processPaymentEvent applies a verified payment event, see handlePaymentWebhook.
+func (s *Server) processPaymentEvent(w http.ResponseWriter, r *http.Request, event tixer.PaymentEvent) {
+	status := "ignored"
+	if event.Type == tixer.PaymentEventCaptured {
+		_, err := s.payOrder(r.Context(), event.Payment.OrderID, event.Payment)
+		switch {
+		case err == nil:
+			status = "processed"
+		case isUnpayable(err):
+			status = "refunded"
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			// The payment is not the payment of an order of the service.
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+			return
+		}
+	}
+
+	res := paymentEventResponse{
+		ID:     event.ID,
+		Type:   string(event.Type),
+		Status: status,
+	}
+
+	err := web.WriteJSON(w, http.StatusOK, web.Envelope{"event": res}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// authorizeOrder authorizes the total of the order with the payment provider.
+//
+// The authorization is keyed by the order, so that a retry gets the payment of the
+// attempt it retries instead of charging the buyer again, which may already be captured.
+// A payment which was voided or refunded since, as its attempt failed, keys the next one.
+func (s *Server) authorizeOrder(ctx context.Context, order tixer.Order) (tixer.Payment, error) {
+	key := "order:" + order.ID.String()
+	for {
+		payment, err := s.PaymentProvider.Authorize(ctx, order.ID, order.Total, key)
+		if err != nil {
+			return tixer.Payment{}, err
+		}
+		if payment.Status != tixer.PaymentVoided && payment.Status != tixer.PaymentRefunded {
+			return payment, nil
+		}
+
+		key = "order:" + order.ID.String() + ":after:" + string(payment.ID)
+	}
+}
+
+// reversePayment voids the payment of an order which could not be paid,
+// or refunds it once it is captured, so that the buyer is not charged for
+// tickets they do not get. A payment which cannot be reversed is logged.
+func (s *Server) reversePayment(id tixer.OrderID, payment tixer.Payment) {
+	ctx, cancel := context.WithTimeout(context.Background(), reversalTimeout)
+	defer cancel()
+
+	var err error
+	switch payment.Status {
+	case tixer.PaymentAuthorized:
+		_, err = s.PaymentProvider.Void(ctx, payment.ID)
+	case tixer.PaymentCaptured:
+		_, err = s.PaymentProvider.Refund(ctx, payment.ID, payment.Captured)
+	}
+	if err != nil {
+		s.Logger.Error("could not reverse the payment of an unpaid order", err, "order", id.String(), "payment", string(payment.ID))
+	}
+}
+
+// payOrder pays the order with a captured payment notified by the payment provider.
+//
+// When the order cannot be paid by the payment, such as once it expired or when it was
+// paid by another payment, the payment is refunded, see reversePayment. The other errors
+// leave it captured, so that the provider delivers the event again.
+// The error of OrderService.PayOrder is returned either way.
+func (s *Server) payOrder(ctx context.Context, id tixer.OrderID, payment tixer.Payment) (tixer.Order, error) {
+	order, err := s.OrderService.PayOrder(ctx, id, payment)
+	if err == nil {
+		return order, nil
+	}
+
+	if isUnpayable(err) && payment.Status == tixer.PaymentCaptured && !payment.Captured.IsZero() {
+		s.reversePayment(id, payment)
+	}
+
+	return tixer.Order{}, err
+}
+
+// isUnpayable reports whether the error of OrderService.PayOrder
+// means the order cannot be paid by the payment.
+func isUnpayable(err error) bool {
+	return errors.Is(err, tixer.ErrOrderExpired) ||
+		errors.Is(err, tixer.ErrInvalidOrderTransition) ||
+		errors.Is(err, tixer.ErrInvalidPaymentAmount) ||
+		errors.Is(err, tixer.ErrTicketNotOnSale) ||
+		errors.Is(err, tixer.ErrTicketNotFound)
+}
+
+// paymentEventResponse contains the outcome of the processing of a payment event,
+// which is "processed", "refunded" or "ignored".
+type paymentEventResponse struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,291 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+	tixerhttp "github.com/mroobert/tixer-tickets/http"
+	"github.com/mroobert/tixer-tickets/payment"
+)
+
+func TestPayOrder_SellsTheTickets(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	ticket, order := createPendingOrder(t, srv, "80.00", 2)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"status":"paid"`) || !strings.Contains(body, `"payment_id":"fake_pay_1"`) {
+		t.Errorf("Got order %s, want it paid by fake_pay_1", body)
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":2,"held":0`) {
+		t.Errorf("Got availability %s, want 2 tickets sold", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Got status code %d paying twice, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestPayOrder_RespondsWithPaymentRequiredWhenDeclined(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	_, order := createPendingOrder(t, srv, "10.02", 1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusPaymentRequired)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, order, nil))
+	if !strings.Contains(rec.Body.String(), `"status":"pending"`) {
+		t.Errorf("Got order %s, want it still pending", rec.Body)
+	}
+}
+
+func TestPayOrder_RefundsThePaymentWhenTheOrderCannotBePaid(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	orders := srv.OrderService
+	_, order := createPendingOrder(t, srv, "80.00", 1)
+
+	srv.OrderService = failingPayOrderService{orders}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	got, err := fake.Payment("fake_pay_1")
+	if err != nil {
+		t.Fatalf("Payment: %v", err)
+	}
+	if got.Status != tixer.PaymentRefunded {
+		t.Errorf("Got payment %s, want it refunded", got.Status)
+	}
+
+	// The retry is charged again, as the payment of the failed attempt was refunded.
+	srv.OrderService = orders
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"payment_id":"fake_pay_2"`) {
+		t.Errorf("Got status code %d and order %s on retry, want it paid by fake_pay_2", rec.Code, rec.Body)
+	}
+}
+
+func TestPayOrder_VoidsThePaymentWhenTheCaptureFails(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	srv.PaymentProvider = failingCaptureProvider{fake}
+	_, order := createPendingOrder(t, srv, "80.00", 1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	got, err := fake.Payment("fake_pay_1")
+	if err != nil {
+		t.Fatalf("Payment: %v", err)
+	}
+	if got.Status != tixer.PaymentVoided {
+		t.Errorf("Got payment %s, want it voided", got.Status)
+	}
+}
+
+func TestPayOrder_CapturesOncePerOrder(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	_, order := createPendingOrder(t, srv, "80.00", 1)
+
+	// An attempt captured the payment, but its response was lost before the order was paid.
+	id := strings.TrimPrefix(order, "/v1/orders/")
+	total := tixer.Money{Amount: 8_000, Currency: "EUR"}
+	authorized, err := fake.Authorize(context.Background(), tixer.OrderID(uuid.MustParse(id)), total, "order:"+id)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if _, err := fake.Capture(context.Background(), authorized.ID, total); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), fmt.Sprintf(`"payment_id":%q`, authorized.ID)) {
+		t.Errorf("Got status code %d and order %s, want it paid by %s", rec.Code, rec.Body, authorized.ID)
+	}
+	if _, err := fake.Payment("fake_pay_2"); !errors.Is(err, tixer.ErrPaymentNotFound) {
+		t.Errorf("Got error %v, want no second payment", err)
+	}
+}
+
+func TestPaymentWebhook_RejectsAnInvalidSignature(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+
+	payload, header, err := fake.Webhook(tixer.PaymentEvent{Type: tixer.PaymentEventCaptured})
+	if err != nil {
+		t.Fatalf("Webhook: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", bytes.NewReader(append(payload, ' ')))
+	req.Header = header
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPaymentWebhook_ProcessesAnEventOnce(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	_, order := createPendingOrder(t, srv, "80.00", 2)
+
+	captured := capturePayment(t, fake, order, 16_000)
+	payload, header, err := fake.Webhook(tixer.PaymentEvent{Type: tixer.PaymentEventCaptured, Payment: captured})
+	if err != nil {
+		t.Fatalf("Webhook: %v", err)
+	}
+
+	for i, replayed := range []string{"", "true"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", bytes.NewReader(payload))
+		req.Header = header
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Got status code %d on delivery %d, want %d: %s", rec.Code, i+1, http.StatusOK, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"status":"processed"`) {
+			t.Errorf("Got event %s on delivery %d, want it processed", rec.Body, i+1)
+		}
+		if got := rec.Header().Get("Idempotent-Replayed"); got != replayed {
+			t.Errorf("Got Idempotent-Replayed %q on delivery %d, want %q", got, i+1, replayed)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, order, nil))
+	if !strings.Contains(rec.Body.String(), fmt.Sprintf(`"payment_id":%q`, captured.ID)) {
+		t.Errorf("Got order %s, want it paid by %s", rec.Body, captured.ID)
+	}
+}
+
+func TestPaymentWebhook_RefundsThePaymentOfACancelledOrder(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	_, order := createPendingOrder(t, srv, "80.00", 1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":cancel", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d on cancel, want %d", rec.Code, http.StatusOK)
+	}
+
+	captured := capturePayment(t, fake, order, 8_000)
+	payload, header, err := fake.Webhook(tixer.PaymentEvent{Type: tixer.PaymentEventCaptured, Payment: captured})
+	if err != nil {
+		t.Fatalf("Webhook: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/webhook", bytes.NewReader(payload))
+	req.Header = header
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"refunded"`) {
+		t.Errorf("Got status code %d and event %s, want it refunded", rec.Code, rec.Body)
+	}
+
+	got, err := fake.Payment(captured.ID)
+	if err != nil {
+		t.Fatalf("Payment: %v", err)
+	}
+	if got.Status != tixer.PaymentRefunded {
+		t.Errorf("Got payment %s, want it refunded", got.Status)
+	}
+}
+
+// createPendingOrder creates a ticket at the given price in EUR,
+// and orders quantity units of it. It returns the locations of both.
+func createPendingOrder(t *testing.T, srv *tixerhttp.Server, price string, quantity int) (ticket, order string) {
+	t.Helper()
+
+	ticket = createTicket(t, srv, fmt.Sprintf(`{"title":"Concert","price":%q,"currency":"EUR","capacity":10}`, price))
+
+	return ticket, createOrder(t, srv, ticket, quantity)
+}
+
+// capturePayment authorizes and captures a payment of the amount in EUR cents
+// for the order at the given location, the way a buyer pays at the gateway.
+func capturePayment(t *testing.T, fake *payment.Fake, order string, amount int64) tixer.Payment {
+	t.Helper()
+
+	id, err := uuid.Parse(strings.TrimPrefix(order, "/v1/orders/"))
+	if err != nil {
+		t.Fatalf("could not parse the order ID: %v", err)
+	}
+	total := tixer.Money{Amount: amount, Currency: "EUR"}
+
+	authorized, err := fake.Authorize(context.Background(), tixer.OrderID(id), total, "checkout:"+id.String())
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	captured, err := fake.Capture(context.Background(), authorized.ID, total)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	return captured
+}
+
+// failingPayOrderService is an OrderService whose store fails to pay the orders.
+type failingPayOrderService struct {
+	tixer.OrderService
+}
+
+func (failingPayOrderService) PayOrder(ctx context.Context, id tixer.OrderID, payment tixer.Payment) (tixer.Order, error) {
+	return tixer.Order{}, errors.New("the store is unavailable")
+}
+
+// failingCaptureProvider is a PaymentProvider whose gateway fails to capture the payments.
+type failingCaptureProvider struct {
+	*payment.Fake
+}
+
+func (failingCaptureProvider) Capture(ctx context.Context, id tixer.PaymentID, amount tixer.Money) (tixer.Payment, error) {
+	return tixer.Payment{}, errors.New("the gateway is unavailable")
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// settleRefundTimeout bounds the confirmation or the cancellation of a refund once the
+// payment provider answered, which is not canceled along with the request.
+const settleRefundTimeout = 10 * time.Second
+
+// handleRefundOrder refunds a paid order, in full or in part, and releases
+// the refunded units of its tickets back to the inventory.
+//
+// A refund of the units of the tickets amounts to the sum of their prices unless its
+// amount is provided, and a refund of an amount alone releases no units. A refund of
+// neither refunds all the amount and the units which were not refunded yet. A refund
+// which exceeds what is left to refund of the order is rejected with a 422 Unprocessable
+// Entity, and an order which is not paid, or was refunded in full, with a 409 Conflict,
+// before anything is refunded by the payment provider.
+//
+// The refund is added to the ledger of the order pending before the payment provider is
+// asked for it, so that concurrent refunds cannot claim the same amount or units, see
+// OrderService.RefundOrder. It is confirmed once the provider refunded it, which releases
+// its units, or cancelled when the provider failed to.
+func (s *Server) handleRefundOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	var input refundOrder
+	err = web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	order, err := s.OrderService.ReadOrder(r.Context(), tixer.OrderID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+	if err := order.CheckTransition(tixer.OrderRefunded); err != nil {
+		conflictResponse(s.Logger, w, r, err.Error())
+		return
+	}
+
+	vld := validate.NewValidator()
+	refund, err := input.refund(order, vld)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+	if !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+	refund.ID = uuid.NewString()
+
+	order, err = s.OrderService.RefundOrder(r.Context(), order.ID, refund)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrOrderNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrInvalidOrderTransition):
+			conflictResponse(s.Logger, w, r, err.Error())
+		case errors.Is(err, tixer.ErrInvalidPaymentAmount), errors.Is(err, tixer.ErrInvalidQuantity), errors.Is(err, tixer.ErrCurrencyMismatch):
+			errorResponse(s.Logger, w, r, http.StatusUnprocessableEntity, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	refunded, err := s.PaymentProvider.Refund(r.Context(), order.PaymentID, refund.Amount)
+	if err != nil {
+		s.cancelRefund(tixer.OrderID(id), refund.ID)
+
+		switch {
+		case errors.Is(err, tixer.ErrInvalidPaymentAmount):
+			conflictResponse(s.Logger, w, r, err.Error())
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settleRefundTimeout)
+	defer cancel()
+
+	order, err = s.OrderService.ConfirmRefund(ctx, tixer.OrderID(id), refund.ID, refunded.ID)
+	if err != nil {
+		// The amount was given back to the buyer, but the refund is left pending: its
+		// units stay sold, and its amount cannot be claimed by another refund.
+		s.Logger.Error("could not confirm a refund made by the payment provider", err, "order", tixer.OrderID(id).String(), "refund", refund.ID, "payment_refund", refunded.ID)
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+
+	refund, err = order.FindRefund(refund.ID)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+	res := web.Envelope{
+		"refund": mapRefundToResponse(refund),
+		"order":  mapOrderToResponse(order),
+	}
+
+	err = web.WriteJSON(w, http.StatusCreated, res, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// cancelRefund cancels a pending refund of the order which the payment provider
+// failed to make, so that its amount and units can be refunded again.
+// A refund which cannot be cancelled is logged, and left pending.
+func (s *Server) cancelRefund(id tixer.OrderID, refundID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), settleRefundTimeout)
+	defer cancel()
+
+	if _, err := s.OrderService.CancelRefund(ctx, id, refundID); err != nil {
+		s.Logger.Error("could not cancel a refund the payment provider failed to make", err, "order", id.String(), "refund", refundID)
+	}
+}
+
+type (
+	// refundOrder contains the information needed to refund an Order,
+	// in full when neither the amount nor the items are provided.
+	refundOrder struct {
+		Amount *amount           `json:"amount"`
+		Items  []createOrderItem `json:"items"`
+	}
+
+	// refundResponse contains the information about a refund of an Order.
+	// The amounts are in the currency of the order.
+	refundResponse struct {
+		ID              string              `json:"id"`
+		Status          string              `json:"status"`
+		PaymentRefundID string              `json:"payment_refund_id,omitempty"`
+		Amount          string              `json:"amount"`
+		Items           []orderItemResponse `json:"items"`
+		DateCreated     time.Time           `json:"date_created"`
+	}
+)
+
+// refund returns the refund of the order described by the input. A ticket ID which
+// is not a UUID, a quantity out of range and an amount which cannot be parsed in the
+// currency of the order are reported to the validator.
+func (input refundOrder) refund(order tixer.Order, vld *validate.Validator) (tixer.OrderRefund, error) {
+	if input.Amount == nil && input.Items == nil {
+		amount, err := order.Refundable()
+		if err != nil {
+			return tixer.OrderRefund{}, err
+		}
+
+		return tixer.OrderRefund{Amount: amount, Items: order.RefundableItems()}, nil
+	}
+
+	var units []tixer.OrderItem
+	if input.Items != nil {
+		units = make([]tixer.OrderItem, 0, len(input.Items))
+		for i, item := range input.Items {
+			ticketID, err := uuid.Parse(item.TicketID)
+			if err != nil {
+				vld.AddError(fmt.Sprintf("items.%d.ticket_id", i), "must be a valid UUID")
+			}
+			vld.Check(item.Quantity > 0 && item.Quantity <= tixer.MaxOrderQuantity, fmt.Sprintf("items.%d.quantity", i), fmt.Sprintf("must be in the range [1, %d]", tixer.MaxOrderQuantity))
+
+			units = append(units, tixer.OrderItem{TicketID: tixer.TicketID(ticketID), Quantity: item.Quantity})
+		}
+	}
+
+	items, total, err := order.RefundItems(units)
+	if err != nil {
+		return tixer.OrderRefund{}, err
+	}
+	if input.Amount != nil {
+		total = input.Amount.money(order.Total.Currency, "amount", vld)
+	}
+	vld.Check(total.Amount > 0, "amount", "must be greater than zero")
+
+	return tixer.OrderRefund{Amount: total, Items: items}, nil
+}
+
+func mapRefundToResponse(refund tixer.OrderRefund) refundResponse {
+	items := make([]orderItemResponse, 0, len(refund.Items))
+	for _, item := range refund.Items {
+		items = append(items, orderItemResponse{
+			TicketID: item.TicketID.String(),
+			Quantity: item.Quantity,
+			Price:    item.Price.Decimal(),
+		})
+	}
+
+	return refundResponse{
+		ID:              refund.ID,
+		Status:          string(refund.Status),
+		PaymentRefundID: refund.PaymentRefundID,
+		Amount:          refund.Amount.Decimal(),
+		Items:           items,
+		DateCreated:     refund.DateCreated,
+	}
+}
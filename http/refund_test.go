@@ -0,0 +1,164 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+	tixerhttp "github.com/mroobert/tixer-tickets/http"
+	"github.com/mroobert/tixer-tickets/payment"
+)
+
+func TestRefundOrder_RefundsInPartThenInFull(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	ticket, order := createPaidOrder(t, srv, "80.00", 3)
+	ticketID := strings.TrimPrefix(ticket, "/v1/tickets/")
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(fmt.Sprintf(`{"items":[{"ticket_id":%q,"quantity":1}]}`, ticketID))))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"amount":"80.00"`) || !strings.Contains(body, `"refunded":"80.00"`) ||
+		!strings.Contains(body, `"status":"succeeded"`) || !strings.Contains(body, `"payment_refund_id":"fake_re_1"`) || !strings.Contains(body, `"status":"paid"`) {
+		t.Errorf("Got refund %s, want 80.00 refunded of a paid order", body)
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":2`) {
+		t.Errorf("Got availability %s, want 2 tickets sold once 1 is refunded", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"amount":"160.00"`) || !strings.Contains(body, `"status":"refunded"`) {
+		t.Errorf("Got refund %s, want the 160.00 left refunded", body)
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":0`) {
+		t.Errorf("Got availability %s, want no ticket sold once all are refunded", got)
+	}
+
+	got, err := fake.Payment("fake_pay_1")
+	if err != nil {
+		t.Fatalf("Payment: %v", err)
+	}
+	if got.Status != tixer.PaymentRefunded {
+		t.Errorf("Got payment %s, want it refunded", got.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Got status code %d refunding a refunded order, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRefundOrder_RespondsWithUnprocessableEntityForMoreThanRefundable(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	ticket, order := createPaidOrder(t, srv, "80.00", 1)
+	ticketID := strings.TrimPrefix(ticket, "/v1/tickets/")
+
+	tests := []string{
+		`{"amount":"80.01"}`,
+		`{"amount":"0"}`,
+		`{"amount":"1.234"}`,
+		fmt.Sprintf(`{"items":[{"ticket_id":%q,"quantity":2}]}`, ticketID),
+		`{"items":[{"ticket_id":"5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11","quantity":1}]}`,
+	}
+	for _, body := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(body)))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Got status code %d for %s, want %d", rec.Code, body, http.StatusUnprocessableEntity)
+		}
+	}
+
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":1`) {
+		t.Errorf("Got availability %s, want the ticket still sold", got)
+	}
+}
+
+func TestRefundOrder_RespondsWithConflictForAPendingOrder(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	_, order := createPendingOrder(t, srv, "80.00", 1)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(`{}`)))
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRefundOrder_CancelsTheRefundWhenTheProviderFails(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	fake := srv.PaymentProvider.(*payment.Fake)
+	ticket, order := createPaidOrder(t, srv, "80.00", 2)
+
+	srv.PaymentProvider = failingRefundProvider{fake}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":2`) {
+		t.Errorf("Got availability %s, want the tickets still sold", got)
+	}
+
+	// The failed refund leaves the amount and the units refundable.
+	srv.PaymentProvider = fake
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+"/refunds", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, `"status":"refunded"`) {
+		t.Errorf("Got refund %s, want the order refunded along with the failed refund in its ledger", body)
+	}
+	if got := readAvailability(t, srv, ticket); !strings.Contains(got, `"sold":0`) {
+		t.Errorf("Got availability %s, want no ticket sold once all are refunded", got)
+	}
+}
+
+// createPaidOrder creates an order of quantity units of a ticket at the given price
+// in EUR, see createPendingOrder, and pays it. It returns the locations of both.
+func createPaidOrder(t *testing.T, srv *tixerhttp.Server, price string, quantity int) (ticket, order string) {
+	t.Helper()
+
+	ticket, order = createPendingOrder(t, srv, price, quantity)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, order+":pay", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d on pay, want %d", rec.Code, http.StatusOK)
+	}
+
+	return ticket, order
+}
+
+// failingRefundProvider is a PaymentProvider whose gateway fails to refund the payments.
+type failingRefundProvider struct {
+	*payment.Fake
+}
+
+func (failingRefundProvider) Refund(ctx context.Context, id tixer.PaymentID, amount tixer.Money) (tixer.Refund, error) {
+	return tixer.Refund{}, errors.New("the gateway is unavailable")
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// readBool reads url boolean parameters, the same way the web package reads
+// the other parameter types.
+func readBool(qs url.Values, key string, defaultValue bool, vld *validate.Validator) bool {
+	v := qs.Get(key)
+
+	if v == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		vld.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readMoney reads url amount parameters, such as "12.50", in the currency.
+// It returns nil when the parameter is not provided, so that it can be
+// told apart from an explicit zero.
+func readMoney(qs url.Values, key string, currency tixer.Currency, vld *validate.Validator) *tixer.Money {
+	v := qs.Get(key)
+
+	if v == "" {
+		return nil
+	}
+
+	m := amount(v).money(currency, key, vld)
+	return &m
+}
+
+// readTime reads url time parameters, formatted as RFC 3339.
+func readTime(qs url.Values, key string, defaultValue time.Time, vld *validate.Validator) time.Time {
+	v := qs.Get(key)
+
+	if v == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		vld.AddError(key, "must be a RFC 3339 date")
+		return defaultValue
+	}
+
+	return t
+}
+
+// readPageInput reads the pagination parameters shared by the lists of tickets.
+func readPageInput(qs url.Values, vld *validate.Validator) pageInput {
+	return pageInput{
+		After:  web.ReadString(qs, "after", ""),
+		Before: web.ReadString(qs, "before", ""),
+		Limit:  web.ReadInt(qs, "limit", 10, vld),
+	}
+}
+
+// validatePageInput validates from a 'Presentation' perspective the pagination parameters.
+func validatePageInput(vld *validate.Validator, input pageInput) {
+	vld.Check(input.Limit > 0 && input.Limit <= 50, "limit", "must be in the interval [0, 50]")
+}
+
+// decodePageCursors verifies the cursors of the page, which must have been
+// issued for the same scope.
+func (s *Server) decodePageCursors(input pageInput, scope string) (after, before *tixer.Cursor, err error) {
+	after, err = decodeCursor(s.CursorSecret, input.After, scope)
+	if err != nil {
+		return nil, nil, fmt.Errorf("after: %w", err)
+	}
+	before, err = decodeCursor(s.CursorSecret, input.Before, scope)
+	if err != nil {
+		return nil, nil, fmt.Errorf("before: %w", err)
+	}
+
+	return after, before, nil
+}
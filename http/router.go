@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// customMethodRouter routes custom methods, such as "POST /v1/tickets:batchCreate"
+// or "POST /v1/tickets/:id:restore", which httprouter cannot route on its own
+// as it treats ':' as the start of a parameter.
+//
+// The verb is cut from the last path segment and the remaining path is routed
+// by a router dedicated to that verb, so path parameters work as usual.
+// Any other request is routed by the standard router.
+type customMethodRouter struct {
+	router *httprouter.Router
+	verbs  map[string]*httprouter.Router
+}
+
+func newCustomMethodRouter(router *httprouter.Router) *customMethodRouter {
+	return &customMethodRouter{
+		router: router,
+		verbs:  make(map[string]*httprouter.Router),
+	}
+}
+
+// HandlerFunc registers a handler for the verb of the custom method
+// on the given method and path.
+func (cr *customMethodRouter) HandlerFunc(method, path, verb string, handler http.HandlerFunc) {
+	router, ok := cr.verbs[verb]
+	if !ok {
+		router = httprouter.New()
+		cr.verbs[verb] = router
+	}
+
+	router.HandlerFunc(method, path, handler)
+}
+
+func (cr *customMethodRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i := strings.LastIndexByte(r.URL.Path, '/')
+	path, verb, ok := strings.Cut(r.URL.Path[i+1:], ":")
+	if !ok {
+		cr.router.ServeHTTP(w, r)
+		return
+	}
+
+	router, ok := cr.verbs[verb]
+	if !ok {
+		cr.router.ServeHTTP(w, r)
+		return
+	}
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = r.URL.Path[:i+1] + path
+	r2.URL.RawPath = ""
+	router.ServeHTTP(w, r2)
+}
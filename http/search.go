@@ -0,0 +1,114 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// searchScope is the scope of the cursors of the search, which cannot be
+// replayed against the lists of tickets.
+const searchScope = "search"
+
+// handleReadTicketOrSearch routes "GET /v1/tickets/search", which httprouter
+// cannot register next to "GET /v1/tickets/:id".
+func (s *Server) handleReadTicketOrSearch(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	if params.ByName("id") != "search" {
+		s.handleReadTicket(w, r)
+		return
+	}
+
+	if s.SearchService == nil {
+		web.NotFoundResponse(s.Logger, w, r)
+		return
+	}
+	s.handleSearchTickets(w, r)
+}
+
+// handleSearchTickets finds the tickets holding the words of the "q" parameter,
+// the most relevant first.
+func (s *Server) handleSearchTickets(w http.ResponseWriter, r *http.Request) {
+	vld := validate.NewValidator()
+
+	var input searchTickets
+	qs := r.URL.Query()
+	input.Query = web.ReadString(qs, "q", "")
+	input.pageInput = readPageInput(qs, vld)
+
+	if validateSearchTickets(vld, input); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	after, before, err := s.decodePageCursors(input.pageInput, searchScope)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	hits, met, err := s.SearchService.SearchTickets(r.Context(), tixer.SearchFilter{
+		Query:  input.Query,
+		After:  after,
+		Before: before,
+		Limit:  input.Limit,
+	})
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+
+	pagination, err := s.mapMetadataToResponse(r, searchScope, input.pageInput, met, len(hits))
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{
+		"hits":       mapHitListToResponse(hits),
+		"pagination": pagination,
+	}, nil)
+
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+type (
+	// searchTickets contains the information needed to search Tickets.
+	searchTickets struct {
+		pageInput
+		Query string `json:"q"`
+	}
+
+	// hitResponse contains the information about a Ticket found by a search.
+	hitResponse struct {
+		Ticket     ticketResponse    `json:"ticket"`
+		Score      float64           `json:"score"`
+		Highlights map[string]string `json:"highlights"`
+	}
+)
+
+// validateSearchTickets validates from a 'Presentation' perspective the information
+// provided for searching tickets.
+func validateSearchTickets(vld *validate.Validator, input searchTickets) {
+	validatePageInput(vld, input.pageInput)
+	vld.Check(input.Query != "", "q", "must be provided")
+	vld.Check(len(input.Query) <= 100, "q", "must not be longer than 100 characters")
+}
+
+func mapHitListToResponse(hits []tixer.SearchHit) []hitResponse {
+	slice := make([]hitResponse, 0, len(hits))
+	for _, hit := range hits {
+		slice = append(slice, hitResponse{
+			Ticket:     mapTicketToResponse(hit.Ticket),
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+
+	return slice
+}
@@ -0,0 +1,100 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearchTickets_RespondsWithTheRankedHits(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	for _, body := range []string{
+		`{"title":"Jazz concert","price":"30.00","currency":"EUR"}`,
+		`{"title":"Rock concert","price":"10.00","currency":"EUR"}`,
+		`{"title":"Opera","price":"20.00","currency":"EUR"}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets/search?q=rock+concert&limit=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Hits []struct {
+			Ticket struct {
+				Title string `json:"title"`
+			} `json:"ticket"`
+			Highlights map[string]string `json:"highlights"`
+		} `json:"hits"`
+		Pagination struct {
+			Total int    `json:"total"`
+			Next  string `json:"next"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if len(body.Hits) != 1 || body.Hits[0].Ticket.Title != "Rock concert" {
+		t.Fatalf("Got hits %+v, want the rock concert first", body.Hits)
+	}
+	if got, want := body.Hits[0].Highlights["title"], "<mark>Rock</mark> <mark>concert</mark>"; got != want {
+		t.Errorf("Got highlight %q, want %q", got, want)
+	}
+	if body.Pagination.Total != 2 {
+		t.Errorf("Got total %d, want 2", body.Pagination.Total)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, body.Pagination.Next, nil))
+	if !strings.Contains(rec.Body.String(), "Jazz concert") {
+		t.Errorf("Got %s, want the jazz concert on the next page", rec.Body)
+	}
+}
+
+func TestSearchTickets_RespondsWithValidationErrorsForAMissingQuery(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets/search", nil))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestSearchTickets_RespondsWithBadRequestForAListCursor(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"10.00","currency":"EUR"}`)))
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets", nil))
+	var body struct {
+		Pagination struct {
+			After string `json:"after"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets/search?q=concert&after="+body.Pagination.After, nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
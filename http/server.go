@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"net/http"
 	"time"
@@ -15,28 +16,61 @@ import (
 // used by this microservice so that dependent packages do not need to reference the "net/http"
 // package at all. This allows us to isolate all HTTP code to this "http" package.
 type Server struct {
-	router *httprouter.Router
-	server *http.Server
+	router        *httprouter.Router
+	customMethods *customMethodRouter
+	server        *http.Server
 
 	Addr            string
 	Logger          *slog.Logger
 	ShutdownTimeout time.Duration
+	IdempotencyTTL  time.Duration
+
+	// OrderTTL is how long a pending order holds its tickets.
+	OrderTTL time.Duration
+
+	// CursorSecret signs the pagination cursors. When it is not set, a random
+	// secret is used, so the cursors do not survive a restart and are not
+	// accepted by the other instances of the service.
+	CursorSecret []byte
 
 	// Services used by the various HTTP routes.
 
 	TicketService tixer.TicketService
+
+	// IdempotencyService is optional. Idempotency keys are ignored when it is not set.
+	IdempotencyService tixer.IdempotencyService
+
+	// SearchService is optional. The search route is not found when it is not set.
+	SearchService tixer.SearchService
+
+	// OrderService is optional. The orders routes are not attached when it is not set.
+	OrderService tixer.OrderService
+
+	// PaymentProvider is optional. The payments routes are not attached when it,
+	// or the OrderService, is not set.
+	PaymentProvider tixer.PaymentProvider
 }
 
 func NewServer(options ...func(*Server)) *Server {
 	srv := &Server{
-		server: &http.Server{},
-		router: httprouter.New(),
+		server:         &http.Server{},
+		router:         httprouter.New(),
+		IdempotencyTTL: 24 * time.Hour,
+		OrderTTL:       tixer.DefaultOrderTTL,
 	}
+	srv.customMethods = newCustomMethodRouter(srv.router)
 
 	for _, opt := range options {
 		opt(srv)
 	}
 
+	if len(srv.CursorSecret) == 0 {
+		srv.CursorSecret = make([]byte, 32)
+		if _, err := rand.Read(srv.CursorSecret); err != nil {
+			panic(err)
+		}
+	}
+
 	return srv
 }
 
@@ -62,6 +96,13 @@ func (s *Server) Shutdown() error {
 	return s.server.Shutdown(ctx)
 }
 
+// ServeHTTP dispatches the request to the attached routes.
+//
+// It allows the server to be exercised without listening on a network address.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.server.Handler.ServeHTTP(w, r)
+}
+
 func WithAddr(addr string) func(*Server) {
 	return func(s *Server) {
 		s.Addr = addr
@@ -99,10 +140,36 @@ func WithShutdownTimeout(d time.Duration) func(*Server) {
 	}
 }
 
+func WithIdempotencyTTL(d time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.IdempotencyTTL = d
+	}
+}
+
+func WithOrderTTL(d time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.OrderTTL = d
+	}
+}
+
+func WithCursorSecret(secret []byte) func(*Server) {
+	return func(s *Server) {
+		s.CursorSecret = secret
+	}
+}
+
 func (s *Server) AttachRoutesV1() {
 	s.router.HandlerFunc(http.MethodGet, "/v1/healthcheck", s.handleHealthCheck)
 
-	s.registerTicketsRoutesV1(s.router)
+	s.registerTicketsRoutesV1(s.router, s.customMethods)
+
+	if s.OrderService != nil {
+		s.registerOrdersRoutesV1(s.router, s.customMethods)
+	}
+
+	if s.OrderService != nil && s.PaymentProvider != nil {
+		s.registerPaymentsRoutesV1(s.router, s.customMethods)
+	}
 
-	s.server.Handler = s.router
+	s.server.Handler = s.customMethods
 }
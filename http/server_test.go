@@ -1,16 +1,88 @@
 package http_test
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	tixerhttp "github.com/mroobert/tixer-tickets/http"
+	"github.com/mroobert/tixer-tickets/inmem"
+	"github.com/mroobert/tixer-tickets/payment"
+	"github.com/mroobert/tixer-tickets/search"
+	"golang.org/x/exp/slog"
 )
 
-func Test_SomeMethod(t *testing.T) {
+func TestHealthCheck_RespondsWithOK(t *testing.T) {
 	t.Parallel()
 
-	want := true
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
 
-	got := true
-	if got != want {
-		t.Fatal("Invalid method")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusOK)
 	}
 }
+
+// newTestServer creates a server backed by in-memory stores and search index.
+func newTestServer() *tixerhttp.Server {
+	srv := tixerhttp.NewServer(
+		tixerhttp.WithLogger(slog.New(slog.NewTextHandler(io.Discard))),
+	)
+	index := search.NewIndex()
+	tickets := inmem.NewStorer()
+	srv.TicketService = search.NewTicketService(tickets, index)
+	srv.SearchService = index
+	srv.IdempotencyService = inmem.NewIdempotencyStorer()
+	srv.OrderService = search.NewOrderService(inmem.NewOrderStorer(tickets), srv.TicketService, index)
+	srv.PaymentProvider = payment.NewFake([]byte("webhook-secret"))
+	srv.AttachRoutesV1()
+
+	return srv
+}
+
+// createTicket creates the ticket described by the JSON body, and returns its location.
+func createTicket(t *testing.T, srv *tixerhttp.Server, body string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d on create, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	return rec.Header().Get("Location")
+}
+
+// createOrder orders quantity units of the ticket at the given location,
+// and returns the location of the order.
+func createOrder(t *testing.T, srv *tixerhttp.Server, ticket string, quantity int) string {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"items":[{"ticket_id":%q,"quantity":%d}]}`, strings.TrimPrefix(ticket, "/v1/tickets/"), quantity)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d on order, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	return rec.Header().Get("Location")
+}
+
+// readAvailability returns the body of the availability of the ticket at the given location.
+func readAvailability(t *testing.T, srv *tixerhttp.Server, ticket string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ticket+"/availability", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d on availability, want %d", rec.Code, http.StatusOK)
+	}
+
+	return rec.Body.String()
+}
@@ -4,24 +4,35 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mroobert/tixer-pkgs/validate"
 	"github.com/mroobert/tixer-pkgs/web"
 	"github.com/mroobert/tixer-tickets"
 )
 
-func (s *Server) registerTicketsRoutesV1(router *httprouter.Router) {
+func (s *Server) registerTicketsRoutesV1(router *httprouter.Router, customMethods *customMethodRouter) {
 	router.HandlerFunc(http.MethodGet, "/v1/tickets", s.handleReadTickets)
 
-	router.HandlerFunc(http.MethodGet, "/v1/tickets/:id", s.handleReadTicket)
+	router.HandlerFunc(http.MethodGet, "/v1/tickets/:id", s.handleReadTicketOrSearch)
 
-	router.HandlerFunc(http.MethodPost, "/v1/tickets", s.handleCreateTicket)
+	router.HandlerFunc(http.MethodPost, "/v1/tickets", s.idempotent(s.handleCreateTicket))
 
 	router.HandlerFunc(http.MethodPatch, "/v1/tickets/:id", s.handleUpdateTicket)
 
+	router.HandlerFunc(http.MethodPut, "/v1/tickets/:id", s.handleReplaceTicket)
+
 	router.HandlerFunc(http.MethodDelete, "/v1/tickets/:id", s.handleDeleteTicket)
+
+	customMethods.HandlerFunc(http.MethodPost, "/v1/tickets/:id", "restore", s.handleRestoreTicket)
+
+	s.registerTicketsBatchRoutesV1(customMethods)
+
+	s.registerTicketsStatusRoutesV1(customMethods)
+
+	s.registerTicketsAvailabilityRoutesV1(router)
 }
 
 func (s *Server) handleCreateTicket(w http.ResponseWriter, r *http.Request) {
@@ -32,13 +43,8 @@ func (s *Server) handleCreateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tck := tixer.Ticket{
-		ID:    tixer.NewTicketID(),
-		Title: input.Title,
-		Price: input.Price,
-	}
-
 	vld := validate.NewValidator()
+	tck := input.ticket(tixer.NewTicketID(), vld)
 	if tck.Validate(vld); !vld.Valid() {
 		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
 		return
@@ -50,8 +56,13 @@ func (s *Server) handleCreateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New tickets always start at version 1.
+	tck.Version = 1
+	tck.Capacity = tck.CapacityOrDefault()
+
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/tickets/%s", tck.ID))
+	headers.Set("ETag", ticketETag(tck))
 
 	err = web.WriteJSON(w, http.StatusCreated, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
 	if err != nil {
@@ -59,6 +70,8 @@ func (s *Server) handleCreateTicket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleReadTicket reads a ticket, or a deleted ticket when the
+// "include_deleted" parameter is set.
 func (s *Server) handleReadTicket(w http.ResponseWriter, r *http.Request) {
 	id, err := web.ReadIDParam(r)
 	if err != nil {
@@ -66,7 +79,17 @@ func (s *Server) handleReadTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	vld := validate.NewValidator()
+	includeDeleted := readBool(r.URL.Query(), "include_deleted", false, vld)
+	if !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
 	tck, err := s.TicketService.ReadTicket(r.Context(), tixer.TicketID(id))
+	if errors.Is(err, tixer.ErrTicketNotFound) && includeDeleted {
+		tck, err = s.TicketService.ReadDeletedTicket(r.Context(), tixer.TicketID(id))
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, tixer.ErrTicketNotFound):
@@ -78,7 +101,10 @@ func (s *Server) handleReadTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", ticketETag(tck))
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
 	if err != nil {
 		web.ServerErrorResponse(s.Logger, w, r, err)
 		return
@@ -99,31 +125,94 @@ func (s *Server) handleUpdateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tck := tixer.Ticket{
-		ID: tixer.TicketID(id),
+	version, err := s.expectedVersion(r.Context(), r, tixer.TicketID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
 	}
 
 	vld := validate.NewValidator()
-	if input.Title != nil {
-		tck.Title = *input.Title
-		tck.ValidateTitle(vld)
+	upd := input.update(tixer.TicketID(id), version, vld)
+	if upd.Validate(vld); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	tck, err := s.TicketService.UpdateTicket(r.Context(), upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
 	}
 
-	if input.Price != nil {
-		tck.Price = *input.Price
-		tck.ValidatPrice(vld)
+	headers := make(http.Header)
+	headers.Set("ETag", ticketETag(tck))
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
 	}
+}
 
-	if !vld.Valid() {
+// handleReplaceTicket fully replaces a ticket, creating it with the
+// client-chosen ID when it does not exist.
+func (s *Server) handleReplaceTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	var input replaceTicket
+	err = web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	// The ticket may not exist yet, in which case no expected version can match.
+	version, err := s.expectedVersion(r.Context(), r, tixer.TicketID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrVersionConflict), errors.Is(err, tixer.ErrTicketNotFound):
+			preconditionFailedResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	vld := validate.NewValidator()
+	tck := createTicket(input).ticket(tixer.TicketID(id), vld)
+	tck.Version = version
+	if tck.Validate(vld); !vld.Valid() {
 		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
 		return
 	}
 
-	tck, err = s.TicketService.UpdateTicket(r.Context(), tck)
+	tck, created, err := s.TicketService.ReplaceTicket(r.Context(), tck)
 	if err != nil {
 		switch {
-		case errors.Is(err, tixer.ErrTicketNotFound):
-			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrTicketDeleted):
+			conflictResponse(s.Logger, w, r, "the ticket is deleted, restore it before replacing it")
 		default:
 			web.ServerErrorResponse(s.Logger, w, r, err)
 		}
@@ -131,7 +220,15 @@ func (s *Server) handleUpdateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, nil)
+	status := http.StatusOK
+	headers := make(http.Header)
+	headers.Set("ETag", ticketETag(tck))
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", fmt.Sprintf("/v1/tickets/%s", tck.ID))
+	}
+
+	err = web.WriteJSON(w, status, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
 	if err != nil {
 		web.ServerErrorResponse(s.Logger, w, r, err)
 	}
@@ -144,11 +241,27 @@ func (s *Server) handleDeleteTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.TicketService.DeleteTicket(r.Context(), tixer.TicketID(id))
+	version, err := s.expectedVersion(r.Context(), r, tixer.TicketID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	err = s.TicketService.DeleteTicket(r.Context(), tixer.TicketID(id), version)
 	if err != nil {
 		switch {
 		case errors.Is(err, tixer.ErrTicketNotFound):
 			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
 		default:
 			web.ServerErrorResponse(s.Logger, w, r, err)
 		}
@@ -162,24 +275,97 @@ func (s *Server) handleDeleteTicket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleRestoreTicket restores a deleted ticket, which honors the If-Match
+// header with the entity tag of the deleted ticket.
+func (s *Server) handleRestoreTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	version, err := expectedVersionOf(r.Context(), r, tixer.TicketID(id), s.TicketService.ReadDeletedTicket)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	tck, err := s.TicketService.RestoreTicket(r.Context(), tixer.TicketID(id), version)
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		case errors.Is(err, tixer.ErrVersionConflict):
+			preconditionFailedResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", ticketETag(tck))
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
 func (s *Server) handleReadTickets(w http.ResponseWriter, r *http.Request) {
 	vld := validate.NewValidator()
 
 	var input readTickets
 	qs := r.URL.Query()
-	input.After = web.ReadUUID(qs, "after", uuid.Nil, vld)
-	input.Before = web.ReadUUID(qs, "before", uuid.Nil, vld)
-	input.Limit = web.ReadInt(qs, "limit", 10, vld)
+	input.pageInput = readPageInput(qs, vld)
+	input.Title = web.ReadString(qs, "title", "")
+	input.Status = web.ReadString(qs, "status", "")
+	input.Currency = tixer.Currency(web.ReadString(qs, "currency", ""))
+	input.PriceMin = readMoney(qs, "price_min", input.Currency, vld)
+	input.PriceMax = readMoney(qs, "price_max", input.Currency, vld)
+	input.CreatedAfter = readTime(qs, "created_after", time.Time{}, vld)
+	input.CreatedBefore = readTime(qs, "created_before", time.Time{}, vld)
+	input.Sort = web.ReadString(qs, "sort", string(tixer.SortByDateCreatedDesc))
+	input.IncludeDeleted = readBool(qs, "include_deleted", false, vld)
 
 	if validateReadTickets(vld, input); !vld.Valid() {
 		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
 		return
 	}
 
+	after, before, err := s.decodePageCursors(input.pageInput, input.Sort)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
 	filter := tixer.Filter{
-		After:  tixer.TicketID(input.After),
-		Before: tixer.TicketID(input.Before),
-		Limit:  input.Limit,
+		After:         after,
+		Before:        before,
+		Limit:         input.Limit,
+		TitlePrefix:   input.Title,
+		Status:        tixer.TicketStatus(input.Status),
+		Currency:      input.Currency,
+		CreatedAfter:  input.CreatedAfter,
+		CreatedBefore: input.CreatedBefore,
+		Sort:          tixer.TicketSort(input.Sort),
+
+		IncludeDeleted: input.IncludeDeleted,
+	}
+	if input.PriceMin != nil {
+		filter.PriceMin = &input.PriceMin.Amount
+	}
+	if input.PriceMax != nil {
+		filter.PriceMax = &input.PriceMax.Amount
 	}
 
 	tt, met, err := s.TicketService.ReadTickets(r.Context(), filter)
@@ -188,9 +374,15 @@ func (s *Server) handleReadTickets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pagination, err := s.mapMetadataToResponse(r, input.Sort, input.pageInput, met, len(tt))
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+
 	err = web.WriteJSON(w, http.StatusOK, web.Envelope{
 		"tickets":    mapTicketListToResponse(tt),
-		"pagination": mapMetadataToResponse(met),
+		"pagination": pagination,
 	}, nil)
 
 	if err != nil {
@@ -201,8 +393,18 @@ func (s *Server) handleReadTickets(w http.ResponseWriter, r *http.Request) {
 type (
 	// createTicket contains the information needed to create a new Ticket.
 	createTicket struct {
-		Title string  `json:"title"`
-		Price float64 `json:"price"`
+		EventID     string         `json:"event_id"`
+		Title       string         `json:"title"`
+		Description string         `json:"description"`
+		Venue       string         `json:"venue"`
+		StartsAt    time.Time      `json:"starts_at"`
+		Section     string         `json:"section"`
+		Row         string         `json:"row"`
+		Seat        string         `json:"seat"`
+		Price       amount         `json:"price"`
+		Currency    tixer.Currency `json:"currency"`
+		Status      string         `json:"status"`
+		Capacity    *int           `json:"capacity"`
 	}
 
 	// updateTicket contains the information needed to update a Ticket.
@@ -210,48 +412,210 @@ type (
 	// It uses pointer fields so we can differentiate between a field that
 	// was not provided and a field that was provided as explicitly blank.
 	updateTicket struct {
-		Title *string  `json:"title"`
-		Price *float64 `json:"price"`
+		EventID     *string         `json:"event_id"`
+		Title       *string         `json:"title"`
+		Description *string         `json:"description"`
+		Venue       *string         `json:"venue"`
+		StartsAt    *time.Time      `json:"starts_at"`
+		Section     *string         `json:"section"`
+		Row         *string         `json:"row"`
+		Seat        *string         `json:"seat"`
+		Price       *amount         `json:"price"`
+		Currency    *tixer.Currency `json:"currency"`
+	}
+
+	// replaceTicket contains the information needed to fully replace a Ticket.
+	// It has the fields of createTicket, which are reset when they are not provided,
+	// but the status and the capacity, which are only used when the ticket is created.
+	replaceTicket createTicket
+
+	// pageInput contains the information needed to read a page of a list.
+	pageInput struct {
+		After  string `json:"after"`
+		Before string `json:"before"`
+		Limit  int    `json:"limit"`
 	}
 
 	// readTickets contains the information needed to read a list of Tickets.
 	readTickets struct {
-		After  uuid.UUID `json:"after"`
-		Before uuid.UUID `json:"before"`
-		Limit  int       `json:"limit"`
+		pageInput
+		Title         string         `json:"title"`
+		Status        string         `json:"status"`
+		Currency      tixer.Currency `json:"currency"`
+		PriceMin      *tixer.Money   `json:"price_min"`
+		PriceMax      *tixer.Money   `json:"price_max"`
+		CreatedAfter  time.Time      `json:"created_after"`
+		CreatedBefore time.Time      `json:"created_before"`
+		Sort          string         `json:"sort"`
+
+		IncludeDeleted bool `json:"include_deleted"`
 	}
 )
 
 type (
 	// ticketResponse contains the information about a Ticket that we want to
 	// return to clients.
+	//
+	// The optional fields are omitted when they are not set.
 	ticketResponse struct {
-		ID    string  `json:"id"`
-		Title string  `json:"title"`
-		Price float64 `json:"price"`
+		ID          string     `json:"id"`
+		EventID     string     `json:"event_id,omitempty"`
+		Title       string     `json:"title"`
+		Description string     `json:"description,omitempty"`
+		Venue       string     `json:"venue,omitempty"`
+		StartsAt    *time.Time `json:"starts_at,omitempty"`
+		Section     string     `json:"section,omitempty"`
+		Row         string     `json:"row,omitempty"`
+		Seat        string     `json:"seat,omitempty"`
+		Price       string     `json:"price"`
+		Currency    string     `json:"currency,omitempty"`
+		Status      string     `json:"status"`
+		Capacity    int        `json:"capacity"`
+		Sold        int        `json:"sold"`
+		Held        int        `json:"held"`
+		Version     int        `json:"version"`
+		DateDeleted *time.Time `json:"date_deleted,omitempty"`
 	}
 
 	// metadataResponse contains the information required to apply pagination
-	// on the client side.
+	// on the client side. The cursors are opaque tokens, and the links
+	// are only set when there may be tickets on the next or previous page.
 	metadataResponse struct {
 		After  string `json:"after"`
 		Before string `json:"before"`
 		Total  int    `json:"total"`
+		Next   string `json:"next,omitempty"`
+		Prev   string `json:"prev,omitempty"`
 	}
 )
 
 // validateReadTickets validates from a 'Presentation' perspective the information
 // provided for reading a list of tickets.
 func validateReadTickets(vld *validate.Validator, input readTickets) {
-	vld.Check(input.Limit > 0 && input.Limit <= 50, "limit", "must be in the interval [0, 50]")
+	validatePageInput(vld, input.pageInput)
+	vld.Check(len(input.Title) <= 50, "title", "must not be longer than 50 characters")
+	vld.Check(input.Status == "" || tixer.TicketStatus(input.Status).Valid(), "status", fmt.Sprintf("must be one of %s", statusNames()))
+	vld.Check(input.Currency == "" || input.Currency.Valid(), "currency", "must be a supported ISO 4217 currency code")
+	if input.PriceMin != nil || input.PriceMax != nil {
+		vld.Check(input.Currency != "", "currency", "must be provided along with price_min or price_max")
+	}
+	vld.Check(input.PriceMin == nil || input.PriceMin.Amount >= 0, "price_min", "must not be negative")
+	vld.Check(input.PriceMax == nil || input.PriceMax.Amount >= 0, "price_max", "must not be negative")
+	if input.PriceMin != nil && input.PriceMax != nil {
+		vld.Check(input.PriceMin.Amount <= input.PriceMax.Amount, "price_max", "must not be lower than price_min")
+	}
+	if !input.CreatedAfter.IsZero() && !input.CreatedBefore.IsZero() {
+		vld.Check(input.CreatedAfter.Before(input.CreatedBefore), "created_before", "must be later than created_after")
+	}
+	vld.Check(tixer.TicketSort(input.Sort).Valid(), "sort", fmt.Sprintf("must be one of %s", sortNames()))
+}
+
+// sortNames lists the accepted values of the sort parameter.
+func sortNames() string {
+	names := make([]string, 0, len(tixer.TicketSorts))
+	for _, sort := range tixer.TicketSorts {
+		names = append(names, string(sort))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// statusNames lists the accepted values of the status parameter.
+func statusNames() string {
+	names := make([]string, 0, len(tixer.TicketStatuses))
+	for _, status := range tixer.TicketStatuses {
+		names = append(names, string(status))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// ticket returns the ticket described by the input, on sale unless it is created as a draft,
+// with DefaultCapacity unless its capacity is provided.
+// A price which cannot be parsed in the currency is reported to the validator.
+func (input createTicket) ticket(id tixer.TicketID, vld *validate.Validator) tixer.Ticket {
+	status := tixer.TicketStatus(input.Status)
+	vld.Check(status == "" || status == tixer.StatusDraft || status == tixer.StatusOnSale, "status", fmt.Sprintf("must be %s or %s", tixer.StatusDraft, tixer.StatusOnSale))
+
+	capacity := tixer.DefaultCapacity
+	if input.Capacity != nil {
+		capacity = *input.Capacity
+	}
+
+	return tixer.Ticket{
+		ID:          id,
+		EventID:     input.EventID,
+		Title:       input.Title,
+		Description: input.Description,
+		Venue:       input.Venue,
+		StartsAt:    input.StartsAt.UTC(),
+		Section:     input.Section,
+		Row:         input.Row,
+		Seat:        input.Seat,
+		Price:       input.Price.money(input.Currency, "price", vld),
+		Status:      status.OrDefault(),
+		Capacity:    capacity,
+	}
+}
+
+// update returns the update of the ticket described by the input.
+//
+// The price and its currency are updated together, so that the amount
+// is always parsed in the currency it is stored with.
+func (input updateTicket) update(id tixer.TicketID, version int, vld *validate.Validator) tixer.TicketUpdate {
+	upd := tixer.TicketUpdate{
+		ID:          id,
+		EventID:     input.EventID,
+		Title:       input.Title,
+		Description: input.Description,
+		Venue:       input.Venue,
+		Section:     input.Section,
+		Row:         input.Row,
+		Seat:        input.Seat,
+		Version:     version,
+	}
+	if input.StartsAt != nil {
+		startsAt := input.StartsAt.UTC()
+		upd.StartsAt = &startsAt
+	}
+	if input.Price != nil || input.Currency != nil {
+		vld.Check(input.Price != nil, "price", "must be provided along with the currency")
+		vld.Check(input.Currency != nil, "currency", "must be provided along with the price")
+	}
+	if input.Price != nil && input.Currency != nil {
+		price := input.Price.money(*input.Currency, "price", vld)
+		upd.Price = &price
+	}
+
+	return upd
 }
 
 func mapTicketToResponse(ticket tixer.Ticket) ticketResponse {
-	return ticketResponse{
-		ID:    ticket.ID.String(),
-		Title: ticket.Title,
-		Price: ticket.Price,
+	res := ticketResponse{
+		ID:          ticket.ID.String(),
+		EventID:     ticket.EventID,
+		Title:       ticket.Title,
+		Description: ticket.Description,
+		Venue:       ticket.Venue,
+		Section:     ticket.Section,
+		Row:         ticket.Row,
+		Seat:        ticket.Seat,
+		Price:       ticket.Price.Decimal(),
+		Currency:    string(ticket.Price.Currency),
+		Status:      string(ticket.Status),
+		Capacity:    ticket.Capacity,
+		Sold:        ticket.Sold,
+		Held:        ticket.Held,
+		Version:     ticket.Version,
+	}
+	if !ticket.StartsAt.IsZero() {
+		res.StartsAt = &ticket.StartsAt
 	}
+	if !ticket.DateDeleted.IsZero() {
+		res.DateDeleted = &ticket.DateDeleted
+	}
+
+	return res
 }
 
 func mapTicketListToResponse(tickets []tixer.Ticket) []ticketResponse {
@@ -263,10 +627,45 @@ func mapTicketListToResponse(tickets []tixer.Ticket) []ticketResponse {
 	return slice
 }
 
-func mapMetadataToResponse(m tixer.Metadata) metadataResponse {
-	return metadataResponse{
-		After:  m.After.String(),
-		Before: m.Before.String(),
+// mapMetadataToResponse signs the cursors of the page for the scope it was read with,
+// and builds the links to its neighbours, keeping the other parameters of the request.
+func (s *Server) mapMetadataToResponse(r *http.Request, scope string, input pageInput, m tixer.Metadata, n int) (metadataResponse, error) {
+	after, err := encodeCursor(s.CursorSecret, m.After, scope)
+	if err != nil {
+		return metadataResponse{}, err
+	}
+	before, err := encodeCursor(s.CursorSecret, m.Before, scope)
+	if err != nil {
+		return metadataResponse{}, err
+	}
+
+	res := metadataResponse{
+		After:  after,
+		Before: before,
 		Total:  m.Total,
 	}
+	if n == 0 {
+		return res, nil
+	}
+
+	// A full page may be followed by more tickets, and paging backwards
+	// always leaves the tickets that were already seen on the next page.
+	if n == input.Limit || input.Before != "" {
+		res.Next = pageLink(r, "after", after)
+	}
+	if input.After != "" || (input.Before != "" && n == input.Limit) {
+		res.Prev = pageLink(r, "before", before)
+	}
+
+	return res, nil
+}
+
+// pageLink returns the URL of the request with its cursor replaced.
+func pageLink(r *http.Request, key, cursor string) string {
+	qs := r.URL.Query()
+	qs.Del("after")
+	qs.Del("before")
+	qs.Set(key, cursor)
+
+	return r.URL.Path + "?" + qs.Encode()
 }
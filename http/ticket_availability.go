@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// registerTicketsAvailabilityRoutesV1 registers the route reading
+// how many units of a ticket are left to sell.
+func (s *Server) registerTicketsAvailabilityRoutesV1(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, "/v1/tickets/:id/availability", s.handleReadTicketAvailability)
+}
+
+// handleReadTicketAvailability reads the units of a ticket sold, held and left to sell.
+//
+// The units of the expired holds which were not released yet are still counted as held.
+func (s *Server) handleReadTicketAvailability(w http.ResponseWriter, r *http.Request) {
+	id, err := web.ReadIDParam(r)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	tck, err := s.TicketService.ReadTicket(r.Context(), tixer.TicketID(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			web.NotFoundResponse(s.Logger, w, r)
+		default:
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+
+		return
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"availability": mapAvailabilityToResponse(tck)}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// availabilityResponse represents the units of a ticket left to sell.
+type availabilityResponse struct {
+	TicketID  string `json:"ticket_id"`
+	Status    string `json:"status"`
+	Capacity  int    `json:"capacity"`
+	Sold      int    `json:"sold"`
+	Held      int    `json:"held"`
+	Available int    `json:"available"`
+}
+
+func mapAvailabilityToResponse(ticket tixer.Ticket) availabilityResponse {
+	return availabilityResponse{
+		TicketID:  ticket.ID.String(),
+		Status:    string(ticket.Status),
+		Capacity:  ticket.CapacityOrDefault(),
+		Sold:      ticket.Sold,
+		Held:      ticket.Held,
+		Available: ticket.Available(),
+	}
+}
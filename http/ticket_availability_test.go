@@ -0,0 +1,73 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadTicketAvailability_ReportsTheCapacityOfTheTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	location := createTicket(t, srv, `{"title":"Festival","price":"80.00","currency":"EUR","capacity":500}`)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location+"/availability", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Availability struct {
+			Capacity  int `json:"capacity"`
+			Sold      int `json:"sold"`
+			Held      int `json:"held"`
+			Available int `json:"available"`
+		} `json:"availability"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if got := body.Availability; got.Capacity != 500 || got.Sold != 0 || got.Held != 0 || got.Available != 500 {
+		t.Errorf("Got availability %+v, want 500 tickets available", got)
+	}
+}
+
+func TestReadTicketAvailability_RespondsWithNotFoundForAMissingTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets/5f0a2b2e-1b7c-4b8e-9a53-0f6f3c2f9d11/availability", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestCreateTicket_RejectsAnInvalidCapacity(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	for _, capacity := range []string{"-1", "0", "100001"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Festival","price":"80.00","currency":"EUR","capacity":`+capacity+`}`)))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Got status code %d for a capacity of %s, want %d", rec.Code, capacity, http.StatusUnprocessableEntity)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Festival","price":"80.00","currency":"EUR"}`)))
+	if !strings.Contains(rec.Body.String(), `"capacity":1`) {
+		t.Errorf("Got ticket %s, want the default capacity of 1", rec.Body)
+	}
+}
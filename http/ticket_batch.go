@@ -0,0 +1,285 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-pkgs/validate"
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+func (s *Server) registerTicketsBatchRoutesV1(router *customMethodRouter) {
+	router.HandlerFunc(http.MethodPost, "/v1/tickets", "batchCreate", s.idempotent(s.handleBatchCreateTickets))
+
+	router.HandlerFunc(http.MethodPost, "/v1/tickets", "batchUpdate", s.handleBatchUpdateTickets)
+
+	router.HandlerFunc(http.MethodPost, "/v1/tickets", "batchDelete", s.handleBatchDeleteTickets)
+}
+
+// handleBatchCreateTickets creates a batch of tickets.
+//
+// By default the batch is atomic: when any ticket is invalid none is created,
+// and the validation errors are reported by index. With atomic=false the valid
+// tickets are created and the result of every ticket is reported.
+func (s *Server) handleBatchCreateTickets(w http.ResponseWriter, r *http.Request) {
+	vld := validate.NewValidator()
+	atomic := readBool(r.URL.Query(), "atomic", true, vld)
+
+	var input batchCreateTickets
+	err := web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	if validateBatchSize(vld, len(input.Tickets), tixer.MaxBatchSize); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	tt := make([]tixer.Ticket, 0, len(input.Tickets))
+	results := make([]batchResult, len(input.Tickets))
+	itemErrors := make(map[string]map[string]string)
+	for i, item := range input.Tickets {
+		vld := validate.NewValidator()
+		tck := item.ticket(tixer.NewTicketID(), vld)
+		if tck.Validate(vld); !vld.Valid() {
+			results[i] = batchResult{Status: batchStatusValidationFailed, Errors: vld.Errors}
+			itemErrors[strconv.Itoa(i)] = vld.Errors
+			continue
+		}
+
+		results[i] = batchResult{ID: tck.ID.String(), Status: batchStatusCreated}
+		tt = append(tt, tck)
+	}
+
+	if atomic && len(itemErrors) > 0 {
+		errorResponse(s.Logger, w, r, http.StatusUnprocessableEntity, itemErrors)
+		return
+	}
+
+	err = s.TicketService.CreateTickets(r.Context(), tt)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+		return
+	}
+
+	if !atomic {
+		err = web.WriteJSON(w, http.StatusOK, web.Envelope{"results": results}, nil)
+		if err != nil {
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+		return
+	}
+
+	ids := make([]string, 0, len(tt))
+	for _, tck := range tt {
+		ids = append(ids, tck.ID.String())
+	}
+
+	err = web.WriteJSON(w, http.StatusCreated, web.Envelope{"ids": ids}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// handleBatchUpdateTickets applies a batch of partial updates.
+//
+// The valid updates are applied together, and the result of every update
+// is reported in the order of the request.
+func (s *Server) handleBatchUpdateTickets(w http.ResponseWriter, r *http.Request) {
+	var input batchUpdateTickets
+	err := web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	vld := validate.NewValidator()
+	if validateBatchSize(vld, len(input.Tickets), tixer.MaxBatchSize); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	// positions maps the updates sent to the service to their index in the request.
+	var (
+		updates   []tixer.TicketUpdate
+		positions []int
+	)
+	results := make([]batchResult, len(input.Tickets))
+	seen := make(map[uuid.UUID]bool, len(input.Tickets))
+	for i, item := range input.Tickets {
+		vld := validate.NewValidator()
+		id := readBatchID(vld, item.ID, seen)
+		vld.Check(item.Version >= 0, "version", "must not be negative")
+
+		upd := item.update(tixer.TicketID(id), item.Version, vld)
+		if upd.Validate(vld); !vld.Valid() {
+			results[i] = batchResult{ID: item.ID, Status: batchStatusValidationFailed, Errors: vld.Errors}
+			continue
+		}
+
+		updates = append(updates, upd)
+		positions = append(positions, i)
+	}
+
+	if len(updates) > 0 {
+		updated, err := s.TicketService.UpdateTickets(r.Context(), updates)
+		if err != nil {
+			web.ServerErrorResponse(s.Logger, w, r, err)
+			return
+		}
+
+		for j, res := range updated {
+			i := positions[j]
+			switch {
+			case errors.Is(res.Err, tixer.ErrTicketNotFound):
+				results[i] = batchResult{ID: updates[j].ID.String(), Status: batchStatusNotFound}
+			case errors.Is(res.Err, tixer.ErrVersionConflict):
+				results[i] = batchResult{ID: updates[j].ID.String(), Status: batchStatusVersionConflict}
+			case res.Err != nil:
+				web.ServerErrorResponse(s.Logger, w, r, res.Err)
+				return
+			default:
+				tck := mapTicketToResponse(res.Ticket)
+				results[i] = batchResult{ID: updates[j].ID.String(), Status: batchStatusUpdated, Ticket: &tck}
+			}
+		}
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"results": results}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+// handleBatchDeleteTickets deletes a batch of tickets.
+//
+// The existing tickets are deleted together, and the result of every ID
+// is reported in the order of the request.
+func (s *Server) handleBatchDeleteTickets(w http.ResponseWriter, r *http.Request) {
+	var input batchDeleteTickets
+	err := web.ReadJSON(w, r, &input)
+	if err != nil {
+		web.BadRequestResponse(s.Logger, w, r, err)
+		return
+	}
+
+	vld := validate.NewValidator()
+	if validateBatchSize(vld, len(input.IDs), tixer.MaxDeleteBatchSize); !vld.Valid() {
+		web.FailedValidationResponse(s.Logger, w, r, vld.Errors)
+		return
+	}
+
+	var (
+		ids       []tixer.TicketID
+		positions []int
+	)
+	results := make([]batchResult, len(input.IDs))
+	seen := make(map[uuid.UUID]bool, len(input.IDs))
+	for i, item := range input.IDs {
+		vld := validate.NewValidator()
+		id := readBatchID(vld, item, seen)
+		if !vld.Valid() {
+			results[i] = batchResult{ID: item, Status: batchStatusValidationFailed, Errors: vld.Errors}
+			continue
+		}
+
+		ids = append(ids, tixer.TicketID(id))
+		positions = append(positions, i)
+	}
+
+	if len(ids) > 0 {
+		deleted, err := s.TicketService.DeleteTickets(r.Context(), ids)
+		if err != nil {
+			web.ServerErrorResponse(s.Logger, w, r, err)
+			return
+		}
+
+		for j, err := range deleted {
+			i := positions[j]
+			switch {
+			case errors.Is(err, tixer.ErrTicketNotFound):
+				results[i] = batchResult{ID: ids[j].String(), Status: batchStatusNotFound}
+			case err != nil:
+				web.ServerErrorResponse(s.Logger, w, r, err)
+				return
+			default:
+				results[i] = batchResult{ID: ids[j].String(), Status: batchStatusDeleted}
+			}
+		}
+	}
+
+	err = web.WriteJSON(w, http.StatusOK, web.Envelope{"results": results}, nil)
+	if err != nil {
+		web.ServerErrorResponse(s.Logger, w, r, err)
+	}
+}
+
+type (
+	// batchCreateTickets contains the information needed to create a batch of Tickets.
+	batchCreateTickets struct {
+		Tickets []createTicket `json:"tickets"`
+	}
+
+	// batchUpdateTickets contains the information needed to update a batch of Tickets.
+	batchUpdateTickets struct {
+		Tickets []batchUpdateTicket `json:"tickets"`
+	}
+
+	// batchUpdateTicket contains the information needed to update a Ticket of a batch.
+	// Version is optional, when provided the update only applies to that version.
+	batchUpdateTicket struct {
+		ID string `json:"id"`
+		updateTicket
+		Version int `json:"version"`
+	}
+
+	// batchDeleteTickets contains the information needed to delete a batch of Tickets.
+	batchDeleteTickets struct {
+		IDs []string `json:"ids"`
+	}
+)
+
+// Statuses reported for the items of a batch operation.
+const (
+	batchStatusCreated          = "created"
+	batchStatusUpdated          = "updated"
+	batchStatusDeleted          = "deleted"
+	batchStatusNotFound         = "not_found"
+	batchStatusVersionConflict  = "version_conflict"
+	batchStatusValidationFailed = "validation_failed"
+)
+
+// batchResult contains the outcome of a single item of a batch operation.
+type batchResult struct {
+	ID     string            `json:"id,omitempty"`
+	Status string            `json:"status"`
+	Ticket *ticketResponse   `json:"ticket,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// readBatchID validates the ID of an item of a batch operation,
+// which must be a UUID that is unique within the batch.
+func readBatchID(vld *validate.Validator, input string, seen map[uuid.UUID]bool) uuid.UUID {
+	id, err := uuid.Parse(input)
+	if err != nil {
+		vld.AddError("id", "must be a valid UUID")
+		return uuid.Nil
+	}
+
+	vld.Check(!seen[id], "id", "must be unique within the batch")
+	seen[id] = true
+
+	return id
+}
+
+// validateBatchSize validates from a 'Presentation' perspective the number
+// of items of a batch operation.
+func validateBatchSize(vld *validate.Validator, size, limit int) {
+	vld.Check(size > 0 && size <= limit, "tickets", fmt.Sprintf("must contain between 1 and %d items", limit))
+}
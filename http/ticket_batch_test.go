@@ -0,0 +1,139 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+)
+
+func TestBatchCreateTickets_CreatesAllTheTickets(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	body := `{"tickets":[{"title":"Concert","price":"150.00","currency":"EUR"},{"title":"Opera","price":"10.00","currency":"EUR"}]}`
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchCreate", strings.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	var resp struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if len(resp.IDs) != 2 {
+		t.Errorf("Got %d ids, want 2", len(resp.IDs))
+	}
+}
+
+func TestBatchCreateTickets_ReportsTheErrorsByIndex(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	body := `{"tickets":[{"title":"Concert","price":"150.00","currency":"EUR"},{"title":"","price":"10.00","currency":"EUR"}]}`
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchCreate", strings.NewReader(body)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(rec.Body.String(), `"1":{"title"`) {
+		t.Errorf("Got %s, want the title error of the second ticket", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchCreate?atomic=false", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp struct {
+		Results []struct {
+			ID     string            `json:"id"`
+			Errors map[string]string `json:"errors"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].ID == "" || resp.Results[1].Errors["title"] == "" {
+		t.Errorf("Got results %+v", resp.Results)
+	}
+}
+
+func TestBatchDeleteTickets_ReportsTheOutcomeOfEveryID(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	body := `{"tickets":[{"title":"Concert","price":"150.00","currency":"EUR"}]}`
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchCreate", strings.NewReader(body)))
+
+	var created struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"ids":["` + created.IDs[0] + `","not-a-uuid","` + uuid.NewString() + `"]}`
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchDelete", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp struct {
+		Results []struct {
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	want := []string{"deleted", "validation_failed", "not_found"}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("Got %d results, want %d", len(resp.Results), len(want))
+	}
+	for i := range want {
+		if resp.Results[i].Status != want[i] {
+			t.Errorf("Got status %q at index %d, want %q", resp.Results[i].Status, i, want[i])
+		}
+	}
+}
+
+func TestBatchDeleteTickets_RespondsWithValidationErrorsPastTheLimit(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	ids := make([]string, tixer.MaxDeleteBatchSize+1)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+	}
+	body, err := json.Marshal(map[string][]string{"ids": ids})
+	if err != nil {
+		t.Fatalf("could not encode the request: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets:batchDelete", strings.NewReader(string(body))))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mroobert/tixer-pkgs/web"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// registerTicketsStatusRoutesV1 registers the custom methods moving a ticket
+// through its lifecycle, such as "POST /v1/tickets/:id:publish".
+func (s *Server) registerTicketsStatusRoutesV1(router *customMethodRouter) {
+	router.HandlerFunc(http.MethodPost, "/v1/tickets/:id", "publish", s.handleTransitionTicket(tixer.StatusOnSale))
+
+	router.HandlerFunc(http.MethodPost, "/v1/tickets/:id", "cancel", s.handleTransitionTicket(tixer.StatusCancelled))
+
+	router.HandlerFunc(http.MethodPost, "/v1/tickets/:id", "archive", s.handleTransitionTicket(tixer.StatusArchived))
+}
+
+// handleTransitionTicket returns the handler moving a ticket to the next status.
+//
+// A transition which the current status of the ticket does not allow is
+// rejected with a 409 Conflict. Like the updates, it honors the If-Match header.
+func (s *Server) handleTransitionTicket(next tixer.TicketStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := web.ReadIDParam(r)
+		if err != nil {
+			web.BadRequestResponse(s.Logger, w, r, err)
+			return
+		}
+
+		version, err := s.expectedVersion(r.Context(), r, tixer.TicketID(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, tixer.ErrVersionConflict):
+				preconditionFailedResponse(s.Logger, w, r)
+			case errors.Is(err, tixer.ErrTicketNotFound):
+				web.NotFoundResponse(s.Logger, w, r)
+			default:
+				web.ServerErrorResponse(s.Logger, w, r, err)
+			}
+
+			return
+		}
+
+		tck, err := s.TicketService.TransitionTicket(r.Context(), tixer.TicketID(id), next, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, tixer.ErrTicketNotFound):
+				web.NotFoundResponse(s.Logger, w, r)
+			case errors.Is(err, tixer.ErrVersionConflict):
+				preconditionFailedResponse(s.Logger, w, r)
+			case errors.Is(err, tixer.ErrInvalidTransition):
+				conflictResponse(s.Logger, w, r, err.Error())
+			default:
+				web.ServerErrorResponse(s.Logger, w, r, err)
+			}
+
+			return
+		}
+
+		headers := make(http.Header)
+		headers.Set("ETag", ticketETag(tck))
+
+		err = web.WriteJSON(w, http.StatusOK, web.Envelope{"ticket": mapTicketToResponse(tck)}, headers)
+		if err != nil {
+			web.ServerErrorResponse(s.Logger, w, r, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateTicket_CreatesTheTicketOnSale(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+	if !strings.Contains(rec.Body.String(), `"status":"on_sale"`) {
+		t.Errorf("Got ticket %s, want it on sale", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR","status":"sold_out"}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got status code %d for a sold out ticket, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestTransitionTicket_PublishesTheCreatedDraft(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR","status":"draft"}`)))
+	location, etag := rec.Header().Get("Location"), rec.Header().Get("ETag")
+
+	var body struct {
+		Ticket struct {
+			Status string `json:"status"`
+		} `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if body.Ticket.Status != "draft" {
+		t.Errorf("Got status %q on create, want %q", body.Ticket.Status, "draft")
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, location+":publish", nil)
+	req.Header.Set("If-Match", etag)
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if body.Ticket.Status != "on_sale" {
+		t.Errorf("Got status %q, want %q", body.Ticket.Status, "on_sale")
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Errorf("The ETag %s should change after a transition", etag)
+	}
+}
+
+func TestTransitionTicket_RespondsWithConflictForAnInvalidTransition(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR","status":"draft"}`)))
+	location := rec.Header().Get("Location")
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, location+":archive", nil))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Got status code %d for archiving a draft, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, location+":cancel", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d for cancelling a draft, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, location+":publish", nil))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Got status code %d for publishing a cancelled ticket, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestReadTickets_FiltersByStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	for _, body := range []string{
+		`{"title":"Concert","price":"150.00","currency":"EUR","status":"draft"}`,
+		`{"title":"Opera","price":"150.00","currency":"EUR"}`,
+	} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets?status=on_sale", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Tickets []struct {
+			Title string `json:"title"`
+		} `json:"tickets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if len(body.Tickets) != 1 || body.Tickets[0].Title != "Opera" {
+		t.Errorf("Got tickets %+v, want only the published one", body.Tickets)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets?status=bogus", nil))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got status code %d for an unknown status, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,493 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateTicket_RespondsWithTheCreatedTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`))
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	var body struct {
+		Ticket struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+			Price string `json:"price"`
+		} `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	if body.Ticket.Title != "Concert" || body.Ticket.Price != "150.00" {
+		t.Errorf("Got ticket %+v", body.Ticket)
+	}
+	if got, want := rec.Header().Get("Location"), "/v1/tickets/"+body.Ticket.ID; got != want {
+		t.Errorf("Got location %q, want %q", got, want)
+	}
+}
+
+func TestCreateTicket_RespondsWithValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"","price":-1}`))
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCreateTicket_RespondsWithTheEventAndSeating(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{
+		"event_id":"rock-in-rio-2024","title":"Rock in Rio","venue":"Cidade do Rock",
+		"starts_at":"2030-09-13T18:30:00-03:00","section":"A","row":"12","seat":"7",
+		"price":150,"currency":"BRL"
+	}`))
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	var body struct {
+		Ticket map[string]any `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	want := map[string]any{
+		"event_id":  "rock-in-rio-2024",
+		"venue":     "Cidade do Rock",
+		"starts_at": "2030-09-13T21:30:00Z",
+		"section":   "A",
+		"row":       "12",
+		"seat":      "7",
+		"price":     "150.00",
+		"currency":  "BRL",
+	}
+	for key, value := range want {
+		if body.Ticket[key] != value {
+			t.Errorf("Got %s %v, want %v", key, body.Ticket[key], value)
+		}
+	}
+	if _, ok := body.Ticket["description"]; ok {
+		t.Errorf("Got a description, want none")
+	}
+}
+
+func TestCreateTicket_RespondsWithValidationErrorsForASeatWithoutRow(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":150,"seat":"7","currency":"XYZ"}`))
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	for _, field := range []string{`"row"`, `"currency"`} {
+		if !strings.Contains(rec.Body.String(), field) {
+			t.Errorf("Got body %s, want an error for %s", rec.Body.String(), field)
+		}
+	}
+}
+
+func TestCreateTicket_AcceptsANumericPrice(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":19.99,"currency":"EUR"}`))
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if !strings.Contains(rec.Body.String(), `"price":"19.99","currency":"EUR"`) {
+		t.Errorf("Got body %s, want the exact price", rec.Body.String())
+	}
+}
+
+func TestCreateTicket_RespondsWithValidationErrorsForExtraDecimals(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	for _, body := range []string{
+		`{"title":"Concert","price":"12.505","currency":"EUR"}`,
+		`{"title":"Concert","price":"1500.5","currency":"JPY"}`,
+		`{"title":"Concert","price":"12.50"}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Got status code %d for %s, want %d", rec.Code, body, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestReadTicket_RespondsWithNotFoundForAMissingTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets/"+uuid.NewString(), nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestDeleteTicket_RespondsWithNotFoundForAMissingTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/tickets/"+uuid.NewString(), nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateTicket_RespondsWithPreconditionFailedForAStaleETag(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+	location, etag := rec.Header().Get("Location"), rec.Header().Get("ETag")
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, location, strings.NewReader(`{"title":"Opera"}`))
+	req.Header.Set("If-Match", etag)
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Errorf("The ETag %s should change after an update", etag)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPatch, location, strings.NewReader(`{"title":"Ballet"}`))
+	req.Header.Set("If-Match", etag)
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+}
+
+func TestReplaceTicket_CreatesThenReplacesTheTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	target := "/v1/tickets/" + uuid.NewString()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d on create, want %d", rec.Code, http.StatusCreated)
+	}
+	if got := rec.Header().Get("Location"); got != target {
+		t.Errorf("Got location %q, want %q", got, target)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d on replace, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCreateTicket_ReplaysTheResponseForARetriedIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	key := uuid.NewString()
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		srv.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	first := create(`{"title":"Concert","price":"150.00","currency":"EUR"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d, want %d", first.Code, http.StatusCreated)
+	}
+
+	retry := create(`{"title":"Concert","price":"150.00","currency":"EUR"}`)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("Got status code %d on retry, want %d", retry.Code, http.StatusCreated)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Got body %s on retry, want %s", retry.Body, first.Body)
+	}
+	if retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("Got location %q on retry, want %q", retry.Header().Get("Location"), first.Header().Get("Location"))
+	}
+
+	reused := create(`{"title":"Opera","price":"10.00","currency":"EUR"}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got status code %d for a reused key, want %d", reused.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets", nil))
+	if !strings.Contains(rec.Body.String(), `"total":1`) {
+		t.Errorf("Got %s, want a single ticket to be created", rec.Body)
+	}
+}
+
+func TestReadTickets_FiltersAndSortsTheTickets(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	for _, body := range []string{
+		`{"title":"Concert","price":"30.00","currency":"EUR"}`,
+		`{"title":"Concert","price":"10.00","currency":"EUR"}`,
+		`{"title":"Opera","price":"20.00","currency":"EUR"}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets?title=Con&currency=EUR&price_max=50&sort=-price", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Tickets []struct {
+			Price string `json:"price"`
+		} `json:"tickets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if len(body.Tickets) != 2 || body.Tickets[0].Price != "30.00" || body.Tickets[1].Price != "10.00" {
+		t.Errorf("Got tickets %+v, want the concerts by descending price", body.Tickets)
+	}
+}
+
+func TestReadTickets_RespondsWithValidationErrorsForInvalidFilters(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	for _, query := range []string{
+		"sort=date",
+		"currency=EUR&price_min=20&price_max=10",
+		"price_min=20",
+		"currency=JPY&price_min=20.5",
+		"currency=XYZ",
+		"created_after=yesterday",
+		"include_deleted=maybe",
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets?"+query, nil))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Got status code %d for %q, want %d", rec.Code, query, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestReadTickets_PagesWithTheNextAndPrevLinks(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	for _, body := range []string{
+		`{"title":"Concert","price":"10.00","currency":"EUR"}`,
+		`{"title":"Concert","price":"20.00","currency":"EUR"}`,
+		`{"title":"Concert","price":"30.00","currency":"EUR"}`,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(body)))
+	}
+
+	type page struct {
+		Tickets []struct {
+			Price string `json:"price"`
+		} `json:"tickets"`
+		Pagination struct {
+			Next string `json:"next"`
+			Prev string `json:"prev"`
+		} `json:"pagination"`
+	}
+	read := func(url string) page {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Got status code %d for %s, want %d", rec.Code, url, http.StatusOK)
+		}
+
+		var p page
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+			t.Fatalf("could not decode the response: %v", err)
+		}
+		return p
+	}
+
+	first := read("/v1/tickets?limit=2&sort=price")
+	if len(first.Tickets) != 2 || first.Pagination.Next == "" || first.Pagination.Prev != "" {
+		t.Fatalf("Got first page %+v", first)
+	}
+
+	second := read(first.Pagination.Next)
+	if len(second.Tickets) != 1 || second.Tickets[0].Price != "30.00" || second.Pagination.Prev == "" {
+		t.Fatalf("Got second page %+v", second)
+	}
+
+	prev := read(second.Pagination.Prev)
+	if len(prev.Tickets) != 2 || prev.Tickets[0].Price != "10.00" || prev.Tickets[1].Price != "20.00" {
+		t.Errorf("Got previous page %+v", prev)
+	}
+}
+
+func TestReadTickets_RespondsWithBadRequestForAForgedCursor(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"10.00","currency":"EUR"}`)))
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets", nil))
+	var body struct {
+		Pagination struct {
+			After string `json:"after"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+
+	for _, query := range []string{
+		"after=" + uuid.NewString(),
+		"after=" + body.Pagination.After + "x",
+		"after=" + body.Pagination.After + "&sort=price",
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tickets?"+query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Got status code %d for %q, want %d", rec.Code, query, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRestoreTicket_RestoresADeletedTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tickets", strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+	location := rec.Header().Get("Location")
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, location, nil))
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location, nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Got status code %d for the deleted ticket, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location+"?include_deleted=true", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d for the included deleted ticket, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Ticket struct {
+			DateDeleted *time.Time `json:"date_deleted"`
+		} `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("could not decode the response: %v", err)
+	}
+	if body.Ticket.DateDeleted == nil {
+		t.Error("The deleted ticket should have its deletion date")
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, location+":restore", nil)
+	req.Header.Set("If-Match", `"2"`)
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d on restore, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status code %d for the restored ticket, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReplaceTicket_RespondsWithConflictForADeletedTicket(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer()
+	target := "/v1/tickets/" + uuid.NewString()
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, target, nil))
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title":"Concert","price":"150.00","currency":"EUR"}`)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Got status code %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
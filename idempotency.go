@@ -0,0 +1,64 @@
+package tixer
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// IdempotencyRecord represents a request made with an idempotency key.
+	//
+	// Fingerprint identifies the request the key was first used with, so that
+	// reusing the key for a different request can be detected. Response is nil
+	// while the request is in progress.
+	IdempotencyRecord struct {
+		Key         string
+		Fingerprint string
+		Response    *IdempotentResponse
+		DateExpires time.Time
+	}
+
+	// IdempotentResponse represents the response replayed when a request
+	// is retried with the same idempotency key.
+	IdempotentResponse struct {
+		StatusCode int
+		Header     map[string][]string
+		Body       []byte
+	}
+
+	// IdempotencyService represents a service for storing idempotency keys.
+	//
+	// Records are kept until their DateExpires, after which the key can be used again.
+	IdempotencyService interface {
+		// ReserveKey reserves the key of the record for a new request.
+		// When the key is already reserved, it returns the stored record and false
+		// if its response can be replayed, ErrIdempotencyKeyReused if the fingerprints
+		// differ, or ErrIdempotencyKeyInProgress if the first request did not complete.
+		ReserveKey(ctx context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error)
+
+		// CompleteKey stores the record holding the response of the request made
+		// with the key, in place of its reservation, the record returned by ReserveKey.
+		//
+		// CompleteKey and ReleaseKey return ErrIdempotencyKeyLost when the key is no
+		// longer reserved as it was, such as when the reservation expired and the key
+		// was reserved again by another request. The stored record is then left as is.
+		CompleteKey(ctx context.Context, reservation, record IdempotencyRecord) error
+
+		// ReleaseKey removes the reservation of the key, so that the request can be retried.
+		ReleaseKey(ctx context.Context, reservation IdempotencyRecord) error
+	}
+)
+
+// Reserves reports whether the record is still the given reservation of its key:
+// a request in progress with the same fingerprint and expiry date.
+func (r IdempotencyRecord) Reserves(reservation IdempotencyRecord) bool {
+	return r.Response == nil &&
+		r.Key == reservation.Key &&
+		r.Fingerprint == reservation.Fingerprint &&
+		r.DateExpires.Equal(reservation.DateExpires)
+}
+
+// Expired reports whether the record can be discarded at the given time.
+func (r IdempotencyRecord) Expired(now time.Time) bool {
+	return !now.Before(r.DateExpires)
+}
@@ -0,0 +1,73 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// IdempotencyStorer persists idempotency keys in memory.
+//
+// Expired keys are discarded lazily, when a key is reserved.
+type IdempotencyStorer struct {
+	mu      sync.Mutex
+	records map[string]tixer.IdempotencyRecord
+}
+
+func NewIdempotencyStorer() *IdempotencyStorer {
+	return &IdempotencyStorer{
+		records: make(map[string]tixer.IdempotencyRecord),
+	}
+}
+
+func (s *IdempotencyStorer) ReserveKey(ctx context.Context, record tixer.IdempotencyRecord) (tixer.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, rec := range s.records {
+		if rec.Expired(now) {
+			delete(s.records, key)
+		}
+	}
+
+	stored, ok := s.records[record.Key]
+	switch {
+	case !ok:
+		record.Response = nil
+		s.records[record.Key] = record
+		return record, true, nil
+	case stored.Fingerprint != record.Fingerprint:
+		return tixer.IdempotencyRecord{}, false, tixer.ErrIdempotencyKeyReused
+	case stored.Response == nil:
+		return tixer.IdempotencyRecord{}, false, tixer.ErrIdempotencyKeyInProgress
+	}
+
+	return stored, false, nil
+}
+
+func (s *IdempotencyStorer) CompleteKey(ctx context.Context, reservation, record tixer.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.records[reservation.Key].Reserves(reservation) {
+		return tixer.ErrIdempotencyKeyLost
+	}
+	s.records[record.Key] = record
+
+	return nil
+}
+
+func (s *IdempotencyStorer) ReleaseKey(ctx context.Context, reservation tixer.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.records[reservation.Key].Reserves(reservation) {
+		return tixer.ErrIdempotencyKeyLost
+	}
+	delete(s.records, reservation.Key)
+
+	return nil
+}
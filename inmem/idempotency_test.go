@@ -0,0 +1,44 @@
+package inmem_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/inmem"
+)
+
+func TestIdempotencyStorer_KeepsTheKeyReservedAgainAfterExpiring(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := inmem.NewIdempotencyStorer()
+
+	expired, _, err := s.ReserveKey(ctx, tixer.IdempotencyRecord{Key: "key", Fingerprint: "fp", DateExpires: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("ReserveKey: %v", err)
+	}
+	current, reserved, err := s.ReserveKey(ctx, tixer.IdempotencyRecord{Key: "key", Fingerprint: "fp", DateExpires: time.Now().Add(time.Minute)})
+	if err != nil || !reserved {
+		t.Fatalf("Got reserved=%t and error %v, want the expired key reserved again", reserved, err)
+	}
+
+	completed := expired
+	completed.Response = &tixer.IdempotentResponse{StatusCode: 201}
+	if err := s.CompleteKey(ctx, expired, completed); !errors.Is(err, tixer.ErrIdempotencyKeyLost) {
+		t.Errorf("Got CompleteKey error %v, want ErrIdempotencyKeyLost", err)
+	}
+	if err := s.ReleaseKey(ctx, expired); !errors.Is(err, tixer.ErrIdempotencyKeyLost) {
+		t.Errorf("Got ReleaseKey error %v, want ErrIdempotencyKeyLost", err)
+	}
+
+	// The second request is still in progress.
+	if _, _, err := s.ReserveKey(ctx, current); !errors.Is(err, tixer.ErrIdempotencyKeyInProgress) {
+		t.Errorf("Got ReserveKey error %v, want ErrIdempotencyKeyInProgress", err)
+	}
+	if err := s.ReleaseKey(ctx, current); err != nil {
+		t.Errorf("ReleaseKey: %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// OrderStorer persists orders in memory.
+//
+// The units held by the orders are kept in the holds of the tickets storer,
+// and the orders are guarded by its lock, so that an order is created along
+// with the holds of all its tickets.
+type OrderStorer struct {
+	tickets *Storer
+	orders  map[tixer.OrderID]tixer.Order
+}
+
+func NewOrderStorer(tickets *Storer) *OrderStorer {
+	return &OrderStorer{
+		tickets: tickets,
+		orders:  make(map[tixer.OrderID]tixer.Order),
+	}
+}
+
+// CreateOrder stores a new order, holding the units of its tickets.
+//
+// All the tickets are checked before any of them is held,
+// so that nothing is held when one of them cannot be.
+func (s *OrderStorer) CreateOrder(ctx context.Context, order tixer.Order, ttl time.Duration) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	if _, ok := s.orders[order.ID]; ok {
+		return tixer.Order{}, tixer.ErrOrderAlreadyExists
+	}
+
+	now := time.Now().UTC()
+	order.Status = tixer.OrderPending
+	order.Version = 1
+	order.DateCreated = now
+	order.DateUpdated = now
+	order.DateExpires = now.Add(ttl)
+
+	// The expired holds are removed from the holds even when the order is rejected.
+	defer func() {
+		for _, item := range order.Items {
+			s.tickets.syncHeld(item.TicketID)
+		}
+	}()
+
+	tickets := make([]tixer.Ticket, len(order.Items))
+	for i, item := range order.Items {
+		tck, err := s.checkHold(order, item, now)
+		if err != nil {
+			return tixer.Order{}, fmt.Errorf("ticket %s: %w", item.TicketID, err)
+		}
+		tickets[i] = tck
+	}
+
+	order, err := order.WithPrices(tickets)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	for _, item := range order.Items {
+		holds := s.tickets.holds[item.TicketID]
+		if holds == nil {
+			holds = make(map[string]tixer.Hold)
+			s.tickets.holds[item.TicketID] = holds
+		}
+		holds[order.Holder()] = tixer.Hold{TicketID: item.TicketID, Holder: order.Holder(), Quantity: item.Quantity, DateExpires: order.DateExpires}
+	}
+	s.orders[order.ID] = copyOrder(order)
+
+	return order, nil
+}
+
+func (s *OrderStorer) ReadOrder(ctx context.Context, id tixer.OrderID) (tixer.Order, error) {
+	s.tickets.mu.RLock()
+	defer s.tickets.mu.RUnlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+
+	return copyOrder(order), nil
+}
+
+// CancelOrder cancels a pending order, releasing the units it holds.
+//
+// The holds which expired and were removed in the meantime,
+// and the tickets which were deleted, are skipped.
+func (s *OrderStorer) CancelOrder(ctx context.Context, id tixer.OrderID) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	if err := order.CheckTransition(tixer.OrderCancelled); err != nil {
+		return tixer.Order{}, err
+	}
+
+	for _, item := range order.Items {
+		if _, ok := s.tickets.live(item.TicketID); !ok {
+			continue
+		}
+		delete(s.tickets.holds[item.TicketID], order.Holder())
+		s.tickets.syncHeld(item.TicketID)
+	}
+
+	order.Status = tixer.OrderCancelled
+	order.Version++
+	order.DateUpdated = time.Now().UTC()
+	s.orders[id] = order
+
+	return copyOrder(order), nil
+}
+
+// PayOrder sells the units held by a pending order, and marks it paid.
+//
+// All the holds of the order are checked before any of them is sold,
+// so that nothing is sold when one of them expired.
+func (s *OrderStorer) PayOrder(ctx context.Context, id tixer.OrderID, payment tixer.Payment) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	if order.PaidBy(payment) {
+		return copyOrder(order), nil
+	}
+
+	now := time.Now().UTC()
+	if err := order.CheckPayment(payment, now); err != nil {
+		return tixer.Order{}, err
+	}
+
+	tickets := make([]tixer.Ticket, len(order.Items))
+	holds := make([]tixer.Hold, len(order.Items))
+	for i, item := range order.Items {
+		tck, ok := s.tickets.live(item.TicketID)
+		if !ok {
+			return tixer.Order{}, fmt.Errorf("ticket %s: %w", item.TicketID, tixer.ErrTicketNotFound)
+		}
+		if tck.Status != tixer.StatusOnSale {
+			return tixer.Order{}, fmt.Errorf("ticket %s: %w", item.TicketID, tixer.ErrTicketNotOnSale)
+		}
+		hold, ok := s.tickets.holds[item.TicketID][order.Holder()]
+		if !ok || hold.Expired(now) {
+			return tixer.Order{}, fmt.Errorf("ticket %s: %w", item.TicketID, tixer.ErrOrderExpired)
+		}
+		tickets[i], holds[i] = tck, hold
+	}
+
+	for i := range order.Items {
+		s.tickets.sell(tickets[i], holds[i])
+	}
+
+	order = order.Pay(payment, now)
+	s.orders[id] = order
+
+	return copyOrder(order), nil
+}
+
+// RefundOrder adds a pending refund to the ledger of a paid order.
+func (s *OrderStorer) RefundOrder(ctx context.Context, id tixer.OrderID, refund tixer.OrderRefund) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	if order.RefundedBy(refund.ID) {
+		return copyOrder(order), nil
+	}
+	if err := order.CheckRefund(refund); err != nil {
+		return tixer.Order{}, err
+	}
+
+	order = order.Refund(refund, time.Now().UTC())
+	s.orders[id] = order
+
+	return copyOrder(order), nil
+}
+
+// ConfirmRefund marks a pending refund of an order succeeded, releasing its units.
+//
+// The refund is checked before any unit is released,
+// and the tickets which were deleted are skipped.
+func (s *OrderStorer) ConfirmRefund(ctx context.Context, id tixer.OrderID, refundID, paymentRefundID string) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	refund, err := order.FindRefund(refundID)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+	if refund.Status == tixer.RefundSucceeded {
+		return copyOrder(order), nil
+	}
+
+	now := time.Now().UTC()
+	order, err = order.ConfirmRefund(refundID, paymentRefundID, now)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	for _, item := range refund.Items {
+		tck, ok := s.tickets.live(item.TicketID)
+		if !ok {
+			continue
+		}
+
+		tck = tck.Restock(item.Quantity)
+		tck.Version++
+		tck.DateUpdated = now
+		s.tickets.tickets[tck.ID] = tck
+	}
+	s.orders[id] = order
+
+	return copyOrder(order), nil
+}
+
+// CancelRefund marks a pending refund of an order failed.
+func (s *OrderStorer) CancelRefund(ctx context.Context, id tixer.OrderID, refundID string) (tixer.Order, error) {
+	s.tickets.mu.Lock()
+	defer s.tickets.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return tixer.Order{}, tixer.ErrOrderNotFound
+	}
+	refund, err := order.FindRefund(refundID)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+	if refund.Status == tixer.RefundFailed {
+		return copyOrder(order), nil
+	}
+
+	order, err = order.CancelRefund(refundID, time.Now().UTC())
+	if err != nil {
+		return tixer.Order{}, err
+	}
+	s.orders[id] = order
+
+	return copyOrder(order), nil
+}
+
+// checkHold reports whether the units of an item can be held by the order,
+// the same way Storer.Hold does, and returns the ticket of the item.
+func (s *OrderStorer) checkHold(order tixer.Order, item tixer.OrderItem, now time.Time) (tixer.Ticket, error) {
+	tck, ok := s.tickets.live(item.TicketID)
+	if !ok {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+	if tck.Status != tixer.StatusOnSale {
+		return tixer.Ticket{}, tixer.ErrTicketNotOnSale
+	}
+	if err := tck.CheckHold(s.tickets.holds[item.TicketID], order.Holder(), item.Quantity, now); err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return tck, nil
+}
+
+// copyOrder returns a copy of the order which does not share its items nor its refunds,
+// so that the stored orders cannot be changed by the callers.
+func copyOrder(order tixer.Order) tixer.Order {
+	order.Items = append([]tixer.OrderItem(nil), order.Items...)
+
+	var refunds []tixer.OrderRefund
+	for _, r := range order.Refunds {
+		r.Items = append([]tixer.OrderItem(nil), r.Items...)
+		refunds = append(refunds, r)
+	}
+	order.Refunds = refunds
+
+	return order
+}
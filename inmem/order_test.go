@@ -0,0 +1,18 @@
+package inmem_test
+
+import (
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/inmem"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+func TestOrderStorer(t *testing.T) {
+	t.Parallel()
+
+	tixertest.RunOrderServiceSuite(t, func(t *testing.T) (tixer.TicketService, tixer.OrderService) {
+		s := inmem.NewStorer()
+		return s, inmem.NewOrderStorer(s)
+	})
+}
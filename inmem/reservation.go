@@ -0,0 +1,132 @@
+package inmem
+
+import (
+	"context"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// Hold holds units of a ticket for the holder, or replaces its hold.
+//
+// The expired holds of the other holders are removed first,
+// so that their units can be held again.
+func (s *Storer) Hold(ctx context.Context, ticketID tixer.TicketID, holder string, quantity int, ttl time.Duration) (tixer.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.live(ticketID)
+	if !ok {
+		return tixer.Hold{}, tixer.ErrTicketNotFound
+	}
+	if tck.Status != tixer.StatusOnSale {
+		return tixer.Hold{}, tixer.ErrTicketNotOnSale
+	}
+
+	now := time.Now().UTC()
+	holds := s.holds[ticketID]
+	if holds == nil {
+		holds = make(map[string]tixer.Hold)
+		s.holds[ticketID] = holds
+	}
+	// The expired holds are removed from holds even when the hold is rejected.
+	defer s.syncHeld(ticketID)
+
+	if err := tck.CheckHold(holds, holder, quantity, now); err != nil {
+		return tixer.Hold{}, err
+	}
+
+	hold := tixer.Hold{TicketID: ticketID, Holder: holder, Quantity: quantity, DateExpires: now.Add(ttl)}
+	holds[holder] = hold
+
+	return hold, nil
+}
+
+// Release removes the hold of the holder on a ticket.
+func (s *Storer) Release(ctx context.Context, ticketID tixer.TicketID, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The holds of a deleted ticket are kept along with it, out of reach.
+	if _, ok := s.live(ticketID); !ok {
+		return tixer.ErrHoldNotFound
+	}
+	if _, ok := s.holds[ticketID][holder]; !ok {
+		return tixer.ErrHoldNotFound
+	}
+	delete(s.holds[ticketID], holder)
+	s.syncHeld(ticketID)
+
+	return nil
+}
+
+// Confirm sells the units of a ticket held by the holder.
+func (s *Storer) Confirm(ctx context.Context, ticketID tixer.TicketID, holder string) (tixer.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.live(ticketID)
+	if !ok {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+	if tck.Status != tixer.StatusOnSale {
+		return tixer.Ticket{}, tixer.ErrTicketNotOnSale
+	}
+
+	hold, ok := s.holds[ticketID][holder]
+	if !ok || hold.Expired(time.Now()) {
+		return tixer.Ticket{}, tixer.ErrHoldNotFound
+	}
+
+	return s.sell(tck, hold), nil
+}
+
+// ReleaseExpired removes the holds expired at the given time.
+func (s *Storer) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var released int
+	for id, holds := range s.holds {
+		if _, ok := s.live(id); !ok {
+			continue
+		}
+		for holder, hold := range holds {
+			if hold.Expired(now) {
+				delete(holds, holder)
+				released++
+			}
+		}
+		s.syncHeld(id)
+	}
+
+	return released, nil
+}
+
+// sell sells the units of a ticket held by the hold, in place of the hold,
+// and returns the updated ticket.
+func (s *Storer) sell(tck tixer.Ticket, hold tixer.Hold) tixer.Ticket {
+	tck = tck.Sell(hold.Quantity)
+	tck.Version++
+	tck.DateUpdated = time.Now().UTC()
+	s.tickets[tck.ID] = tck
+
+	delete(s.holds[tck.ID], hold.Holder)
+	s.syncHeld(tck.ID)
+
+	return s.tickets[tck.ID]
+}
+
+// syncHeld counts the units held by the holds of a ticket into the ticket,
+// and removes the holds of the ticket once none is left.
+func (s *Storer) syncHeld(ticketID tixer.TicketID) {
+	holds := s.holds[ticketID]
+	if len(holds) == 0 {
+		delete(s.holds, ticketID)
+	}
+
+	if tck, ok := s.tickets[ticketID]; ok {
+		tck.Held = tixer.HeldUnits(holds)
+		s.tickets[ticketID] = tck
+	}
+}
@@ -0,0 +1,392 @@
+// Package inmem implements ticket service in memory.
+//
+// It is meant for tests and local development, where a Firebase project
+// is not available. Data is lost once the process exits.
+package inmem
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// Storer persists tickets in memory.
+//
+// The holds of the tickets are kept by ticket, then by holder,
+// and the units they hold are counted into the Held of their ticket.
+type Storer struct {
+	mu      sync.RWMutex
+	tickets map[tixer.TicketID]tixer.Ticket
+	holds   map[tixer.TicketID]map[string]tixer.Hold
+}
+
+func NewStorer() *Storer {
+	return &Storer{
+		tickets: make(map[tixer.TicketID]tixer.Ticket),
+		holds:   make(map[tixer.TicketID]map[string]tixer.Hold),
+	}
+}
+
+// CreateTicket stores a new ticket.
+//
+// The creation date is set by the storer, the same way Firestore
+// sets it through a server timestamp.
+func (s *Storer) CreateTicket(ctx context.Context, ticket tixer.Ticket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tickets[ticket.ID]; ok {
+		return tixer.ErrTicketAlreadyExists
+	}
+
+	s.tickets[ticket.ID] = newTicket(ticket)
+
+	return nil
+}
+
+// CreateTickets stores new tickets, all of them or none of them.
+func (s *Storer) CreateTickets(ctx context.Context, tickets []tixer.Ticket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[tixer.TicketID]bool, len(tickets))
+	for _, ticket := range tickets {
+		if _, ok := s.tickets[ticket.ID]; ok || seen[ticket.ID] {
+			return tixer.ErrTicketAlreadyExists
+		}
+		seen[ticket.ID] = true
+	}
+
+	for _, ticket := range tickets {
+		s.tickets[ticket.ID] = newTicket(ticket)
+	}
+
+	return nil
+}
+
+func (s *Storer) ReadTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tck, ok := s.live(id)
+	if !ok {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+
+	return tck, nil
+}
+
+// UpdateTicket applies the fields provided by the update to a ticket.
+func (s *Storer) UpdateTicket(ctx context.Context, update tixer.TicketUpdate) (tixer.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.live(update.ID)
+	if !ok {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+	if err := tck.CheckVersion(update.Version); err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	tck = update.Apply(tck)
+	tck.Version++
+	tck.DateUpdated = time.Now().UTC()
+
+	s.tickets[update.ID] = tck
+
+	return tck, nil
+}
+
+// UpdateTickets applies a batch of updates.
+func (s *Storer) UpdateTickets(ctx context.Context, updates []tixer.TicketUpdate) ([]tixer.TicketResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]tixer.TicketResult, len(updates))
+	for i, update := range updates {
+		tck, ok := s.live(update.ID)
+		if !ok {
+			results[i].Err = tixer.ErrTicketNotFound
+			continue
+		}
+		if err := tck.CheckVersion(update.Version); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		tck = update.Apply(tck)
+		tck.Version++
+		tck.DateUpdated = time.Now().UTC()
+		s.tickets[update.ID] = tck
+
+		results[i].Ticket = tck
+	}
+
+	return results, nil
+}
+
+// ReplaceTicket replaces the editable fields of a ticket,
+// or creates the ticket when it does not exist.
+func (s *Storer) ReplaceTicket(ctx context.Context, ticket tixer.Ticket) (tixer.Ticket, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.tickets[ticket.ID]
+	if ok && !tck.DateDeleted.IsZero() {
+		return tixer.Ticket{}, false, tixer.ErrTicketDeleted
+	}
+	if !ok {
+		if ticket.Version != tixer.AnyVersion {
+			return tixer.Ticket{}, false, tixer.ErrVersionConflict
+		}
+
+		tck = newTicket(ticket)
+		s.tickets[ticket.ID] = tck
+
+		return tck, true, nil
+	}
+	if err := tck.CheckVersion(ticket.Version); err != nil {
+		return tixer.Ticket{}, false, err
+	}
+
+	ticket.Version = tck.Version + 1
+	ticket.Status = tck.Status
+	ticket.Capacity = tck.Capacity
+	ticket.Sold = tck.Sold
+	ticket.Held = tck.Held
+	ticket.DateCreated = tck.DateCreated
+	ticket.DateUpdated = time.Now().UTC()
+	s.tickets[ticket.ID] = ticket
+
+	return ticket, false, nil
+}
+
+// TransitionTicket moves a ticket to the next status.
+func (s *Storer) TransitionTicket(ctx context.Context, id tixer.TicketID, next tixer.TicketStatus, version int) (tixer.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.live(id)
+	if !ok {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+	if err := tck.CheckVersion(version); err != nil {
+		return tixer.Ticket{}, err
+	}
+	if err := tck.CheckTransition(next); err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	tck.Status = next
+	tck.Version++
+	tck.DateUpdated = time.Now().UTC()
+	s.tickets[id] = tck
+
+	return tck, nil
+}
+
+// DeleteTicket soft deletes a ticket, which is kept until it is restored or purged.
+func (s *Storer) DeleteTicket(ctx context.Context, id tixer.TicketID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.live(id)
+	if !ok {
+		return tixer.ErrTicketNotFound
+	}
+	if err := tck.CheckVersion(version); err != nil {
+		return err
+	}
+	s.tickets[id] = deletedTicket(tck)
+
+	return nil
+}
+
+// DeleteTickets soft deletes a batch of tickets.
+func (s *Storer) DeleteTickets(ctx context.Context, ids []tixer.TicketID) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]error, len(ids))
+	for i, id := range ids {
+		tck, ok := s.live(id)
+		if !ok {
+			results[i] = tixer.ErrTicketNotFound
+			continue
+		}
+
+		s.tickets[id] = deletedTicket(tck)
+	}
+
+	return results, nil
+}
+
+func (s *Storer) ReadDeletedTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tck, ok := s.tickets[id]
+	if !ok || tck.DateDeleted.IsZero() {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+
+	return tck, nil
+}
+
+// RestoreTicket restores a deleted ticket.
+func (s *Storer) RestoreTicket(ctx context.Context, id tixer.TicketID, version int) (tixer.Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tck, ok := s.tickets[id]
+	if !ok || tck.DateDeleted.IsZero() {
+		return tixer.Ticket{}, tixer.ErrTicketNotFound
+	}
+	if err := tck.CheckVersion(version); err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	tck.DateDeleted = time.Time{}
+	tck.Version++
+	tck.DateUpdated = time.Now().UTC()
+	s.tickets[id] = tck
+
+	return tck, nil
+}
+
+// PurgeTickets removes the tickets deleted before a date.
+func (s *Storer) PurgeTickets(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int
+	for id, tck := range s.tickets {
+		if !tck.DateDeleted.IsZero() && tck.DateDeleted.Before(deletedBefore) {
+			delete(s.tickets, id)
+			delete(s.holds, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// ReadTickets reads a page of the tickets matching the filter, in the order of filter.Sort.
+//
+// The cursors behave like the Firestore StartAfter/EndBefore cursors,
+// see tixer.Filter.
+func (s *Storer) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var total int
+	sorted := make([]tixer.Ticket, 0, len(s.tickets))
+	for _, tck := range s.tickets {
+		if tck.DateDeleted.IsZero() {
+			total++
+		}
+		if filter.Match(tck) {
+			sorted = append(sorted, tck)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return less(filter.Sort, sorted[i], sorted[j])
+	})
+
+	if filter.After != nil {
+		after := filter.After.Ticket()
+		i := sort.Search(len(sorted), func(i int) bool {
+			return less(filter.Sort, after, sorted[i])
+		})
+		sorted = sorted[i:]
+	}
+	if filter.Before != nil {
+		before := filter.Before.Ticket()
+		i := sort.Search(len(sorted), func(i int) bool {
+			return !less(filter.Sort, sorted[i], before)
+		})
+		sorted = sorted[:i]
+	}
+
+	if filter.Limit > 0 && len(sorted) > filter.Limit {
+		if filter.Before != nil && filter.After == nil {
+			sorted = sorted[len(sorted)-filter.Limit:]
+		} else {
+			sorted = sorted[:filter.Limit]
+		}
+	}
+
+	var tt []tixer.Ticket
+	tt = append(tt, sorted...)
+
+	before, after := tixer.PageCursors(tt)
+
+	return tt, tixer.Metadata{
+		After:  after,
+		Before: before,
+		Total:  total,
+	}, nil
+}
+
+// live returns the ticket of the given ID, unless it is deleted.
+func (s *Storer) live(id tixer.TicketID) (tixer.Ticket, bool) {
+	tck, ok := s.tickets[id]
+	if !ok || !tck.DateDeleted.IsZero() {
+		return tixer.Ticket{}, false
+	}
+
+	return tck, true
+}
+
+// deletedTicket returns the ticket as it is stored when it is deleted.
+func deletedTicket(tck tixer.Ticket) tixer.Ticket {
+	tck.DateDeleted = time.Now().UTC()
+	tck.Version++
+
+	return tck
+}
+
+// newTicket returns the ticket as it is stored when it is created.
+func newTicket(ticket tixer.Ticket) tixer.Ticket {
+	ticket.Status = ticket.Status.OrDefault()
+	ticket.Capacity = ticket.CapacityOrDefault()
+	ticket.Sold = 0
+	ticket.Held = 0
+	ticket.Version = 1
+	ticket.DateCreated = time.Now().UTC()
+	ticket.DateUpdated = time.Time{}
+
+	return ticket
+}
+
+// less reports whether ticket a comes before ticket b in the given order.
+//
+// Ties are broken, the same way Firestore breaks them using
+// the document name, by ID in the direction of the order.
+func less(order tixer.TicketSort, a, b tixer.Ticket) bool {
+	field, desc := order.Field()
+	if desc {
+		a, b = b, a
+	}
+
+	switch field {
+	case "price":
+		if a.Price.Amount != b.Price.Amount {
+			return a.Price.Amount < b.Price.Amount
+		}
+	case "title":
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+	default:
+		if !a.DateCreated.Equal(b.DateCreated) {
+			return a.DateCreated.Before(b.DateCreated)
+		}
+	}
+
+	return a.ID.String() < b.ID.String()
+}
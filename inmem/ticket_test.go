@@ -0,0 +1,26 @@
+package inmem_test
+
+import (
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/inmem"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+func TestStorer(t *testing.T) {
+	t.Parallel()
+
+	tixertest.RunTicketServiceSuite(t, func(t *testing.T) tixer.TicketService {
+		return inmem.NewStorer()
+	})
+}
+
+func TestStorerReservations(t *testing.T) {
+	t.Parallel()
+
+	tixertest.RunReservationServiceSuite(t, func(t *testing.T) (tixer.TicketService, tixer.ReservationService) {
+		s := inmem.NewStorer()
+		return s, s
+	})
+}
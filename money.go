@@ -0,0 +1,186 @@
+package tixer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money represents an exact amount of money, as an integer number of
+// minor units of its currency, such as 1250 for 12.50 EUR or 1250 for 1250 JPY.
+//
+// It is encoded in JSON as an object holding the amount as a decimal string:
+//
+//	{"amount": "12.50", "currency": "EUR"}
+//
+// The arithmetic helpers only combine amounts of the same currency,
+// and return ErrCurrencyMismatch otherwise. They return ErrInvalidAmount
+// when the result does not fit in an int64.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// ParseMoney returns the money described by a decimal amount, such as "12.50",
+// in the currency. The amount must not have more decimals than the minor unit
+// of the currency, so "12.505" is rejected for EUR and "12.5" for JPY.
+//
+// The amounts which cannot be parsed are rejected with ErrInvalidAmount.
+func ParseMoney(amount string, currency Currency) (Money, error) {
+	digits := strings.TrimPrefix(amount, "-")
+	units, decimals, hasDot := strings.Cut(digits, ".")
+	if !isDigits(units) || (hasDot && !isDigits(decimals)) {
+		return Money{}, fmt.Errorf("%q: %w", amount, ErrInvalidAmount)
+	}
+	if len(decimals) > currency.MinorUnits() {
+		return Money{}, fmt.Errorf("%q has more than %d decimals for %s: %w", amount, currency.MinorUnits(), currency, ErrInvalidAmount)
+	}
+
+	decimals += strings.Repeat("0", currency.MinorUnits()-len(decimals))
+	minor, err := strconv.ParseInt(units+decimals, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%q: %w", amount, ErrInvalidAmount)
+	}
+	if len(digits) < len(amount) {
+		minor = -minor
+	}
+
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// Decimal returns the amount as a decimal number, with as many decimals
+// as the minor unit of the currency, such as "12.50" for 1250 EUR cents.
+func (m Money) Decimal() string {
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatUint(amount, 10)
+	units := m.Currency.MinorUnits()
+	if units == 0 {
+		return sign + digits
+	}
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
+
+// String returns the amount followed by the currency, such as "12.50 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns the sum of the amounts.
+func (m Money) Add(n Money) (Money, error) {
+	if m.Currency != n.Currency {
+		return Money{}, fmt.Errorf("%s and %s: %w", m.Currency, n.Currency, ErrCurrencyMismatch)
+	}
+
+	sum := m.Amount + n.Amount
+	if (n.Amount > 0 && sum < m.Amount) || (n.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%s + %s overflows: %w", m, n, ErrInvalidAmount)
+	}
+
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns the difference of the amounts.
+func (m Money) Sub(n Money) (Money, error) {
+	if m.Currency != n.Currency {
+		return Money{}, fmt.Errorf("%s and %s: %w", m.Currency, n.Currency, ErrCurrencyMismatch)
+	}
+
+	diff := m.Amount - n.Amount
+	if (n.Amount > 0 && diff > m.Amount) || (n.Amount < 0 && diff < m.Amount) {
+		return Money{}, fmt.Errorf("%s - %s overflows: %w", m, n, ErrInvalidAmount)
+	}
+
+	return Money{Amount: diff, Currency: m.Currency}, nil
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (m Money) Mul(quantity int64) (Money, error) {
+	if m.Amount == 0 || quantity == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+
+	product := m.Amount * quantity
+	if product/quantity != m.Amount || (quantity == -1 && m.Amount == math.MinInt64) {
+		return Money{}, fmt.Errorf("%s * %d overflows: %w", m, quantity, ErrInvalidAmount)
+	}
+
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Cmp compares the amounts, and returns -1, 0 or +1 when m is lower than,
+// equal to or greater than n.
+func (m Money) Cmp(n Money) (int, error) {
+	if m.Currency != n.Currency {
+		return 0, fmt.Errorf("%s and %s: %w", m.Currency, n.Currency, ErrCurrencyMismatch)
+	}
+
+	switch {
+	case m.Amount < n.Amount:
+		return -1, nil
+	case m.Amount > n.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// moneyJSON is the JSON representation of Money.
+type moneyJSON struct {
+	Amount   string   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{
+		Amount:   m.Decimal(),
+		Currency: m.Currency,
+	})
+}
+
+// UnmarshalJSON parses the amount with ParseMoney, so that it is rejected
+// when it has more decimals than the minor unit of the currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
+
+// isDigits reports whether s is a non empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,186 @@
+package tixer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+func TestParseMoney_ParsesTheAmountInMinorUnits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount   string
+		currency tixer.Currency
+		want     int64
+	}{
+		{"12.50", "EUR", 1250},
+		{"12.5", "EUR", 1250},
+		{"12", "EUR", 1200},
+		{"0.01", "EUR", 1},
+		{"-3.20", "EUR", -320},
+		{"-0", "EUR", 0},
+		{"1500", "JPY", 1500},
+		{"92233720368547758.07", "EUR", math.MaxInt64},
+	}
+	for _, tt := range tests {
+		got, err := tixer.ParseMoney(tt.amount, tt.currency)
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): %v", tt.amount, tt.currency, err)
+			continue
+		}
+		if want := (tixer.Money{Amount: tt.want, Currency: tt.currency}); got != want {
+			t.Errorf("ParseMoney(%q, %s) = %+v, want %+v", tt.amount, tt.currency, got, want)
+		}
+	}
+}
+
+func TestParseMoney_RejectsInvalidAmounts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount   string
+		currency tixer.Currency
+	}{
+		{"", "EUR"},
+		{"12.", "EUR"},
+		{".5", "EUR"},
+		{"-", "EUR"},
+		{"--5", "EUR"},
+		{"+5", "EUR"},
+		{"1e3", "EUR"},
+		{" 12", "EUR"},
+		{"12.505", "EUR"},
+		{"12.5", "JPY"},
+		{"1500.", "JPY"},
+		{"92233720368547758.08", "EUR"},
+		{"99999999999999999999", "JPY"},
+	}
+	for _, tt := range tests {
+		if got, err := tixer.ParseMoney(tt.amount, tt.currency); !errors.Is(err, tixer.ErrInvalidAmount) {
+			t.Errorf("ParseMoney(%q, %s) = %+v, %v, want ErrInvalidAmount", tt.amount, tt.currency, got, err)
+		}
+	}
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		money tixer.Money
+		want  string
+	}{
+		{tixer.Money{Amount: 1250, Currency: "EUR"}, "12.50"},
+		{tixer.Money{Amount: 5, Currency: "EUR"}, "0.05"},
+		{tixer.Money{Amount: 0, Currency: "EUR"}, "0.00"},
+		{tixer.Money{Amount: -320, Currency: "EUR"}, "-3.20"},
+		{tixer.Money{Amount: 1500, Currency: "JPY"}, "1500"},
+		{tixer.Money{Amount: 1999}, "19.99"},
+		{tixer.Money{Amount: math.MinInt64, Currency: "EUR"}, "-92233720368547758.08"},
+		{tixer.Money{Amount: math.MinInt64, Currency: "JPY"}, "-9223372036854775808"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("Decimal of %d %s = %q, want %q", tt.money.Amount, tt.money.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoney_RoundTripsThroughJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, m := range []tixer.Money{
+		{Amount: 1250, Currency: "EUR"},
+		{Amount: -1, Currency: "USD"},
+		{Amount: 1500, Currency: "JPY"},
+		{Amount: math.MaxInt64, Currency: "GBP"},
+	} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%+v): %v", m, err)
+		}
+
+		var got tixer.Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got != m {
+			t.Errorf("Got %+v from %s, want %+v", got, data, m)
+		}
+	}
+
+	data, err := json.Marshal(tixer.Money{Amount: 1250, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(data), `{"amount":"12.50","currency":"EUR"}`; got != want {
+		t.Errorf("Got %s, want %s", got, want)
+	}
+}
+
+func TestMoney_UnmarshalJSONRejectsExtraDecimals(t *testing.T) {
+	t.Parallel()
+
+	var m tixer.Money
+	if err := json.Unmarshal([]byte(`{"amount":"12.5","currency":"JPY"}`), &m); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("Got %v, want ErrInvalidAmount", err)
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	t.Parallel()
+
+	a := tixer.Money{Amount: 1250, Currency: "EUR"}
+	b := tixer.Money{Amount: 250, Currency: "EUR"}
+
+	if got, err := a.Add(b); err != nil || got.Amount != 1500 {
+		t.Errorf("Add = %+v, %v, want 1500", got, err)
+	}
+	if got, err := a.Sub(b); err != nil || got.Amount != 1000 {
+		t.Errorf("Sub = %+v, %v, want 1000", got, err)
+	}
+	if got, err := a.Mul(3); err != nil || got.Amount != 3750 {
+		t.Errorf("Mul = %+v, %v, want 3750", got, err)
+	}
+	if got, err := b.Cmp(a); err != nil || got != -1 {
+		t.Errorf("Cmp = %d, %v, want -1", got, err)
+	}
+
+	usd := tixer.Money{Amount: 250, Currency: "USD"}
+	if _, err := a.Add(usd); !errors.Is(err, tixer.ErrCurrencyMismatch) {
+		t.Errorf("Add of another currency: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := a.Cmp(usd); !errors.Is(err, tixer.ErrCurrencyMismatch) {
+		t.Errorf("Cmp of another currency: got %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestMoney_ArithmeticRejectsOverflows(t *testing.T) {
+	t.Parallel()
+
+	max := tixer.Money{Amount: math.MaxInt64, Currency: "EUR"}
+	min := tixer.Money{Amount: math.MinInt64, Currency: "EUR"}
+	one := tixer.Money{Amount: 1, Currency: "EUR"}
+
+	if _, err := max.Add(one); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("max + 1: got %v, want ErrInvalidAmount", err)
+	}
+	if _, err := min.Sub(one); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("min - 1: got %v, want ErrInvalidAmount", err)
+	}
+	if _, err := one.Sub(min); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("1 - min: got %v, want ErrInvalidAmount", err)
+	}
+	if _, err := max.Mul(2); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("max * 2: got %v, want ErrInvalidAmount", err)
+	}
+	if _, err := min.Mul(-1); !errors.Is(err, tixer.ErrInvalidAmount) {
+		t.Errorf("min * -1: got %v, want ErrInvalidAmount", err)
+	}
+	if got, err := min.Add(max); err != nil || got.Amount != -1 {
+		t.Errorf("min + max = %+v, %v, want -1", got, err)
+	}
+}
@@ -0,0 +1,532 @@
+package tixer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxOrderItems is the maximum number of line items of an order.
+//
+// Creating an order writes the document of every ticket it holds, along with
+// the order, so it stays far under the Firestore limit of 500 writes per commit.
+const MaxOrderItems = 50
+
+// MaxOrderQuantity is the maximum number of units of a ticket bought by a line item.
+const MaxOrderQuantity = 100
+
+// DefaultOrderTTL is how long a pending order holds its tickets
+// when no other duration is configured.
+const DefaultOrderTTL = 15 * time.Minute
+
+// OrderStatus represents the stage of the lifecycle of an order.
+//
+// An order is created pending, and only moves through the transitions of orderTransitions.
+type OrderStatus string
+
+// The statuses of an order.
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// OrderStatuses lists the statuses of an order.
+var OrderStatuses = []OrderStatus{OrderPending, OrderPaid, OrderCancelled, OrderRefunded}
+
+// RefundStatus represents the stage of a refund of an order.
+//
+// A refund is added to the ledger pending, before the payment gateway is asked for it,
+// and then succeeds or fails along with the gateway.
+type RefundStatus string
+
+// The statuses of a refund.
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// orderTransitions lists the statuses an order can move to from each status.
+//
+// A cancelled or refunded order is final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderRefunded},
+	OrderCancelled: {},
+	OrderRefunded:  {},
+}
+
+type (
+	// OrderID represents a unique identifier for an order.
+	OrderID uuid.UUID
+
+	// OrderItem represents a line item of an order: Quantity units of a ticket,
+	// bought at Price each. Price is the price of the ticket when the order was created.
+	OrderItem struct {
+		TicketID TicketID
+		Quantity int
+		Price    Money
+	}
+
+	// Order represents the purchase of tickets by a buyer.
+	//
+	// Total is the sum of the prices of the items, all of them in the same currency.
+	// Both are computed from the prices of the tickets when the order is created,
+	// so that a later change of a price does not change the order.
+	//
+	// A pending order holds the units of its tickets until DateExpires, see OrderService.
+	// PaymentID and Captured are set once the order is paid, by the payment captured
+	// for its total. Refunds is the ledger of the refunds of a paid order, in the order
+	// they were made, and the order is refunded once all its captured amount is, by the
+	// refunds which succeeded.
+	//
+	// Version starts at 1 and is incremented on every change of status or refund.
+	Order struct {
+		ID          OrderID
+		Items       []OrderItem
+		Total       Money
+		Status      OrderStatus
+		PaymentID   PaymentID
+		Captured    Money
+		Refunds     []OrderRefund
+		Version     int
+		DateCreated time.Time
+		DateUpdated time.Time
+		DateExpires time.Time
+	}
+
+	// OrderRefund represents an amount of a paid order given back to the buyer, along
+	// with the units of its tickets released back to the inventory, if any. The units
+	// are listed as line items, at the prices of the items of the order.
+	//
+	// The amount and the units of a pending refund are reserved, so that no other refund
+	// can claim them, and the units are released once it succeeds. PaymentRefundID is the
+	// identifier of the refund at the payment gateway, see Refund, set once it succeeds.
+	OrderRefund struct {
+		ID              string
+		Status          RefundStatus
+		PaymentRefundID string
+		Amount          Money
+		Items           []OrderItem
+		DateCreated     time.Time
+		DateUpdated     time.Time
+	}
+
+	// OrderService represents a service for managing orders.
+	//
+	// The units of the tickets of an order are held through the holds of the
+	// tickets, for the holder returned by Order.Holder, so that they count
+	// against the capacity of the tickets the same way the holds of a
+	// ReservationService do. The ticket IDs of an order must be unique.
+	OrderService interface {
+		// CreateOrder stores a new pending order, holding the units of its items until
+		// ttl from now. The prices and the total are computed from the prices of the
+		// tickets, see Order.WithPrices, and the order is returned with them.
+		//
+		// The units of all the items are held along with the creation of the order, or
+		// none of them is: it returns the error of the first item which cannot be held,
+		// ErrTicketNotFound, ErrTicketNotOnSale, ErrSoldOut, ErrAlreadyHeld or ErrInvalidQuantity,
+		// see ReservationService.Hold, ErrCurrencyMismatch when the tickets are priced in
+		// different currencies, and ErrOrderAlreadyExists when the ID is already used.
+		CreateOrder(ctx context.Context, order Order, ttl time.Duration) (Order, error)
+
+		// ReadOrder returns ErrOrderNotFound when the order does not exist.
+		ReadOrder(ctx context.Context, id OrderID) (Order, error)
+
+		// CancelOrder cancels a pending order, and releases the units it holds along with it.
+		// It returns ErrInvalidOrderTransition when the order is not pending,
+		// and ErrOrderNotFound when it does not exist.
+		CancelOrder(ctx context.Context, id OrderID) (Order, error)
+
+		// PayOrder sells the units held by a pending order, in place of its holds, and marks
+		// it paid by the payment, see Order.CheckPayment. The tickets whose units are all sold
+		// move to StatusSoldOut, and their version is incremented, the same way
+		// ReservationService.Confirm does.
+		//
+		// It returns the order as is when it was already paid by the same payment, see
+		// Order.PaidBy, so that a payment can be reported more than once. It returns
+		// ErrOrderExpired when a hold of the order expired, ErrTicketNotOnSale when a ticket
+		// stopped being on sale since it was ordered, ErrTicketNotFound when it was deleted,
+		// and ErrOrderNotFound when the order does not exist.
+		PayOrder(ctx context.Context, id OrderID, payment Payment) (Order, error)
+
+		// RefundOrder adds a pending refund to the ledger of a paid order, see Order.CheckRefund
+		// and Order.Refund, which reserves its amount and its units until it is confirmed or
+		// cancelled. Concurrent refunds are checked against each other's reservations, so that
+		// they never claim more than the order bought.
+		//
+		// It returns the order as is when the refund is already in its ledger, so that a refund
+		// can be requested more than once, the errors of Order.CheckRefund, and ErrOrderNotFound
+		// when the order does not exist.
+		RefundOrder(ctx context.Context, id OrderID, refund OrderRefund) (Order, error)
+
+		// ConfirmRefund marks a pending refund succeeded at the payment gateway, see
+		// Order.ConfirmRefund, and releases the units of its items: they are no longer sold,
+		// and the tickets which were sold out move back to StatusOnSale, with their version
+		// incremented. The tickets which were deleted since are skipped. The order moves to
+		// OrderRefunded once all its captured amount is refunded.
+		//
+		// The refund is confirmed along with the release of its units, or neither is. It returns
+		// the order as is when the refund already succeeded, the errors of Order.ConfirmRefund,
+		// and ErrOrderNotFound when the order does not exist.
+		ConfirmRefund(ctx context.Context, id OrderID, refundID, paymentRefundID string) (Order, error)
+
+		// CancelRefund marks a pending refund failed, see Order.CancelRefund, which gives its
+		// amount and its units back to the refunds to come. It returns the order as is when the
+		// refund already failed, the errors of Order.CancelRefund, and ErrOrderNotFound when
+		// the order does not exist.
+		CancelRefund(ctx context.Context, id OrderID, refundID string) (Order, error)
+	}
+)
+
+func NewOrderID() OrderID {
+	return OrderID(uuid.New())
+}
+
+func (id OrderID) String() string {
+	return uuid.UUID(id).String()
+}
+
+// Valid reports whether the status is one of OrderStatuses.
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order can move from the status to the next one.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, to := range orderTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTransition reports ErrInvalidOrderTransition when the order
+// cannot move from its current status to the next one.
+func (o Order) CheckTransition(next OrderStatus) error {
+	if !o.Status.CanTransitionTo(next) {
+		return fmt.Errorf("from %s to %s: %w", o.Status, next, ErrInvalidOrderTransition)
+	}
+
+	return nil
+}
+
+// CheckPayment reports whether the order can be paid by the payment at the given time:
+// the order must be pending and not expired, and the payment must have captured its total.
+//
+// It returns ErrInvalidOrderTransition, ErrOrderExpired or ErrInvalidPaymentAmount.
+func (o Order) CheckPayment(p Payment, now time.Time) error {
+	if err := o.CheckTransition(OrderPaid); err != nil {
+		return err
+	}
+	if o.Expired(now) {
+		return fmt.Errorf("at %s: %w", o.DateExpires.Format(time.RFC3339), ErrOrderExpired)
+	}
+	if p.Status != PaymentCaptured || p.Captured != o.Total {
+		return fmt.Errorf("%s captured for a total of %s: %w", p.Captured, o.Total, ErrInvalidPaymentAmount)
+	}
+
+	return nil
+}
+
+// PaidBy reports whether the order was paid by the payment,
+// even when it was refunded since.
+func (o Order) PaidBy(p Payment) bool {
+	return o.PaymentID != "" && o.PaymentID == p.ID
+}
+
+// Pay returns the order paid by the payment at the given time, with its version incremented.
+func (o Order) Pay(p Payment, now time.Time) Order {
+	o.Status = OrderPaid
+	o.PaymentID = p.ID
+	o.Captured = p.Captured
+	o.Version++
+	o.DateUpdated = now
+
+	return o
+}
+
+// Refunded returns the amount of the order refunded by the refunds of its ledger which succeeded.
+func (o Order) Refunded() Money {
+	return o.refunds(RefundSucceeded)
+}
+
+// Refunding returns the amount of the order reserved by the pending refunds of its ledger.
+func (o Order) Refunding() Money {
+	return o.refunds(RefundPending)
+}
+
+// refunds returns the sum of the amounts of the refunds of the ledger with the given status.
+func (o Order) refunds(status RefundStatus) Money {
+	sum := Money{Currency: o.Total.Currency}
+	for _, r := range o.Refunds {
+		if r.Status == status {
+			sum.Amount += r.Amount.Amount
+		}
+	}
+
+	return sum
+}
+
+// RefundedBy reports whether the refund with the given ID is in the ledger of the order,
+// whatever its status.
+func (o Order) RefundedBy(id string) bool {
+	_, err := o.FindRefund(id)
+	return err == nil
+}
+
+// FindRefund returns the refund with the given ID of the ledger of the order.
+// It returns ErrRefundNotFound when there is none.
+func (o Order) FindRefund(id string) (OrderRefund, error) {
+	for _, r := range o.Refunds {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+
+	return OrderRefund{}, fmt.Errorf("refund %q: %w", id, ErrRefundNotFound)
+}
+
+// RefundableItems returns the units of the items of the order which were neither refunded
+// nor reserved by a pending refund yet, at the prices of the items. The items whose units
+// were all refunded are left out.
+func (o Order) RefundableItems() []OrderItem {
+	refunded := make(map[TicketID]int)
+	for _, r := range o.Refunds {
+		if r.Status == RefundFailed {
+			continue
+		}
+		for _, item := range r.Items {
+			refunded[item.TicketID] += item.Quantity
+		}
+	}
+
+	var items []OrderItem
+	for _, item := range o.Items {
+		if item.Quantity -= refunded[item.TicketID]; item.Quantity > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// RefundItems returns the line items of a refund of the given units of the tickets of
+// the order, at the prices of the items of the order, along with the sum of their prices.
+// The prices of the units are ignored, and the units of the tickets which are not in
+// the order are left at a zero price, to be rejected by CheckRefund.
+func (o Order) RefundItems(units []OrderItem) ([]OrderItem, Money, error) {
+	prices := make(map[TicketID]Money, len(o.Items))
+	for _, item := range o.Items {
+		prices[item.TicketID] = item.Price
+	}
+
+	items := make([]OrderItem, len(units))
+	amount := Money{Currency: o.Total.Currency}
+	for i, unit := range units {
+		unit.Price = prices[unit.TicketID]
+		if unit.Price.Currency == "" {
+			unit.Price.Currency = o.Total.Currency
+		}
+		items[i] = unit
+
+		price, err := unit.Price.Mul(int64(unit.Quantity))
+		if err != nil {
+			return nil, Money{}, err
+		}
+		if amount, err = amount.Add(price); err != nil {
+			return nil, Money{}, err
+		}
+	}
+
+	return items, amount, nil
+}
+
+// CheckRefund reports whether the refund can be added to the ledger of the order: the
+// order must be paid, the amount of the refund must be positive and at most the amount
+// captured and neither refunded nor reserved yet, and its items must be units of the
+// items of the order which are still refundable, once at most per ticket.
+//
+// It returns ErrInvalidOrderTransition, ErrInvalidPaymentAmount, ErrCurrencyMismatch
+// or ErrInvalidQuantity.
+func (o Order) CheckRefund(r OrderRefund) error {
+	if err := o.CheckTransition(OrderRefunded); err != nil {
+		return err
+	}
+
+	refundable, err := o.Refundable()
+	if err != nil {
+		return err
+	}
+	cmp, err := r.Amount.Cmp(refundable)
+	if err != nil {
+		return err
+	}
+	if r.Amount.Amount <= 0 || cmp > 0 {
+		return fmt.Errorf("%s of %s refundable: %w", r.Amount, refundable, ErrInvalidPaymentAmount)
+	}
+
+	units := make(map[TicketID]int)
+	for _, item := range o.RefundableItems() {
+		units[item.TicketID] = item.Quantity
+	}
+	for _, item := range r.Items {
+		left := units[item.TicketID]
+		if item.Quantity < 1 || item.Quantity > left {
+			return fmt.Errorf("ticket %s: %d of %d refundable: %w", item.TicketID, item.Quantity, left, ErrInvalidQuantity)
+		}
+		delete(units, item.TicketID)
+	}
+
+	return nil
+}
+
+// Refundable returns the amount captured for the order which was
+// neither refunded nor reserved by a pending refund yet.
+func (o Order) Refundable() (Money, error) {
+	refunded, err := o.Refunded().Add(o.Refunding())
+	if err != nil {
+		return Money{}, err
+	}
+
+	return o.Captured.Sub(refunded)
+}
+
+// Refund returns the order with the refund added to its ledger at the given time, pending,
+// and its version incremented.
+//
+// A refund which reserves all the amount left to refund also reserves all the units left,
+// whether they are listed or not, so that an order refunded in full releases all its units.
+func (o Order) Refund(r OrderRefund, now time.Time) Order {
+	if refundable, err := o.Refundable(); err == nil && r.Amount == refundable {
+		r.Items = o.RefundableItems()
+	}
+	r.Items = append([]OrderItem(nil), r.Items...)
+	r.Status = RefundPending
+	r.PaymentRefundID = ""
+	r.DateCreated = now
+	r.DateUpdated = now
+
+	o.Refunds = append(append([]OrderRefund(nil), o.Refunds...), r)
+	o.Version++
+	o.DateUpdated = now
+
+	return o
+}
+
+// ConfirmRefund returns the order with the pending refund with the given ID marked succeeded
+// at the given time, as refunded by the payment gateway under paymentRefundID, and its version
+// incremented. It moves to OrderRefunded once all its captured amount is refunded.
+//
+// It returns ErrRefundNotFound when the refund is not in the ledger, and ErrInvalidRefundTransition
+// when it is not pending.
+func (o Order) ConfirmRefund(id, paymentRefundID string, now time.Time) (Order, error) {
+	o, i, err := o.settleRefund(id, RefundSucceeded, now)
+	if err != nil {
+		return Order{}, err
+	}
+
+	o.Refunds[i].PaymentRefundID = paymentRefundID
+	if o.Refunded() == o.Captured {
+		o.Status = OrderRefunded
+	}
+
+	return o, nil
+}
+
+// CancelRefund returns the order with the pending refund with the given ID marked failed at the
+// given time, which gives its amount and its units back, and its version incremented.
+//
+// It returns ErrRefundNotFound when the refund is not in the ledger, and ErrInvalidRefundTransition
+// when it is not pending.
+func (o Order) CancelRefund(id string, now time.Time) (Order, error) {
+	o, _, err := o.settleRefund(id, RefundFailed, now)
+	return o, err
+}
+
+// settleRefund returns the order with the pending refund with the given ID moved to the status
+// in a copy of its ledger, along with its index, and the version of the order incremented.
+func (o Order) settleRefund(id string, status RefundStatus, now time.Time) (Order, int, error) {
+	for i, r := range o.Refunds {
+		if r.ID != id {
+			continue
+		}
+		if r.Status != RefundPending {
+			return Order{}, 0, fmt.Errorf("refund %q is %s: %w", id, r.Status, ErrInvalidRefundTransition)
+		}
+
+		o.Refunds = append([]OrderRefund(nil), o.Refunds...)
+		o.Refunds[i].Status = status
+		o.Refunds[i].DateUpdated = now
+		o.Version++
+		o.DateUpdated = now
+
+		return o, i, nil
+	}
+
+	return Order{}, 0, fmt.Errorf("refund %q: %w", id, ErrRefundNotFound)
+}
+
+// Holder returns the holder of the units held by the order.
+func (o Order) Holder() string {
+	return "order:" + o.ID.String()
+}
+
+// Expired reports whether the order is pending but no longer holds its tickets at the given time.
+func (o Order) Expired(now time.Time) bool {
+	return o.Status == OrderPending && !now.Before(o.DateExpires)
+}
+
+// WithPrices returns the order with the prices of its items set to the prices of
+// their tickets, given in the order of the items, and its total set to their sum.
+//
+// It returns ErrCurrencyMismatch when the tickets are priced in different currencies.
+func (o Order) WithPrices(tickets []Ticket) (Order, error) {
+	if len(tickets) != len(o.Items) {
+		return Order{}, fmt.Errorf("%d tickets for %d items", len(tickets), len(o.Items))
+	}
+
+	items := make([]OrderItem, len(o.Items))
+	var total Money
+	for i, item := range o.Items {
+		item.Price = tickets[i].Price
+		items[i] = item
+
+		amount, err := item.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return Order{}, err
+		}
+		if i == 0 {
+			total = amount
+			continue
+		}
+		if total, err = total.Add(amount); err != nil {
+			return Order{}, err
+		}
+	}
+	o.Items = items
+	o.Total = total
+
+	return o, nil
+}
+
+// Validate checks the items of the order, which must reference
+// unique tickets, see OrderService.
+func (o Order) Validate(vld Validator) {
+	vld.Check(len(o.Items) > 0 && len(o.Items) <= MaxOrderItems, "items", fmt.Sprintf("must contain between 1 and %d items", MaxOrderItems))
+
+	seen := make(map[TicketID]bool, len(o.Items))
+	for i, item := range o.Items {
+		vld.Check(!seen[item.TicketID], fmt.Sprintf("items.%d.ticket_id", i), "must be unique within the order")
+		seen[item.TicketID] = true
+
+		vld.Check(item.Quantity > 0 && item.Quantity <= MaxOrderQuantity, fmt.Sprintf("items.%d.quantity", i), fmt.Sprintf("must be in the range [1, %d]", MaxOrderQuantity))
+	}
+}
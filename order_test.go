@@ -0,0 +1,207 @@
+package tixer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+func TestOrderStatus_CanTransitionTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		from, to tixer.OrderStatus
+		want     bool
+	}{
+		{tixer.OrderPending, tixer.OrderPaid, true},
+		{tixer.OrderPending, tixer.OrderCancelled, true},
+		{tixer.OrderPending, tixer.OrderRefunded, false},
+		{tixer.OrderPaid, tixer.OrderRefunded, true},
+		{tixer.OrderPaid, tixer.OrderCancelled, false},
+		{tixer.OrderCancelled, tixer.OrderPending, false},
+		{tixer.OrderRefunded, tixer.OrderPaid, false},
+		{tixer.OrderPending, tixer.OrderPending, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("Got %t from %q to %q, want %t", got, tt.from, tt.to, tt.want)
+		}
+	}
+}
+
+func TestOrder_WithPricesComputesTheTotal(t *testing.T) {
+	t.Parallel()
+
+	order := tixer.Order{Items: []tixer.OrderItem{
+		{TicketID: tixer.NewTicketID(), Quantity: 2},
+		{TicketID: tixer.NewTicketID(), Quantity: 1},
+	}}
+	tickets := []tixer.Ticket{
+		{ID: order.Items[0].TicketID, Price: tixer.Money{Amount: 1250, Currency: "EUR"}},
+		{ID: order.Items[1].TicketID, Price: tixer.Money{Amount: 800, Currency: "EUR"}},
+	}
+
+	got, err := order.WithPrices(tickets)
+	if err != nil {
+		t.Fatalf("WithPrices: %v", err)
+	}
+	if want := (tixer.Money{Amount: 3300, Currency: "EUR"}); got.Total != want {
+		t.Errorf("Got total %v, want %v", got.Total, want)
+	}
+	if got.Items[0].Price != tickets[0].Price || got.Items[1].Price != tickets[1].Price {
+		t.Errorf("Got items %+v, want the prices of the tickets", got.Items)
+	}
+	if order.Items[0].Price.Amount != 0 {
+		t.Errorf("Got the items of the order changed, want a copy")
+	}
+}
+
+func TestOrder_WithPricesRejectsMixedCurrencies(t *testing.T) {
+	t.Parallel()
+
+	order := tixer.Order{Items: []tixer.OrderItem{
+		{TicketID: tixer.NewTicketID(), Quantity: 1},
+		{TicketID: tixer.NewTicketID(), Quantity: 1},
+	}}
+	tickets := []tixer.Ticket{
+		{Price: tixer.Money{Amount: 1250, Currency: "EUR"}},
+		{Price: tixer.Money{Amount: 1250, Currency: "USD"}},
+	}
+
+	if _, err := order.WithPrices(tickets); !errors.Is(err, tixer.ErrCurrencyMismatch) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrCurrencyMismatch)
+	}
+}
+
+func TestOrder_CheckRefund(t *testing.T) {
+	t.Parallel()
+
+	concert, opera := tixer.NewTicketID(), tixer.NewTicketID()
+	eur := func(amount int64) tixer.Money { return tixer.Money{Amount: amount, Currency: "EUR"} }
+	order := tixer.Order{
+		Items: []tixer.OrderItem{
+			{TicketID: concert, Quantity: 2, Price: eur(5000)},
+			{TicketID: opera, Quantity: 1, Price: eur(3000)},
+		},
+		Total:    eur(13000),
+		Status:   tixer.OrderPaid,
+		Captured: eur(13000),
+		Refunds: []tixer.OrderRefund{
+			{ID: "re_1", Status: tixer.RefundSucceeded, Amount: eur(5000), Items: []tixer.OrderItem{{TicketID: concert, Quantity: 1, Price: eur(5000)}}},
+			{ID: "re_2", Status: tixer.RefundFailed, Amount: eur(3000), Items: []tixer.OrderItem{{TicketID: opera, Quantity: 1, Price: eur(3000)}}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		refund tixer.OrderRefund
+		want   error
+	}{
+		{"the rest", tixer.OrderRefund{Amount: eur(8000), Items: []tixer.OrderItem{{TicketID: concert, Quantity: 1}, {TicketID: opera, Quantity: 1}}}, nil},
+		{"an amount alone", tixer.OrderRefund{Amount: eur(100)}, nil},
+		{"more than captured", tixer.OrderRefund{Amount: eur(8001)}, tixer.ErrInvalidPaymentAmount},
+		{"nothing", tixer.OrderRefund{Amount: eur(0)}, tixer.ErrInvalidPaymentAmount},
+		{"another currency", tixer.OrderRefund{Amount: tixer.Money{Amount: 100, Currency: "USD"}}, tixer.ErrCurrencyMismatch},
+		{"units refunded already", tixer.OrderRefund{Amount: eur(100), Items: []tixer.OrderItem{{TicketID: concert, Quantity: 2}}}, tixer.ErrInvalidQuantity},
+		{"units of a ticket twice", tixer.OrderRefund{Amount: eur(100), Items: []tixer.OrderItem{{TicketID: opera, Quantity: 1}, {TicketID: opera, Quantity: 1}}}, tixer.ErrInvalidQuantity},
+		{"a ticket not ordered", tixer.OrderRefund{Amount: eur(100), Items: []tixer.OrderItem{{TicketID: tixer.NewTicketID(), Quantity: 1}}}, tixer.ErrInvalidQuantity},
+	}
+
+	for _, tt := range tests {
+		if err := order.CheckRefund(tt.refund); !errors.Is(err, tt.want) {
+			t.Errorf("Got error %v refunding %s, want %v", err, tt.name, tt.want)
+		}
+	}
+
+	pending := order
+	pending.Status = tixer.OrderPending
+	if err := pending.CheckRefund(tixer.OrderRefund{Amount: eur(100)}); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v for a pending order, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+
+	// A pending refund reserves its amount and its units.
+	reserved := order.Refund(tixer.OrderRefund{ID: "re_3", Amount: eur(3000), Items: []tixer.OrderItem{{TicketID: opera, Quantity: 1, Price: eur(3000)}}}, time.Now())
+	if err := reserved.CheckRefund(tixer.OrderRefund{Amount: eur(5001)}); !errors.Is(err, tixer.ErrInvalidPaymentAmount) {
+		t.Errorf("Got error %v for more than left by a pending refund, want %v", err, tixer.ErrInvalidPaymentAmount)
+	}
+	if err := reserved.CheckRefund(tixer.OrderRefund{Amount: eur(100), Items: []tixer.OrderItem{{TicketID: opera, Quantity: 1}}}); !errors.Is(err, tixer.ErrInvalidQuantity) {
+		t.Errorf("Got error %v for the units of a pending refund, want %v", err, tixer.ErrInvalidQuantity)
+	}
+}
+
+func TestOrder_RefundMovesToRefundedOnceAllIsRefunded(t *testing.T) {
+	t.Parallel()
+
+	ticket := tixer.NewTicketID()
+	eur := func(amount int64) tixer.Money { return tixer.Money{Amount: amount, Currency: "EUR"} }
+	order := tixer.Order{
+		Items:    []tixer.OrderItem{{TicketID: ticket, Quantity: 2, Price: eur(5000)}},
+		Total:    eur(10000),
+		Status:   tixer.OrderPaid,
+		Captured: eur(10000),
+		Version:  2,
+	}
+
+	order = order.Refund(tixer.OrderRefund{ID: "re_1", Amount: eur(4000)}, time.Now())
+	if order.Status != tixer.OrderPaid || order.Version != 3 || order.Refunded() != eur(0) || order.Refunding() != eur(4000) {
+		t.Errorf("Got status %q, version %d, %v refunded and %v refunding, want %q, 3, 0.00 EUR and 40.00 EUR", order.Status, order.Version, order.Refunded(), order.Refunding(), tixer.OrderPaid)
+	}
+
+	order, err := order.ConfirmRefund("re_1", "pay_re_1", time.Now())
+	if err != nil {
+		t.Fatalf("ConfirmRefund: %v", err)
+	}
+	if order.Status != tixer.OrderPaid || order.Version != 4 || order.Refunded() != eur(4000) || order.Refunds[0].PaymentRefundID != "pay_re_1" {
+		t.Errorf("Got status %q, version %d, %v refunded and refunds %+v, want %q, 4 and 40.00 EUR", order.Status, order.Version, order.Refunded(), order.Refunds, tixer.OrderPaid)
+	}
+	if _, err := order.ConfirmRefund("re_1", "pay_re_1", time.Now()); !errors.Is(err, tixer.ErrInvalidRefundTransition) {
+		t.Errorf("Got error %v confirming a refund twice, want %v", err, tixer.ErrInvalidRefundTransition)
+	}
+
+	// The refund of all the amount left reserves all the units left, though it lists none.
+	order = order.Refund(tixer.OrderRefund{ID: "re_2", Amount: eur(6000)}, time.Now())
+	if got := order.Refunds[1].Items; len(got) != 1 || got[0].TicketID != ticket || got[0].Quantity != 2 {
+		t.Errorf("Got items %+v, want the 2 units left", got)
+	}
+	if order, err = order.ConfirmRefund("re_2", "pay_re_2", time.Now()); err != nil {
+		t.Fatalf("ConfirmRefund: %v", err)
+	}
+	if order.Status != tixer.OrderRefunded || !order.RefundedBy("re_1") || !order.RefundedBy("re_2") {
+		t.Errorf("Got status %q and refunds %+v, want %q with both refunds", order.Status, order.Refunds, tixer.OrderRefunded)
+	}
+}
+
+func TestOrder_CancelRefundGivesTheReservationBack(t *testing.T) {
+	t.Parallel()
+
+	ticket := tixer.NewTicketID()
+	eur := func(amount int64) tixer.Money { return tixer.Money{Amount: amount, Currency: "EUR"} }
+	order := tixer.Order{
+		Items:    []tixer.OrderItem{{TicketID: ticket, Quantity: 1, Price: eur(5000)}},
+		Total:    eur(5000),
+		Status:   tixer.OrderPaid,
+		Captured: eur(5000),
+	}
+
+	order = order.Refund(tixer.OrderRefund{ID: "re_1", Amount: eur(5000)}, time.Now())
+	order, err := order.CancelRefund("re_1", time.Now())
+	if err != nil {
+		t.Fatalf("CancelRefund: %v", err)
+	}
+	if order.Status != tixer.OrderPaid || order.Refunds[0].Status != tixer.RefundFailed || order.Refunding() != eur(0) {
+		t.Errorf("Got status %q and refunds %+v, want %q with the refund failed", order.Status, order.Refunds, tixer.OrderPaid)
+	}
+	if err := order.CheckRefund(tixer.OrderRefund{Amount: eur(5000), Items: order.RefundableItems()}); err != nil {
+		t.Errorf("Got error %v, want the amount and the units of a failed refund refundable again", err)
+	}
+
+	if _, err := order.ConfirmRefund("re_1", "pay_re_1", time.Now()); !errors.Is(err, tixer.ErrInvalidRefundTransition) {
+		t.Errorf("Got error %v confirming a failed refund, want %v", err, tixer.ErrInvalidRefundTransition)
+	}
+	if _, err := order.CancelRefund("re_2", time.Now()); !errors.Is(err, tixer.ErrRefundNotFound) {
+		t.Errorf("Got error %v for a missing refund, want %v", err, tixer.ErrRefundNotFound)
+	}
+}
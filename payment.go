@@ -0,0 +1,98 @@
+package tixer
+
+import (
+	"context"
+	"time"
+)
+
+// PaymentStatus represents the stage of a payment at the payment gateway.
+type PaymentStatus string
+
+// The statuses of a payment.
+const (
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentRefunded   PaymentStatus = "refunded"
+	PaymentVoided     PaymentStatus = "voided"
+)
+
+// PaymentEventType represents the kind of change of a payment notified by a webhook.
+type PaymentEventType string
+
+// The types of the payment events handled by the service.
+// The gateways may notify others, which are acknowledged and ignored.
+const (
+	PaymentEventCaptured PaymentEventType = "payment.captured"
+	PaymentEventRefunded PaymentEventType = "payment.refunded"
+)
+
+type (
+	// PaymentID represents the identifier of a payment, assigned by the payment gateway.
+	PaymentID string
+
+	// Payment represents the payment of an order at the payment gateway.
+	//
+	// Amount is the amount authorized, of which Captured was moved to the
+	// merchant, and Refunded given back to the buyer. A payment is refunded
+	// once all its captured amount is.
+	Payment struct {
+		ID       PaymentID
+		OrderID  OrderID
+		Amount   Money
+		Captured Money
+		Refunded Money
+		Status   PaymentStatus
+	}
+
+	// Refund represents an amount of a payment given back to the buyer,
+	// identified by the payment gateway.
+	Refund struct {
+		ID          string
+		PaymentID   PaymentID
+		Amount      Money
+		DateCreated time.Time
+	}
+
+	// PaymentEvent represents a change of a payment notified by the payment
+	// gateway through a webhook. ID identifies the event, so that the gateways
+	// which deliver an event more than once can be told apart.
+	PaymentEvent struct {
+		ID      string
+		Type    PaymentEventType
+		Payment Payment
+	}
+
+	// PaymentProvider represents a payment gateway.
+	//
+	// A payment is first authorized, which reserves its amount on the payment
+	// method of the buyer, then captured, which moves it, and may be refunded.
+	// An authorized payment which is not captured may be voided instead.
+	// The amounts are in the currency of the payment.
+	PaymentProvider interface {
+		// Authorize authorizes the payment of amount for the order. Authorizing again with
+		// the same idempotency key returns the payment it made, in its current status.
+		// It returns ErrPaymentDeclined when the gateway declines it.
+		Authorize(ctx context.Context, orderID OrderID, amount Money, idempotencyKey string) (Payment, error)
+
+		// Capture captures amount of an authorized payment, at most the amount authorized.
+		// Capturing a payment captured with the same amount returns it as is.
+		// It returns ErrInvalidPaymentAmount for a larger amount or a payment which is not
+		// authorized, and ErrPaymentNotFound when the payment does not exist.
+		Capture(ctx context.Context, id PaymentID, amount Money) (Payment, error)
+
+		// Void releases the amount of an authorized payment, which cannot be captured anymore.
+		// Voiding a voided payment returns it as is. It returns ErrInvalidPaymentAmount for a
+		// payment which is not authorized, and ErrPaymentNotFound when it does not exist.
+		Void(ctx context.Context, id PaymentID) (Payment, error)
+
+		// Refund refunds amount of a captured payment, at most the amount captured and
+		// not refunded yet. It returns ErrInvalidPaymentAmount for a larger amount or a
+		// payment which is not captured, and ErrPaymentNotFound when the payment does not exist.
+		Refund(ctx context.Context, id PaymentID, amount Money) (Refund, error)
+
+		// VerifyWebhook checks that the payload of a webhook, delivered with the given
+		// headers, was signed by the gateway, and returns the event it notifies.
+		// It returns ErrInvalidWebhookSignature when the signature does not match.
+		VerifyWebhook(payload []byte, header map[string][]string) (PaymentEvent, error)
+	}
+)
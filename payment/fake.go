@@ -0,0 +1,267 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+)
+
+// DeclinedMinorUnits are the last two digits, in minor units, of the amounts
+// declined by Fake, such as 10.02 EUR.
+const DeclinedMinorUnits = 2
+
+// Fake is a local payment gateway which moves no money, for the tests and
+// local development.
+//
+// It is deterministic: the payments, the refunds and the events are numbered
+// in the order they are made, and Authorize declines the amounts ending with
+// DeclinedMinorUnits, the way the test cards of a gateway are declined.
+// The declined authorizations are not kept, so their idempotency keys can be used again.
+// The payments are kept in memory, and are lost once the process exits.
+//
+// Its webhooks are built by Webhook, signed with its secret by Sign.
+type Fake struct {
+	secret []byte
+
+	mu       sync.Mutex
+	payments map[tixer.PaymentID]tixer.Payment
+	keys     map[string]tixer.PaymentID
+	refunds  int
+	events   int
+}
+
+func NewFake(secret []byte) *Fake {
+	return &Fake{
+		secret:   secret,
+		payments: make(map[tixer.PaymentID]tixer.Payment),
+		keys:     make(map[string]tixer.PaymentID),
+	}
+}
+
+func (f *Fake) Authorize(ctx context.Context, orderID tixer.OrderID, amount tixer.Money, idempotencyKey string) (tixer.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.keys[idempotencyKey]; ok {
+		return f.payments[id], nil
+	}
+	if amount.Amount <= 0 {
+		return tixer.Payment{}, fmt.Errorf("%s: %w", amount, tixer.ErrInvalidPaymentAmount)
+	}
+	if amount.Amount%100 == DeclinedMinorUnits {
+		return tixer.Payment{}, fmt.Errorf("%s: %w", amount, tixer.ErrPaymentDeclined)
+	}
+
+	payment := tixer.Payment{
+		ID:       tixer.PaymentID(fmt.Sprintf("fake_pay_%d", len(f.payments)+1)),
+		OrderID:  orderID,
+		Amount:   amount,
+		Captured: tixer.Money{Currency: amount.Currency},
+		Refunded: tixer.Money{Currency: amount.Currency},
+		Status:   tixer.PaymentAuthorized,
+	}
+	f.payments[payment.ID] = payment
+	f.keys[idempotencyKey] = payment.ID
+
+	return payment, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, id tixer.PaymentID, amount tixer.Money) (tixer.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[id]
+	if !ok {
+		return tixer.Payment{}, tixer.ErrPaymentNotFound
+	}
+	if payment.Status == tixer.PaymentCaptured && payment.Captured == amount {
+		return payment, nil
+	}
+	if payment.Status != tixer.PaymentAuthorized {
+		return tixer.Payment{}, fmt.Errorf("payment %s is %s: %w", id, payment.Status, tixer.ErrInvalidPaymentAmount)
+	}
+	if err := checkAmount(amount, payment.Amount); err != nil {
+		return tixer.Payment{}, err
+	}
+
+	payment.Captured = amount
+	payment.Status = tixer.PaymentCaptured
+	f.payments[id] = payment
+
+	return payment, nil
+}
+
+func (f *Fake) Void(ctx context.Context, id tixer.PaymentID) (tixer.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[id]
+	if !ok {
+		return tixer.Payment{}, tixer.ErrPaymentNotFound
+	}
+	switch payment.Status {
+	case tixer.PaymentVoided:
+		return payment, nil
+	case tixer.PaymentAuthorized:
+	default:
+		return tixer.Payment{}, fmt.Errorf("payment %s is %s: %w", id, payment.Status, tixer.ErrInvalidPaymentAmount)
+	}
+
+	payment.Status = tixer.PaymentVoided
+	f.payments[id] = payment
+
+	return payment, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, id tixer.PaymentID, amount tixer.Money) (tixer.Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[id]
+	if !ok {
+		return tixer.Refund{}, tixer.ErrPaymentNotFound
+	}
+	if payment.Status != tixer.PaymentCaptured {
+		return tixer.Refund{}, fmt.Errorf("payment %s is %s: %w", id, payment.Status, tixer.ErrInvalidPaymentAmount)
+	}
+	refundable, err := payment.Captured.Sub(payment.Refunded)
+	if err != nil {
+		return tixer.Refund{}, err
+	}
+	if err := checkAmount(amount, refundable); err != nil {
+		return tixer.Refund{}, err
+	}
+
+	payment.Refunded, err = payment.Refunded.Add(amount)
+	if err != nil {
+		return tixer.Refund{}, err
+	}
+	if payment.Refunded == payment.Captured {
+		payment.Status = tixer.PaymentRefunded
+	}
+	f.payments[id] = payment
+
+	f.refunds++
+	return tixer.Refund{
+		ID:          fmt.Sprintf("fake_re_%d", f.refunds),
+		PaymentID:   id,
+		Amount:      amount,
+		DateCreated: time.Now().UTC(),
+	}, nil
+}
+
+// VerifyWebhook checks the signature of a webhook built by Webhook, see VerifySignature.
+func (f *Fake) VerifyWebhook(payload []byte, header map[string][]string) (tixer.PaymentEvent, error) {
+	if err := VerifySignature(f.secret, payload, http.Header(header).Get(SignatureHeader), time.Now()); err != nil {
+		return tixer.PaymentEvent{}, err
+	}
+
+	var event fakeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return tixer.PaymentEvent{}, fmt.Errorf("malformed event: %v: %w", err, tixer.ErrInvalidWebhookSignature)
+	}
+	orderID, err := uuid.Parse(event.Payment.OrderID)
+	if err != nil {
+		return tixer.PaymentEvent{}, fmt.Errorf("malformed order ID %q: %w", event.Payment.OrderID, tixer.ErrInvalidWebhookSignature)
+	}
+
+	return tixer.PaymentEvent{
+		ID:   event.ID,
+		Type: tixer.PaymentEventType(event.Type),
+		Payment: tixer.Payment{
+			ID:       tixer.PaymentID(event.Payment.ID),
+			OrderID:  tixer.OrderID(orderID),
+			Amount:   event.Payment.Amount,
+			Captured: event.Payment.Captured,
+			Refunded: event.Payment.Refunded,
+			Status:   tixer.PaymentStatus(event.Payment.Status),
+		},
+	}, nil
+}
+
+// Webhook returns the payload and the headers of the webhook notifying the event of
+// the stored payment with the given ID, as the gateway would deliver it. The event is
+// numbered when its ID is not set, and a delivery is repeated by building it again.
+func (f *Fake) Webhook(event tixer.PaymentEvent) ([]byte, map[string][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if event.ID == "" {
+		f.events++
+		event.ID = fmt.Sprintf("fake_evt_%d", f.events)
+	}
+
+	payload, err := json.Marshal(fakeEvent{
+		ID:   event.ID,
+		Type: string(event.Type),
+		Payment: fakePayment{
+			ID:       string(event.Payment.ID),
+			OrderID:  event.Payment.OrderID.String(),
+			Amount:   event.Payment.Amount,
+			Captured: event.Payment.Captured,
+			Refunded: event.Payment.Refunded,
+			Status:   string(event.Payment.Status),
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := map[string][]string{
+		SignatureHeader: {Sign(f.secret, payload, time.Now())},
+	}
+
+	return payload, header, nil
+}
+
+// Payment returns the stored payment with the given ID.
+func (f *Fake) Payment(id tixer.PaymentID) (tixer.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[id]
+	if !ok {
+		return tixer.Payment{}, tixer.ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+type (
+	// fakeEvent is the JSON payload of the webhooks of Fake.
+	fakeEvent struct {
+		ID      string      `json:"id"`
+		Type    string      `json:"type"`
+		Payment fakePayment `json:"payment"`
+	}
+
+	// fakePayment is the JSON representation of a payment in the webhooks of Fake.
+	fakePayment struct {
+		ID       string      `json:"id"`
+		OrderID  string      `json:"order_id"`
+		Amount   tixer.Money `json:"amount"`
+		Captured tixer.Money `json:"captured"`
+		Refunded tixer.Money `json:"refunded"`
+		Status   string      `json:"status"`
+	}
+)
+
+// checkAmount reports ErrInvalidPaymentAmount when the amount is not positive
+// or exceeds the maximum, and ErrCurrencyMismatch when their currencies differ.
+func checkAmount(amount, max tixer.Money) error {
+	cmp, err := amount.Cmp(max)
+	if err != nil {
+		return err
+	}
+	if amount.Amount <= 0 || cmp > 0 {
+		return fmt.Errorf("%s of at most %s: %w", amount, max, tixer.ErrInvalidPaymentAmount)
+	}
+
+	return nil
+}
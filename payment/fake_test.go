@@ -0,0 +1,206 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/payment"
+)
+
+func TestFake_AuthorizesCapturesAndRefunds(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := payment.NewFake([]byte("secret"))
+	amount := tixer.Money{Amount: 10_000, Currency: "EUR"}
+
+	authorized, err := fake.Authorize(ctx, tixer.NewOrderID(), amount, "key-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if authorized.ID != "fake_pay_1" || authorized.Status != tixer.PaymentAuthorized {
+		t.Errorf("Got payment %q %s, want fake_pay_1 authorized", authorized.ID, authorized.Status)
+	}
+
+	captured, err := fake.Capture(ctx, authorized.ID, amount)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if captured.Status != tixer.PaymentCaptured || captured.Captured != amount {
+		t.Errorf("Got payment %s with %s captured, want %s captured", captured.Status, captured.Captured, amount)
+	}
+
+	part := tixer.Money{Amount: 4_000, Currency: "EUR"}
+	if _, err := fake.Refund(ctx, captured.ID, part); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if _, err := fake.Refund(ctx, captured.ID, amount); !errors.Is(err, tixer.ErrInvalidPaymentAmount) {
+		t.Errorf("Got error %v refunding more than captured, want %v", err, tixer.ErrInvalidPaymentAmount)
+	}
+
+	refund, err := fake.Refund(ctx, captured.ID, tixer.Money{Amount: 6_000, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if refund.ID != "fake_re_2" {
+		t.Errorf("Got refund %q, want fake_re_2", refund.ID)
+	}
+
+	got, err := fake.Payment(captured.ID)
+	if err != nil {
+		t.Fatalf("Payment: %v", err)
+	}
+	if got.Status != tixer.PaymentRefunded || got.Refunded != amount {
+		t.Errorf("Got payment %s with %s refunded, want %s refunded", got.Status, got.Refunded, amount)
+	}
+}
+
+func TestFake_DeclinesTheAmountsEndingWith02(t *testing.T) {
+	t.Parallel()
+
+	fake := payment.NewFake([]byte("secret"))
+
+	_, err := fake.Authorize(context.Background(), tixer.NewOrderID(), tixer.Money{Amount: 1_002, Currency: "EUR"}, "key-1")
+	if !errors.Is(err, tixer.ErrPaymentDeclined) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrPaymentDeclined)
+	}
+}
+
+func TestFake_CaptureRejectsMoreThanAuthorized(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := payment.NewFake([]byte("secret"))
+
+	authorized, err := fake.Authorize(ctx, tixer.NewOrderID(), tixer.Money{Amount: 10_000, Currency: "EUR"}, "key-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	if _, err := fake.Capture(ctx, authorized.ID, tixer.Money{Amount: 10_001, Currency: "EUR"}); !errors.Is(err, tixer.ErrInvalidPaymentAmount) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrInvalidPaymentAmount)
+	}
+	if _, err := fake.Capture(ctx, "fake_pay_9", tixer.Money{Amount: 100, Currency: "EUR"}); !errors.Is(err, tixer.ErrPaymentNotFound) {
+		t.Errorf("Got error %v for a missing payment, want %v", err, tixer.ErrPaymentNotFound)
+	}
+}
+
+func TestFake_AuthorizesOncePerIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := payment.NewFake([]byte("secret"))
+	orderID, amount := tixer.NewOrderID(), tixer.Money{Amount: 10_000, Currency: "EUR"}
+
+	authorized, err := fake.Authorize(ctx, orderID, amount, "key-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if _, err := fake.Capture(ctx, authorized.ID, amount); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	again, err := fake.Authorize(ctx, orderID, amount, "key-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if again.ID != authorized.ID || again.Status != tixer.PaymentCaptured {
+		t.Errorf("Got payment %q %s, want %q captured", again.ID, again.Status, authorized.ID)
+	}
+	if _, err := fake.Capture(ctx, again.ID, amount); err != nil {
+		t.Errorf("Got error %v capturing again, want the captured payment", err)
+	}
+
+	other, err := fake.Authorize(ctx, orderID, amount, "key-2")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if other.ID == authorized.ID {
+		t.Errorf("Got payment %q for another key, want a new payment", other.ID)
+	}
+}
+
+func TestFake_VoidsAnAuthorizedPayment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := payment.NewFake([]byte("secret"))
+	amount := tixer.Money{Amount: 10_000, Currency: "EUR"}
+
+	authorized, err := fake.Authorize(ctx, tixer.NewOrderID(), amount, "key-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	voided, err := fake.Void(ctx, authorized.ID)
+	if err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if voided.Status != tixer.PaymentVoided {
+		t.Errorf("Got payment %s, want it voided", voided.Status)
+	}
+	if _, err := fake.Capture(ctx, authorized.ID, amount); !errors.Is(err, tixer.ErrInvalidPaymentAmount) {
+		t.Errorf("Got error %v capturing a voided payment, want %v", err, tixer.ErrInvalidPaymentAmount)
+	}
+}
+
+func TestFake_VerifiesItsWebhooks(t *testing.T) {
+	t.Parallel()
+
+	fake := payment.NewFake([]byte("secret"))
+	event := tixer.PaymentEvent{
+		Type: tixer.PaymentEventCaptured,
+		Payment: tixer.Payment{
+			ID:       "fake_pay_1",
+			OrderID:  tixer.NewOrderID(),
+			Amount:   tixer.Money{Amount: 10_000, Currency: "EUR"},
+			Captured: tixer.Money{Amount: 10_000, Currency: "EUR"},
+			Refunded: tixer.Money{Currency: "EUR"},
+			Status:   tixer.PaymentCaptured,
+		},
+	}
+
+	payload, header, err := fake.Webhook(event)
+	if err != nil {
+		t.Fatalf("Webhook: %v", err)
+	}
+
+	got, err := fake.VerifyWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	event.ID = "fake_evt_1"
+	if got != event {
+		t.Errorf("Got event %+v, want %+v", got, event)
+	}
+
+	tampered := append([]byte(nil), payload...)
+	tampered[len(tampered)-2] = ' '
+	if _, err := fake.VerifyWebhook(tampered, header); !errors.Is(err, tixer.ErrInvalidWebhookSignature) {
+		t.Errorf("Got error %v for a tampered payload, want %v", err, tixer.ErrInvalidWebhookSignature)
+	}
+
+	other := payment.NewFake([]byte("other secret"))
+	if _, err := other.VerifyWebhook(payload, header); !errors.Is(err, tixer.ErrInvalidWebhookSignature) {
+		t.Errorf("Got error %v for another secret, want %v", err, tixer.ErrInvalidWebhookSignature)
+	}
+}
+
+func TestVerifySignature_RejectsAnOldSignature(t *testing.T) {
+	t.Parallel()
+
+	secret, payload := []byte("secret"), []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+
+	if err := payment.VerifySignature(secret, payload, payment.Sign(secret, payload, now), now); err != nil {
+		t.Errorf("Got error %v, want the signature accepted", err)
+	}
+
+	old := payment.Sign(secret, payload, now.Add(-payment.SignatureTolerance-time.Second))
+	if err := payment.VerifySignature(secret, payload, old, now); !errors.Is(err, tixer.ErrInvalidWebhookSignature) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrInvalidWebhookSignature)
+	}
+}
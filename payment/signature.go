@@ -0,0 +1,81 @@
+// Package payment implements tixer.PaymentProvider over the payment gateways.
+//
+// Every gateway has an adapter of its own, in a file named after it, which
+// translates the calls of the service to the API of the gateway and verifies
+// the signature of its webhooks. Fake is the adapter of a local gateway which
+// moves no money, for the tests and local development.
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// SignatureHeader is the header holding the signature of the webhooks signed by Sign.
+const SignatureHeader = "Tixer-Signature"
+
+// SignatureTolerance is how long a signed webhook is accepted after it was signed,
+// so that a delivery captured along the way cannot be replayed later on.
+const SignatureTolerance = 5 * time.Minute
+
+// Sign returns the signature of a webhook payload sent at the given time, as the value
+// of SignatureHeader: "t=<unix time>,v1=<HMAC-SHA256 of "<unix time>.<payload>">", the
+// scheme shared by the adapters of the gateways which sign their webhooks the same way.
+func Sign(secret, payload []byte, at time.Time) string {
+	t := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, payload)
+}
+
+// VerifySignature checks that the value of SignatureHeader of a webhook payload, header,
+// holds a signature of the payload made by Sign within SignatureTolerance of now.
+//
+// The header may hold several signatures, such as while the secret is rotated,
+// and one of them must match. It returns ErrInvalidWebhookSignature otherwise.
+func VerifySignature(secret, payload []byte, header string, now time.Time) error {
+	var (
+		t          string
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return fmt.Errorf("no timestamp: %w", tixer.ErrInvalidWebhookSignature)
+	}
+	if at := time.Unix(unix, 0); now.Sub(at) > SignatureTolerance || at.Sub(now) > SignatureTolerance {
+		return fmt.Errorf("signed at %s: %w", at.UTC().Format(time.RFC3339), tixer.ErrInvalidWebhookSignature)
+	}
+
+	want := signature(secret, t, payload)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+
+	return tixer.ErrInvalidWebhookSignature
+}
+
+// signature returns the hex encoded HMAC-SHA256 of the timestamp and the payload.
+func signature(secret []byte, t string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t + "."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
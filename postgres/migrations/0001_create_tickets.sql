@@ -0,0 +1,19 @@
+CREATE TABLE tickets (
+	id           UUID PRIMARY KEY,
+	title        TEXT NOT NULL,
+	price        DOUBLE PRECISION NOT NULL,
+	date_created TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+	date_updated TIMESTAMPTZ
+);
+
+-- Supports the keyset pagination used by ReadTickets.
+CREATE INDEX tickets_date_created_id_idx ON tickets (date_created DESC, id DESC);
+
+-- ticket_stats holds a single row with the total tickets counter,
+-- the same way the Firestore counter document does.
+CREATE TABLE ticket_stats (
+	id            BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	total_tickets BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO ticket_stats (id, total_tickets) VALUES (TRUE, 0);
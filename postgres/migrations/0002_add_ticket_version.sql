@@ -0,0 +1,2 @@
+-- version is used for optimistic concurrency control on updates and deletes.
+ALTER TABLE tickets ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+-- The event, seating and currency of a ticket are optional, so that
+-- the tickets created before they existed remain valid.
+ALTER TABLE tickets
+	ADD COLUMN event_id    TEXT NOT NULL DEFAULT '',
+	ADD COLUMN description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN venue       TEXT NOT NULL DEFAULT '',
+	ADD COLUMN starts_at   TIMESTAMPTZ,
+	ADD COLUMN section     TEXT NOT NULL DEFAULT '',
+	ADD COLUMN seat_row    TEXT NOT NULL DEFAULT '',
+	ADD COLUMN seat        TEXT NOT NULL DEFAULT '',
+	ADD COLUMN currency    TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Prices are stored as an integer amount of minor units of their currency,
+-- which has no decimals for JPY and KRW, and two for the other currencies.
+-- The prices without a currency are converted with two decimals.
+ALTER TABLE tickets ALTER COLUMN price TYPE BIGINT
+	USING round(price * CASE WHEN currency IN ('JPY', 'KRW') THEN 1 ELSE 100 END);
+
+-- Supports filtering the tickets by currency and price.
+CREATE INDEX tickets_currency_price_id_idx ON tickets (currency, price, id);
@@ -0,0 +1,6 @@
+-- The deleted tickets are kept, with the date they were deleted,
+-- until they are restored or purged.
+ALTER TABLE tickets ADD COLUMN date_deleted TIMESTAMPTZ;
+
+-- Supports purging the tickets deleted before a date.
+CREATE INDEX tickets_date_deleted_idx ON tickets (date_deleted) WHERE date_deleted IS NOT NULL;
@@ -0,0 +1,3 @@
+-- The tickets created before capacities existed are a single unit.
+-- The units sold and held are only counted by the stores which can hold tickets.
+ALTER TABLE tickets ADD COLUMN capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0);
@@ -0,0 +1,53 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/postgres"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+// TestStorer runs the conformance suite against the database pointed
+// by TIXER_POSTGRES_DSN, and is skipped when it is not set.
+//
+// The tables are emptied before every test, so the database must not be shared.
+func TestStorer(t *testing.T) {
+	db := newTestDB(t)
+
+	tixertest.RunTicketServiceSuite(t, func(t *testing.T) tixer.TicketService {
+		_, err := db.Exec(`
+			TRUNCATE tickets;
+			UPDATE ticket_stats SET total_tickets = 0;`)
+		if err != nil {
+			t.Fatalf("could not reset the database: %v", err)
+		}
+
+		return postgres.NewStorer(db)
+	})
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TIXER_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TIXER_POSTGRES_DSN not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := postgres.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("could not migrate the database: %v", err)
+	}
+
+	return db
+}
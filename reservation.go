@@ -0,0 +1,124 @@
+package tixer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type (
+	// Hold represents units of a ticket held for a holder, such as a buyer going
+	// through the checkout, so that no one else can hold them until DateExpires.
+	Hold struct {
+		TicketID    TicketID
+		Holder      string
+		Quantity    int
+		DateExpires time.Time
+	}
+
+	// ReservationService represents a service for holding tickets while they are bought.
+	//
+	// The units of a ticket, up to its capacity, are held by holders, a hold at most
+	// per holder, and are sold once their hold is confirmed: the units sold and held
+	// never exceed the capacity of the ticket, however many holders compete for them.
+	// An expired hold does not keep its units from being held by another holder, and
+	// is removed by ReleaseExpired. Holding, releasing or expiring a hold change Held,
+	// but not the version of the ticket.
+	ReservationService interface {
+		// Hold holds quantity units of the ticket for the holder until ttl from now, or
+		// replaces the hold of the holder, extended, when it already holds some.
+		// It returns ErrSoldOut when fewer units than quantity are left unsold,
+		// ErrAlreadyHeld when enough are left but other holders hold them,
+		// ErrInvalidQuantity when quantity is not positive, ErrTicketNotOnSale when
+		// the ticket is not on sale, and ErrTicketNotFound when it does not exist or is deleted.
+		Hold(ctx context.Context, ticketID TicketID, holder string, quantity int, ttl time.Duration) (Hold, error)
+
+		// Release removes the hold of the holder on the ticket, even an expired one,
+		// and returns ErrHoldNotFound when there is none.
+		Release(ctx context.Context, ticketID TicketID, holder string) error
+
+		// Confirm sells the units held by the holder, in place of its hold, and moves
+		// the ticket to StatusSoldOut once all its units are sold. It increments the version
+		// of the ticket. It returns ErrHoldNotFound when the holder does not hold the ticket
+		// or the hold expired, and ErrTicketNotOnSale when the ticket stopped being on sale
+		// since it was held.
+		Confirm(ctx context.Context, ticketID TicketID, holder string) (Ticket, error)
+
+		// ReleaseExpired removes the holds expired at the given time,
+		// and returns how many were removed.
+		ReleaseExpired(ctx context.Context, now time.Time) (int, error)
+	}
+)
+
+// Expired reports whether the hold no longer keeps the ticket at the given time.
+func (h Hold) Expired(now time.Time) bool {
+	return !now.Before(h.DateExpires)
+}
+
+// CheckHold reports whether quantity units of the ticket can be held by the holder,
+// alongside the holds of the other holders at the given time, and removes from holds
+// the expired ones, whose units can be held again. The holds are keyed by holder.
+//
+// It returns ErrInvalidQuantity, ErrSoldOut or ErrAlreadyHeld, see ReservationService.Hold.
+func (t Ticket) CheckHold(holds map[string]Hold, holder string, quantity int, now time.Time) error {
+	if quantity < 1 {
+		return fmt.Errorf("%d: %w", quantity, ErrInvalidQuantity)
+	}
+
+	unsold := t.CapacityOrDefault() - t.Sold
+	if quantity > unsold {
+		return fmt.Errorf("%d of %d: %w", quantity, unsold, ErrSoldOut)
+	}
+
+	var held int
+	for h, hold := range holds {
+		if h == holder {
+			continue
+		}
+		if hold.Expired(now) {
+			delete(holds, h)
+			continue
+		}
+		held += hold.Quantity
+	}
+	if quantity > unsold-held {
+		return fmt.Errorf("%d of %d: %w", quantity, unsold-held, ErrAlreadyHeld)
+	}
+
+	return nil
+}
+
+// Sell returns the ticket with quantity more units sold,
+// moved to StatusSoldOut when all its units are sold.
+func (t Ticket) Sell(quantity int) Ticket {
+	t.Sold += quantity
+	if t.Sold >= t.CapacityOrDefault() {
+		t.Status = StatusSoldOut
+	}
+
+	return t
+}
+
+// Restock returns the ticket with quantity less units sold, such as once they are
+// refunded, moved back to StatusOnSale when it was sold out.
+func (t Ticket) Restock(quantity int) Ticket {
+	t.Sold -= quantity
+	if t.Sold < 0 {
+		t.Sold = 0
+	}
+	if t.Status == StatusSoldOut && t.Sold < t.CapacityOrDefault() {
+		t.Status = StatusOnSale
+	}
+
+	return t
+}
+
+// HeldUnits returns the number of units held by the holds, expired or not.
+func HeldUnits(holds map[string]Hold) int {
+	var held int
+	for _, hold := range holds {
+		held += hold.Quantity
+	}
+
+	return held
+}
@@ -0,0 +1,50 @@
+package tixer
+
+import "context"
+
+type (
+	// SearchFilter represents the criteria used to search a page of tickets.
+	//
+	// The hits are ranked by decreasing relevance to the query, and the cursors
+	// are taken from the hits, the same way the cursors of Filter are.
+	SearchFilter struct {
+		Query  string
+		Before *Cursor
+		After  *Cursor
+		Limit  int
+	}
+
+	// SearchHit represents a ticket matching a search.
+	//
+	// Highlights holds, by field name, the HTML escaped text of the fields where
+	// the words of the query were found, with these words wrapped in <mark> tags.
+	SearchHit struct {
+		Ticket     Ticket
+		Score      float64
+		Highlights map[string]string
+	}
+
+	// SearchService represents a service for searching tickets by the words they contain.
+	//
+	// The Total of the returned Metadata counts the tickets matching the query.
+	SearchService interface {
+		SearchTickets(ctx context.Context, filter SearchFilter) ([]SearchHit, Metadata, error)
+	}
+)
+
+// Cursor returns the cursor positioned at the hit.
+func (h SearchHit) Cursor() *Cursor {
+	c := h.Ticket.Cursor()
+	c.Score = h.Score
+
+	return c
+}
+
+// HitCursors returns the cursors of the first and the last hits of a page.
+func HitCursors(hits []SearchHit) (before, after *Cursor) {
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	return hits[0].Cursor(), hits[len(hits)-1].Cursor()
+}
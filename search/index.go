@@ -0,0 +1,429 @@
+// Package search implements ticket search with an embedded inverted index.
+//
+// The index is held in memory and can be saved to a file, so that it does not
+// have to be rebuilt from the ticket store every time the service starts.
+package search
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// ErrIndexFormat is returned when loading an index file written in another format,
+// such as by an earlier version of the service. The index must then be rebuilt.
+var ErrIndexFormat = errors.New("unsupported search index format")
+
+// indexFormat is the version of the format of the index files, which is
+// incremented whenever the encoding of the saved tickets changes.
+const indexFormat = 2
+
+// The parameters of the BM25 ranking function.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Index is an inverted index of the words of the tickets.
+//
+// The hits of a search are ranked with BM25, which favours the tickets holding
+// the rarest words of the query, several times, in the shortest text.
+// A ticket matches when it holds any word of the query.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[tixer.TicketID]document
+	postings map[string]map[tixer.TicketID]int
+	totalLen int
+
+	// touched holds the tickets written while the index is rebuilt,
+	// whose indexed version is newer than the one read from the store.
+	touched map[tixer.TicketID]bool
+}
+
+// indexFile is the content of an index file.
+type indexFile struct {
+	Format  int
+	Tickets []tixer.Ticket
+}
+
+// document is an indexed ticket along with its number of occurrences of each word.
+type document struct {
+	ticket tixer.Ticket
+	terms  map[string]int
+	length int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[tixer.TicketID]document),
+		postings: make(map[string]map[tixer.TicketID]int),
+	}
+}
+
+// Len returns the number of indexed tickets.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+// IndexTicket adds the ticket to the index, replacing its previous version.
+func (ix *Index) IndexTicket(ticket tixer.Ticket) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.touch(ticket.ID)
+	ix.remove(ticket.ID)
+	ix.add(ticket)
+}
+
+// RemoveTicket removes the ticket from the index.
+func (ix *Index) RemoveTicket(id tixer.TicketID) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.touch(id)
+	ix.remove(id)
+}
+
+// SearchTickets returns a page of the tickets holding the words of the query,
+// ranked by decreasing relevance. Ties are broken by ticket ID, in the same direction.
+//
+// The scores depend on all the indexed tickets, so a page read from a cursor
+// may skip or repeat hits when tickets were indexed since the cursor was taken.
+func (ix *Index) SearchTickets(ctx context.Context, filter tixer.SearchFilter) ([]tixer.SearchHit, tixer.Metadata, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	terms := queryTerms(filter.Query)
+	scores := make(map[tixer.TicketID]float64)
+	for _, term := range terms {
+		posting := ix.postings[term]
+		idf := math.Log(1 + (float64(len(ix.docs))-float64(len(posting))+0.5)/(float64(len(posting))+0.5))
+		for id, tf := range posting {
+			norm := 1 - bm25B + bm25B*float64(ix.docs[id].length)/ix.avgLength()
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+		}
+	}
+
+	ranked := make([]tixer.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, tixer.SearchHit{Ticket: ix.docs[id].ticket, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranksBefore(*ranked[i].Cursor(), *ranked[j].Cursor())
+	})
+	total := len(ranked)
+
+	if filter.After != nil {
+		i := sort.Search(len(ranked), func(i int) bool {
+			return ranksBefore(*filter.After, *ranked[i].Cursor())
+		})
+		ranked = ranked[i:]
+	}
+	if filter.Before != nil {
+		i := sort.Search(len(ranked), func(i int) bool {
+			return !ranksBefore(*ranked[i].Cursor(), *filter.Before)
+		})
+		ranked = ranked[:i]
+	}
+
+	if filter.Limit > 0 && len(ranked) > filter.Limit {
+		if filter.Before != nil && filter.After == nil {
+			ranked = ranked[len(ranked)-filter.Limit:]
+		} else {
+			ranked = ranked[:filter.Limit]
+		}
+	}
+
+	var hits []tixer.SearchHit
+	for _, hit := range ranked {
+		hit.Highlights = highlights(hit.Ticket, terms)
+		hits = append(hits, hit)
+	}
+
+	before, after := tixer.HitCursors(hits)
+
+	return hits, tixer.Metadata{
+		After:  after,
+		Before: before,
+		Total:  total,
+	}, nil
+}
+
+// Rebuild replaces the content of the index with all the tickets of the service.
+//
+// The tickets indexed or removed while the index is rebuilt keep their
+// indexed version, so it can run while the tickets are being written.
+// Only one rebuild must run at a time.
+func (ix *Index) Rebuild(ctx context.Context, tickets tixer.TicketService) error {
+	ix.mu.Lock()
+	ix.touched = make(map[tixer.TicketID]bool)
+	ix.mu.Unlock()
+	defer func() {
+		ix.mu.Lock()
+		ix.touched = nil
+		ix.mu.Unlock()
+	}()
+
+	fresh := NewIndex()
+
+	filter := tixer.Filter{Limit: tixer.MaxBatchSize}
+	for {
+		tt, met, err := tickets.ReadTickets(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, tck := range tt {
+			fresh.add(tck)
+		}
+		// A short page does not mean that the tickets are exhausted,
+		// as the store may skip the documents which are not tickets.
+		if len(tt) == 0 || met.After == nil {
+			break
+		}
+		filter.After = met.After
+	}
+
+	ix.replace(fresh)
+
+	return nil
+}
+
+// Save writes the indexed tickets to the file at path.
+//
+// The file is replaced atomically, so a failed save leaves the previous one intact.
+func (ix *Index) Save(path string) error {
+	ix.mu.RLock()
+	tt := make([]tixer.Ticket, 0, len(ix.docs))
+	for _, doc := range ix.docs {
+		tt = append(tt, doc.ticket)
+	}
+	ix.mu.RUnlock()
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := gob.NewEncoder(f).Encode(indexFile{Format: indexFormat, Tickets: tt}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Load replaces the content of the index with the tickets saved to the file at path.
+// When the file does not exist, the returned error matches fs.ErrNotExist,
+// and when it was saved in another format, it matches ErrIndexFormat.
+func (ix *Index) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The files saved before the format was versioned hold a bare list of
+	// tickets, which cannot be decoded as an indexFile.
+	var file indexFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrIndexFormat)
+	}
+	if file.Format != indexFormat {
+		return fmt.Errorf("format %d: %w", file.Format, ErrIndexFormat)
+	}
+
+	fresh := NewIndex()
+	for _, tck := range file.Tickets {
+		fresh.add(tck)
+	}
+
+	ix.replace(fresh)
+
+	return nil
+}
+
+// replace swaps the content of the index with the content of the fresh one,
+// except for the tickets written since the rebuild started.
+func (ix *Index) replace(fresh *Index) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	for id := range ix.touched {
+		fresh.remove(id)
+		if doc, ok := ix.docs[id]; ok {
+			fresh.add(doc.ticket)
+		}
+	}
+
+	ix.docs = fresh.docs
+	ix.postings = fresh.postings
+	ix.totalLen = fresh.totalLen
+}
+
+// touch records the write of the ticket while the index is rebuilt.
+func (ix *Index) touch(id tixer.TicketID) {
+	if ix.touched != nil {
+		ix.touched[id] = true
+	}
+}
+
+func (ix *Index) add(ticket tixer.Ticket) {
+	doc := document{
+		ticket: ticket,
+		terms:  make(map[string]int),
+	}
+	for _, text := range fields(ticket) {
+		for _, tok := range tokenize(text) {
+			doc.terms[tok.term]++
+			doc.length++
+		}
+	}
+
+	for term, tf := range doc.terms {
+		posting, ok := ix.postings[term]
+		if !ok {
+			posting = make(map[tixer.TicketID]int)
+			ix.postings[term] = posting
+		}
+		posting[ticket.ID] = tf
+	}
+	ix.docs[ticket.ID] = doc
+	ix.totalLen += doc.length
+}
+
+func (ix *Index) remove(id tixer.TicketID) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for term := range doc.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.docs, id)
+	ix.totalLen -= doc.length
+}
+
+func (ix *Index) avgLength() float64 {
+	if len(ix.docs) == 0 || ix.totalLen == 0 {
+		return 1
+	}
+
+	return float64(ix.totalLen) / float64(len(ix.docs))
+}
+
+// ranksBefore reports whether the hit at cursor a ranks before the hit at cursor b.
+func ranksBefore(a, b tixer.Cursor) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+
+	return a.ID.String() > b.ID.String()
+}
+
+// fields returns the searchable text of the ticket, by field name.
+func fields(ticket tixer.Ticket) map[string]string {
+	return map[string]string{
+		"title":       ticket.Title,
+		"description": ticket.Description,
+	}
+}
+
+// highlights returns the fields of the ticket holding any of the terms, as HTML
+// escaped text with these terms wrapped in <mark> tags.
+func highlights(ticket tixer.Ticket, terms []string) map[string]string {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	hl := make(map[string]string)
+	for name, text := range fields(ticket) {
+		var (
+			b     strings.Builder
+			last  int
+			found bool
+		)
+		for _, tok := range tokenize(text) {
+			if !wanted[tok.term] {
+				continue
+			}
+			b.WriteString(html.EscapeString(text[last:tok.start]))
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(text[tok.start:tok.end]))
+			b.WriteString("</mark>")
+			last = tok.end
+			found = true
+		}
+		if found {
+			b.WriteString(html.EscapeString(text[last:]))
+			hl[name] = b.String()
+		}
+	}
+
+	return hl
+}
+
+// token is a word of a text, lower cased, along with its byte offsets in the text.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits the text into words, made of letters and digits.
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		start  = -1
+	)
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+// queryTerms returns the distinct words of the query.
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, tok := range tokenize(query) {
+		if !seen[tok.term] {
+			seen[tok.term] = true
+			terms = append(terms, tok.term)
+		}
+	}
+
+	return terms
+}
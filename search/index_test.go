@@ -0,0 +1,302 @@
+package search_test
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/inmem"
+	"github.com/mroobert/tixer-tickets/search"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+func TestTicketService(t *testing.T) {
+	t.Parallel()
+
+	tixertest.RunTicketServiceSuite(t, func(t *testing.T) tixer.TicketService {
+		return search.NewTicketService(inmem.NewStorer(), search.NewIndex())
+	})
+}
+
+func TestIndex_RanksAndHighlightsTheHits(t *testing.T) {
+	t.Parallel()
+
+	ix := search.NewIndex()
+	rock := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Rock concert, front row"}
+	jazz := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Jazz Concert"}
+	rockRock := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Rock & Rock"}
+	ix.IndexTicket(rock)
+	ix.IndexTicket(jazz)
+	ix.IndexTicket(rockRock)
+	ix.IndexTicket(tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera"})
+
+	hits, met, err := ix.SearchTickets(context.Background(), tixer.SearchFilter{Query: "rock CONCERT", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	if met.Total != 3 {
+		t.Errorf("Got total %d, want 3", met.Total)
+	}
+	assertHits(t, hits, rock.ID, rockRock.ID, jazz.ID)
+
+	if got, want := hits[0].Highlights["title"], "<mark>Rock</mark> <mark>concert</mark>, front row"; got != want {
+		t.Errorf("Got highlight %q, want %q", got, want)
+	}
+	if got, want := hits[2].Highlights["title"], "Jazz <mark>Concert</mark>"; got != want {
+		t.Errorf("Got highlight %q, want %q", got, want)
+	}
+}
+
+func TestIndex_EscapesTheHighlights(t *testing.T) {
+	t.Parallel()
+
+	ix := search.NewIndex()
+	ix.IndexTicket(tixer.Ticket{ID: tixer.NewTicketID(), Title: `<script>alert("rock")</script> & Roll`})
+
+	hits, _, err := ix.SearchTickets(context.Background(), tixer.SearchFilter{Query: "rock", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("Got %d hits, want 1", len(hits))
+	}
+
+	if got, want := hits[0].Highlights["title"], "&lt;script&gt;alert(&#34;<mark>rock</mark>&#34;)&lt;/script&gt; &amp; Roll"; got != want {
+		t.Errorf("Got highlight %q, want %q", got, want)
+	}
+}
+
+func TestIndex_PagesWithTheCursors(t *testing.T) {
+	t.Parallel()
+
+	ix := search.NewIndex()
+	for i := 0; i < 5; i++ {
+		ix.IndexTicket(tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert"})
+	}
+	ctx := context.Background()
+
+	all, _, err := ix.SearchTickets(ctx, tixer.SearchFilter{Query: "concert", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+
+	page, met, err := ix.SearchTickets(ctx, tixer.SearchFilter{Query: "concert", Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	assertHits(t, page, all[0].Ticket.ID, all[1].Ticket.ID)
+
+	page, met, err = ix.SearchTickets(ctx, tixer.SearchFilter{Query: "concert", After: met.After, Limit: 2})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	assertHits(t, page, all[2].Ticket.ID, all[3].Ticket.ID)
+
+	page, _, err = ix.SearchTickets(ctx, tixer.SearchFilter{Query: "concert", Before: met.Before, Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	assertHits(t, page, all[1].Ticket.ID)
+}
+
+func TestIndex_KeepsInSyncWithTheTicketService(t *testing.T) {
+	t.Parallel()
+
+	ix := search.NewIndex()
+	svc := search.NewTicketService(inmem.NewStorer(), ix)
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Rock concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}}
+	if err := svc.CreateTicket(ctx, tck); err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+	assertSearch(t, ix, "rock", tck.ID)
+
+	title := "Jazz concert"
+	if _, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: &title}); err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	assertSearch(t, ix, "rock")
+	assertSearch(t, ix, "jazz", tck.ID)
+
+	if err := svc.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+	assertSearch(t, ix, "jazz")
+}
+
+func TestIndex_RebuildsFromTheTicketService(t *testing.T) {
+	t.Parallel()
+
+	tickets := inmem.NewStorer()
+	ctx := context.Background()
+	for i := 0; i < tixer.MaxBatchSize+1; i++ {
+		if err := tickets.CreateTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}}); err != nil {
+			t.Fatalf("CreateTicket: %v", err)
+		}
+	}
+
+	ix := search.NewIndex()
+	ix.IndexTicket(tixer.Ticket{ID: tixer.NewTicketID(), Title: "Stale"})
+	if err := ix.Rebuild(ctx, tickets); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	if got, want := ix.Len(), tixer.MaxBatchSize+1; got != want {
+		t.Errorf("Got %d indexed tickets, want %d", got, want)
+	}
+	assertSearch(t, ix, "stale")
+}
+
+func TestIndex_RebuildsPastShortPages(t *testing.T) {
+	t.Parallel()
+
+	tickets := shortPages{inmem.NewStorer()}
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := tickets.CreateTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}}); err != nil {
+			t.Fatalf("CreateTicket: %v", err)
+		}
+	}
+
+	ix := search.NewIndex()
+	if err := ix.Rebuild(ctx, tickets); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	if got, want := ix.Len(), 5; got != want {
+		t.Errorf("Got %d indexed tickets, want %d", got, want)
+	}
+}
+
+func TestIndex_KeepsTheWritesMadeWhileRebuilding(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := inmem.NewStorer()
+	deleted := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Jazz concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}}
+	if err := store.CreateTicket(ctx, deleted); err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+
+	ix := search.NewIndex()
+	ix.IndexTicket(deleted)
+	created := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Rock concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}}
+
+	// The writes land once the store was read, before the index is replaced.
+	tickets := afterRead{Storer: store, write: func() {
+		ix.IndexTicket(created)
+		ix.RemoveTicket(deleted.ID)
+	}}
+	if err := ix.Rebuild(ctx, tickets); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	assertSearch(t, ix, "jazz")
+	assertSearch(t, ix, "rock", created.ID)
+}
+
+func TestIndex_SavesAndLoadsTheTickets(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tickets.index")
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Rock concert", Price: tixer.Money{Amount: 1000, Currency: "EUR"}, Version: 2}
+
+	saved := search.NewIndex()
+	saved.IndexTicket(tck)
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := search.NewIndex()
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	hits, _, err := loaded.SearchTickets(context.Background(), tixer.SearchFilter{Query: "concert", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ticket != tck {
+		t.Errorf("Got hits %+v, want the saved ticket %+v", hits, tck)
+	}
+}
+
+func TestIndex_RejectsAFileOfAnotherFormat(t *testing.T) {
+	t.Parallel()
+
+	// The files saved before the format was versioned hold a bare list of tickets.
+	path := filepath.Join(t.TempDir(), "tickets.index")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := gob.NewEncoder(f).Encode([]tixer.Ticket{{ID: tixer.NewTicketID(), Title: "Concert"}}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	f.Close()
+
+	if err := search.NewIndex().Load(path); !errors.Is(err, search.ErrIndexFormat) {
+		t.Errorf("Got %v, want ErrIndexFormat", err)
+	}
+}
+
+// shortPages is a ticket service which returns a single ticket per page,
+// like a store skipping the documents which are not tickets.
+type shortPages struct {
+	*inmem.Storer
+}
+
+func (s shortPages) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
+	tt, met, err := s.Storer.ReadTickets(ctx, filter)
+	if err != nil || len(tt) <= 1 {
+		return tt, met, err
+	}
+	tt = tt[:1]
+	met.Before, met.After = tixer.PageCursors(tt)
+
+	return tt, met, nil
+}
+
+// afterRead is a ticket service which runs write once its first page was read.
+type afterRead struct {
+	*inmem.Storer
+	write func()
+}
+
+func (s afterRead) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
+	tt, met, err := s.Storer.ReadTickets(ctx, filter)
+	if filter.After == nil {
+		s.write()
+	}
+
+	return tt, met, err
+}
+
+// assertSearch checks that the query finds exactly the given tickets, in order.
+func assertSearch(t *testing.T, ix *search.Index, query string, want ...tixer.TicketID) {
+	t.Helper()
+
+	hits, _, err := ix.SearchTickets(context.Background(), tixer.SearchFilter{Query: query, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchTickets: %v", err)
+	}
+	assertHits(t, hits, want...)
+}
+
+func assertHits(t *testing.T, hits []tixer.SearchHit, want ...tixer.TicketID) {
+	t.Helper()
+
+	if len(hits) != len(want) {
+		t.Fatalf("Got %d hits, want %d", len(hits), len(want))
+	}
+	for i := range want {
+		if hits[i].Ticket.ID != want[i] {
+			t.Errorf("Got hit %d with ticket %s, want %s", i, hits[i].Ticket.ID, want[i])
+		}
+	}
+}
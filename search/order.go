@@ -0,0 +1,60 @@
+package search
+
+import (
+	"context"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// OrderService keeps an index in sync with the tickets sold by an order service,
+// whose units sold and status change once an order is paid or a refund of it succeeds.
+//
+// The orders do not hold their tickets, so the tickets of a paid or refunded order
+// are read back from the ticket service to be indexed. A ticket which cannot be read
+// is left as is, until the index is refreshed.
+type OrderService struct {
+	tixer.OrderService
+	tickets tixer.TicketService
+	index   *Index
+}
+
+func NewOrderService(orders tixer.OrderService, tickets tixer.TicketService, index *Index) *OrderService {
+	return &OrderService{
+		orders,
+		tickets,
+		index,
+	}
+}
+
+func (s *OrderService) PayOrder(ctx context.Context, id tixer.OrderID, payment tixer.Payment) (tixer.Order, error) {
+	order, err := s.OrderService.PayOrder(ctx, id, payment)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	s.indexTickets(ctx, order.Items)
+
+	return order, nil
+}
+
+func (s *OrderService) ConfirmRefund(ctx context.Context, id tixer.OrderID, refundID, paymentRefundID string) (tixer.Order, error) {
+	order, err := s.OrderService.ConfirmRefund(ctx, id, refundID, paymentRefundID)
+	if err != nil {
+		return tixer.Order{}, err
+	}
+
+	if refund, err := order.FindRefund(refundID); err == nil {
+		s.indexTickets(ctx, refund.Items)
+	}
+
+	return order, nil
+}
+
+// indexTickets indexes the tickets of the line items, read back from the ticket service.
+func (s *OrderService) indexTickets(ctx context.Context, items []tixer.OrderItem) {
+	for _, item := range items {
+		if tck, err := s.tickets.ReadTicket(ctx, item.TicketID); err == nil {
+			s.index.IndexTicket(tck)
+		}
+	}
+}
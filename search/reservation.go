@@ -0,0 +1,35 @@
+package search
+
+import (
+	"context"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// ReservationService keeps an index in sync with the tickets sold by a
+// reservation service, whose units sold and status change once a hold is confirmed.
+//
+// The holds are not changes of the tickets, so the units held by the indexed
+// tickets are only brought up to date when the index is refreshed.
+type ReservationService struct {
+	tixer.ReservationService
+	index *Index
+}
+
+func NewReservationService(reservations tixer.ReservationService, index *Index) *ReservationService {
+	return &ReservationService{
+		reservations,
+		index,
+	}
+}
+
+func (s *ReservationService) Confirm(ctx context.Context, ticketID tixer.TicketID, holder string) (tixer.Ticket, error) {
+	tck, err := s.ReservationService.Confirm(ctx, ticketID, holder)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	s.index.IndexTicket(tck)
+
+	return tck, nil
+}
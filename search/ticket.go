@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// TicketService keeps an index in sync with the writes of a ticket service.
+//
+// Every write is applied to the ticket service first, and only the tickets
+// it wrote are indexed. The created tickets are indexed without the creation
+// date set by the ticket service, which is not used by the search, and with
+// the status and the capacity they are created with.
+type TicketService struct {
+	tixer.TicketService
+	index *Index
+}
+
+func NewTicketService(tickets tixer.TicketService, index *Index) *TicketService {
+	return &TicketService{
+		tickets,
+		index,
+	}
+}
+
+func (s *TicketService) CreateTicket(ctx context.Context, ticket tixer.Ticket) error {
+	if err := s.TicketService.CreateTicket(ctx, ticket); err != nil {
+		return err
+	}
+
+	ticket.Version = 1
+	ticket.Status = ticket.Status.OrDefault()
+	ticket.Capacity = ticket.CapacityOrDefault()
+	s.index.IndexTicket(ticket)
+
+	return nil
+}
+
+func (s *TicketService) CreateTickets(ctx context.Context, tickets []tixer.Ticket) error {
+	if err := s.TicketService.CreateTickets(ctx, tickets); err != nil {
+		return err
+	}
+
+	for _, ticket := range tickets {
+		ticket.Version = 1
+		ticket.Status = ticket.Status.OrDefault()
+		ticket.Capacity = ticket.CapacityOrDefault()
+		s.index.IndexTicket(ticket)
+	}
+
+	return nil
+}
+
+func (s *TicketService) UpdateTicket(ctx context.Context, update tixer.TicketUpdate) (tixer.Ticket, error) {
+	tck, err := s.TicketService.UpdateTicket(ctx, update)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	s.index.IndexTicket(tck)
+
+	return tck, nil
+}
+
+func (s *TicketService) UpdateTickets(ctx context.Context, updates []tixer.TicketUpdate) ([]tixer.TicketResult, error) {
+	results, err := s.TicketService.UpdateTickets(ctx, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range results {
+		if res.Err == nil {
+			s.index.IndexTicket(res.Ticket)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *TicketService) ReplaceTicket(ctx context.Context, ticket tixer.Ticket) (tixer.Ticket, bool, error) {
+	tck, created, err := s.TicketService.ReplaceTicket(ctx, ticket)
+	if err != nil {
+		return tixer.Ticket{}, false, err
+	}
+
+	s.index.IndexTicket(tck)
+
+	return tck, created, nil
+}
+
+func (s *TicketService) TransitionTicket(ctx context.Context, id tixer.TicketID, next tixer.TicketStatus, version int) (tixer.Ticket, error) {
+	tck, err := s.TicketService.TransitionTicket(ctx, id, next, version)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	s.index.IndexTicket(tck)
+
+	return tck, nil
+}
+
+func (s *TicketService) DeleteTicket(ctx context.Context, id tixer.TicketID, version int) error {
+	if err := s.TicketService.DeleteTicket(ctx, id, version); err != nil {
+		return err
+	}
+
+	s.index.RemoveTicket(id)
+
+	return nil
+}
+
+func (s *TicketService) DeleteTickets(ctx context.Context, ids []tixer.TicketID) ([]error, error) {
+	errs, err := s.TicketService.DeleteTickets(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, err := range errs {
+		if err == nil {
+			s.index.RemoveTicket(ids[i])
+		}
+	}
+
+	return errs, nil
+}
+
+func (s *TicketService) RestoreTicket(ctx context.Context, id tixer.TicketID, version int) (tixer.Ticket, error) {
+	tck, err := s.TicketService.RestoreTicket(ctx, id, version)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	s.index.IndexTicket(tck)
+
+	return tck, nil
+}
@@ -0,0 +1,21 @@
+-- Dates are stored as Unix time in nanoseconds, so that they sort
+-- the same way as the Firestore and PostgreSQL timestamps.
+CREATE TABLE tickets (
+	id           TEXT PRIMARY KEY,
+	title        TEXT NOT NULL,
+	price        REAL NOT NULL,
+	date_created INTEGER NOT NULL,
+	date_updated INTEGER
+);
+
+-- Supports the keyset pagination used by ReadTickets.
+CREATE INDEX tickets_date_created_id_idx ON tickets (date_created DESC, id DESC);
+
+-- ticket_stats holds a single row with the total tickets counter,
+-- the same way the Firestore counter document does.
+CREATE TABLE ticket_stats (
+	id            INTEGER PRIMARY KEY CHECK (id = 1),
+	total_tickets INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO ticket_stats (id, total_tickets) VALUES (1, 0);
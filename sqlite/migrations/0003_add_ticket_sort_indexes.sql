@@ -0,0 +1,3 @@
+-- Supports the keyset pagination used by ReadTickets when sorting by price or by title.
+CREATE INDEX tickets_price_id_idx ON tickets (price, id);
+CREATE INDEX tickets_title_id_idx ON tickets (title, id);
@@ -0,0 +1,11 @@
+-- The event, seating and currency of a ticket are optional, so that
+-- the tickets created before they existed remain valid.
+-- starts_at is stored as Unix time in nanoseconds, like the other dates.
+ALTER TABLE tickets ADD COLUMN event_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN venue TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN starts_at INTEGER;
+ALTER TABLE tickets ADD COLUMN section TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN seat_row TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN seat TEXT NOT NULL DEFAULT '';
+ALTER TABLE tickets ADD COLUMN currency TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,38 @@
+-- Prices are stored as an integer amount of minor units of their currency,
+-- which has no decimals for JPY and KRW, and two for the other currencies.
+-- The prices without a currency are converted with two decimals.
+--
+-- SQLite cannot change the type of a column, and a REAL column would store
+-- the amounts as floating point numbers, so the table is rebuilt.
+CREATE TABLE tickets_new (
+	id           TEXT PRIMARY KEY,
+	event_id     TEXT NOT NULL DEFAULT '',
+	title        TEXT NOT NULL,
+	description  TEXT NOT NULL DEFAULT '',
+	venue        TEXT NOT NULL DEFAULT '',
+	starts_at    INTEGER,
+	section      TEXT NOT NULL DEFAULT '',
+	seat_row     TEXT NOT NULL DEFAULT '',
+	seat         TEXT NOT NULL DEFAULT '',
+	price        INTEGER NOT NULL,
+	currency     TEXT NOT NULL DEFAULT '',
+	version      INTEGER NOT NULL DEFAULT 1,
+	date_created INTEGER NOT NULL,
+	date_updated INTEGER
+);
+
+INSERT INTO tickets_new (id, event_id, title, description, venue, starts_at, section, seat_row, seat, price, currency, version, date_created, date_updated)
+SELECT id, event_id, title, description, venue, starts_at, section, seat_row, seat,
+	CAST(round(price * CASE WHEN currency IN ('JPY', 'KRW') THEN 1 ELSE 100 END) AS INTEGER),
+	currency, version, date_created, date_updated
+FROM tickets;
+
+DROP TABLE tickets;
+ALTER TABLE tickets_new RENAME TO tickets;
+
+CREATE INDEX tickets_date_created_id_idx ON tickets (date_created DESC, id DESC);
+CREATE INDEX tickets_price_id_idx ON tickets (price, id);
+CREATE INDEX tickets_title_id_idx ON tickets (title, id);
+
+-- Supports filtering the tickets by currency and price.
+CREATE INDEX tickets_currency_price_id_idx ON tickets (currency, price, id);
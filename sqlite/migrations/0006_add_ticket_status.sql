@@ -0,0 +1,5 @@
+-- The tickets created before statuses existed are on sale.
+ALTER TABLE tickets ADD COLUMN status TEXT NOT NULL DEFAULT 'on_sale';
+
+-- Supports filtering the tickets by status.
+CREATE INDEX tickets_status_date_created_id_idx ON tickets (status, date_created, id);
@@ -0,0 +1,745 @@
+// Package sqlite implements ticket service over an embedded SQLite database.
+//
+// It uses a pure Go driver, so the service can still be built with CGO disabled.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mroobert/tixer-tickets"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ticketColumns are the columns of a ticket, in the order read by scanTicket.
+const ticketColumns = `id, event_id, title, description, venue, starts_at, section, seat_row, seat, price, currency, status, capacity, version, date_created, date_updated, date_deleted`
+
+// insertColumns are the columns of a ticket set on creation,
+// in the order of ticketArgs followed by the status, the capacity and the creation date.
+const insertColumns = `id, event_id, title, description, venue, starts_at, section, seat_row, seat, price, currency, status, capacity, date_created`
+
+// Storer persists tickets in SQLite.
+type Storer struct {
+	db *sql.DB
+}
+
+func NewStorer(db *sql.DB) *Storer {
+	return &Storer{
+		db,
+	}
+}
+
+// Open opens the SQLite database stored at path, creating it if needed.
+//
+// SQLite allows a single writer at a time, so the pool is limited to one
+// connection. This also keeps ":memory:" databases alive between queries.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(ON)")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// CreateTicket creates a ticket in SQLite.
+//
+// It uses a transaction to ensure atomicity regarding
+// the creation of the ticket and the increment of the total_tickets column.
+func (s *Storer) CreateTicket(ctx context.Context, ticket tixer.Ticket) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := s.insertTicket(ctx, tx, ticket); err != nil {
+			return err
+		}
+
+		return s.incrementTotal(ctx, tx, 1)
+	})
+}
+
+// CreateTickets creates tickets in SQLite.
+//
+// It uses a transaction to ensure atomicity regarding the creation
+// of the tickets and a single increment of the total_tickets column.
+func (s *Storer) CreateTickets(ctx context.Context, tickets []tixer.Ticket) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, ticket := range tickets {
+			if err := s.insertTicket(ctx, tx, ticket); err != nil {
+				return err
+			}
+		}
+
+		return s.incrementTotal(ctx, tx, len(tickets))
+	})
+}
+
+func (s *Storer) ReadTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM tickets
+		WHERE id = ? AND date_deleted IS NULL`,
+		id.String(),
+	)
+
+	return scanTicket(row)
+}
+
+// UpdateTicket updates a ticket in SQLite.
+//
+// It uses a transaction, so that the version can be checked
+// against the expected one before being incremented.
+//
+// Only the fields provided by the update are written.
+func (s *Storer) UpdateTicket(ctx context.Context, update tixer.TicketUpdate) (tixer.Ticket, error) {
+	var tck tixer.Ticket
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		tck, err = s.updateTicket(ctx, tx, update)
+		return err
+	})
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return tck, nil
+}
+
+// UpdateTickets updates a batch of tickets in SQLite.
+//
+// It uses a single transaction to apply all the valid updates together.
+func (s *Storer) UpdateTickets(ctx context.Context, updates []tixer.TicketUpdate) ([]tixer.TicketResult, error) {
+	results := make([]tixer.TicketResult, len(updates))
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		for i, update := range updates {
+			tck, err := s.updateTicket(ctx, tx, update)
+			switch {
+			case errors.Is(err, tixer.ErrTicketNotFound), errors.Is(err, tixer.ErrVersionConflict):
+				results[i] = tixer.TicketResult{Err: err}
+			case err != nil:
+				return err
+			default:
+				results[i] = tixer.TicketResult{Ticket: tck}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ReplaceTicket replaces the editable fields of a ticket in SQLite,
+// or creates the ticket when it does not exist.
+//
+// It uses a transaction to ensure atomicity regarding the creation
+// of the ticket and the increment of the total_tickets column, the same
+// way CreateTicket does.
+func (s *Storer) ReplaceTicket(ctx context.Context, ticket tixer.Ticket) (tixer.Ticket, bool, error) {
+	var (
+		tck     tixer.Ticket
+		created bool
+	)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UnixNano()
+
+		err := s.checkVersion(ctx, tx, ticket.ID, ticket.Version, false)
+		switch {
+		case errors.Is(err, tixer.ErrTicketNotFound):
+			if s.checkVersion(ctx, tx, ticket.ID, tixer.AnyVersion, true) == nil {
+				return tixer.ErrTicketDeleted
+			}
+			if ticket.Version != tixer.AnyVersion {
+				return tixer.ErrVersionConflict
+			}
+
+			row := tx.QueryRowContext(ctx, `
+				INSERT INTO tickets (`+insertColumns+`)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14)
+				RETURNING `+ticketColumns,
+				append(ticketArgs(ticket), string(ticket.Status.OrDefault()), ticket.CapacityOrDefault(), now)...,
+			)
+
+			tck, err = scanTicket(row)
+			if err != nil {
+				return err
+			}
+
+			created = true
+			return s.incrementTotal(ctx, tx, 1)
+		case err != nil:
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, `
+			UPDATE tickets
+			SET event_id     = ?2,
+			    title        = ?3,
+			    description  = ?4,
+			    venue        = ?5,
+			    starts_at    = ?6,
+			    section      = ?7,
+			    seat_row     = ?8,
+			    seat         = ?9,
+			    price        = ?10,
+			    currency     = ?11,
+			    version      = version + 1,
+			    date_updated = ?12
+			WHERE id = ?1
+			RETURNING `+ticketColumns,
+			append(ticketArgs(ticket), now)...,
+		)
+
+		tck, err = scanTicket(row)
+		return err
+	})
+	if err != nil {
+		return tixer.Ticket{}, false, err
+	}
+
+	return tck, created, nil
+}
+
+// TransitionTicket moves a ticket to the next status in SQLite.
+//
+// It uses a transaction so that the version and the status
+// of the ticket can be checked before being changed.
+func (s *Storer) TransitionTicket(ctx context.Context, id tixer.TicketID, next tixer.TicketStatus, version int) (tixer.Ticket, error) {
+	var tck tixer.Ticket
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, `
+			SELECT `+ticketColumns+`
+			FROM tickets
+			WHERE id = ? AND date_deleted IS NULL`,
+			id.String(),
+		)
+
+		stored, err := scanTicket(row)
+		if err != nil {
+			return err
+		}
+		if err := stored.CheckVersion(version); err != nil {
+			return err
+		}
+		if err := stored.CheckTransition(next); err != nil {
+			return err
+		}
+
+		row = tx.QueryRowContext(ctx, `
+			UPDATE tickets
+			SET status       = ?2,
+			    version      = version + 1,
+			    date_updated = ?3
+			WHERE id = ?1
+			RETURNING `+ticketColumns,
+			id.String(), string(next), time.Now().UnixNano(),
+		)
+
+		tck, err = scanTicket(row)
+		return err
+	})
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return tck, nil
+}
+
+// DeleteTicket soft deletes a ticket in SQLite.
+//
+// It uses a transaction to ensure atomicity regarding
+// the deletion of the ticket and the decrement of the total_tickets column.
+func (s *Storer) DeleteTicket(ctx context.Context, id tixer.TicketID, version int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := s.checkVersion(ctx, tx, id, version, false); err != nil {
+			return err
+		}
+		if err := s.deleteTicket(ctx, tx, id); err != nil {
+			return err
+		}
+
+		return s.incrementTotal(ctx, tx, -1)
+	})
+}
+
+// DeleteTickets soft deletes a batch of tickets in SQLite.
+//
+// It uses a transaction to ensure atomicity regarding the deletion of the
+// tickets and the decrement of the total_tickets column, which is decremented
+// by the number of tickets that actually existed and were not deleted yet.
+func (s *Storer) DeleteTickets(ctx context.Context, ids []tixer.TicketID) ([]error, error) {
+	results := make([]error, len(ids))
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var deleted int
+		for i, id := range ids {
+			err := s.deleteTicket(ctx, tx, id)
+			switch {
+			case errors.Is(err, tixer.ErrTicketNotFound):
+				results[i] = err
+			case err != nil:
+				return err
+			default:
+				deleted++
+			}
+		}
+
+		return s.incrementTotal(ctx, tx, -deleted)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *Storer) ReadDeletedTicket(ctx context.Context, id tixer.TicketID) (tixer.Ticket, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM tickets
+		WHERE id = ? AND date_deleted IS NOT NULL`,
+		id.String(),
+	)
+
+	return scanTicket(row)
+}
+
+// RestoreTicket restores a deleted ticket in SQLite.
+//
+// It uses a transaction to ensure atomicity regarding
+// the restoration of the ticket and the increment of the total_tickets column.
+func (s *Storer) RestoreTicket(ctx context.Context, id tixer.TicketID, version int) (tixer.Ticket, error) {
+	var tck tixer.Ticket
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := s.checkVersion(ctx, tx, id, version, true); err != nil {
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, `
+			UPDATE tickets
+			SET date_deleted = NULL,
+			    version      = version + 1,
+			    date_updated = ?2
+			WHERE id = ?1
+			RETURNING `+ticketColumns,
+			id.String(), time.Now().UnixNano(),
+		)
+
+		var err error
+		tck, err = scanTicket(row)
+		if err != nil {
+			return err
+		}
+
+		return s.incrementTotal(ctx, tx, 1)
+	})
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	return tck, nil
+}
+
+// PurgeTickets removes the tickets deleted before a date from SQLite.
+//
+// The total_tickets column is left as is, as it was decremented
+// when the tickets were deleted.
+func (s *Storer) PurgeTickets(ctx context.Context, deletedBefore time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tickets WHERE date_deleted < ?`, deletedBefore.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// ReadTickets reads a page of the tickets matching the filter, in the order of filter.Sort.
+//
+// It uses keyset pagination on the sorted column and the id, which matches
+// the Firestore StartAfter/EndBefore cursors of gcfirestore.Storer.
+// The values of the cursors are compared directly, their tickets are not read.
+func (s *Storer) ReadTickets(ctx context.Context, filter tixer.Filter) ([]tixer.Ticket, tixer.Metadata, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, tixer.Metadata{}, err
+	}
+	defer tx.Rollback()
+
+	var q filterQuery
+	column, desc := sortColumn(filter.Sort)
+
+	if filter.After != nil {
+		q.keyset(column, sortValue(*filter.After, filter.Sort), filter.After.ID, !desc)
+	}
+	if filter.Before != nil {
+		q.keyset(column, sortValue(*filter.Before, filter.Sort), filter.Before.ID, desc)
+	}
+
+	if !filter.IncludeDeleted {
+		q.conds = append(q.conds, "date_deleted IS NULL")
+	}
+	if filter.TitlePrefix != "" {
+		q.where("substr(title, 1, length(%[1]s)) = %[1]s", filter.TitlePrefix)
+	}
+	if filter.Status != "" {
+		q.where("status = %s", string(filter.Status))
+	}
+	if filter.Currency != "" {
+		q.where("currency = %s", string(filter.Currency))
+	}
+	if filter.PriceMin != nil {
+		q.where("price >= %s", *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		q.where("price <= %s", *filter.PriceMax)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q.where("date_created > %s", filter.CreatedAfter.UnixNano())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		q.where("date_created < %s", filter.CreatedBefore.UnixNano())
+	}
+
+	// The last tickets before the cursor are read in the reverse order, then reversed.
+	reverse := filter.Limit > 0 && filter.Before != nil && filter.After == nil
+	dir := "ASC"
+	if desc != reverse {
+		dir = "DESC"
+	}
+
+	query := `
+		SELECT ` + ticketColumns + `
+		FROM tickets`
+	if len(q.conds) > 0 {
+		query += `
+		WHERE ` + strings.Join(q.conds, " AND ")
+	}
+	query += fmt.Sprintf(`
+		ORDER BY %s %s, id %s`, column, dir, dir)
+	if filter.Limit > 0 {
+		query += `
+		LIMIT ` + q.arg(filter.Limit)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, tixer.Metadata{}, err
+	}
+	defer rows.Close()
+
+	var tt []tixer.Ticket
+	for rows.Next() {
+		tck, err := scanTicket(rows)
+		if err != nil {
+			return nil, tixer.Metadata{}, err
+		}
+
+		tt = append(tt, tck)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, tixer.Metadata{}, err
+	}
+	if reverse {
+		for i, j := 0, len(tt)-1; i < j; i, j = i+1, j-1 {
+			tt[i], tt[j] = tt[j], tt[i]
+		}
+	}
+
+	var total int
+	err = tx.QueryRowContext(ctx, `SELECT total_tickets FROM ticket_stats`).Scan(&total)
+	if err != nil {
+		return nil, tixer.Metadata{}, err
+	}
+
+	before, after := tixer.PageCursors(tt)
+
+	return tt, tixer.Metadata{
+		After:  after,
+		Before: before,
+		Total:  total,
+	}, nil
+}
+
+// filterQuery builds the WHERE clause of ReadTickets and its arguments.
+type filterQuery struct {
+	conds []string
+	args  []any
+}
+
+// arg adds an argument and returns its numbered placeholder.
+func (q *filterQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return "?" + strconv.Itoa(len(q.args))
+}
+
+// where adds a condition, whose %s verb is replaced by the placeholder of v.
+func (q *filterQuery) where(cond string, v any) {
+	q.conds = append(q.conds, fmt.Sprintf(cond, q.arg(v)))
+}
+
+// keyset adds the condition keeping the rows after, or before, the cursor
+// in the ascending order of (column, id).
+func (q *filterQuery) keyset(column string, value any, id tixer.TicketID, after bool) {
+	op := "<"
+	if after {
+		op = ">"
+	}
+
+	q.conds = append(q.conds, fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, q.arg(value), q.arg(id.String())))
+}
+
+// sortColumn returns the column of the order, and whether the order is descending.
+//
+// Titles are compared with the default BINARY collation, so that they sort
+// by code point the same way as in Firestore.
+func sortColumn(order tixer.TicketSort) (string, bool) {
+	field, desc := order.Field()
+	switch field {
+	case "price":
+		return "price", desc
+	case "title":
+		return "title", desc
+	default:
+		return "date_created", desc
+	}
+}
+
+// sortValue returns the value of the cursor for the column of the order.
+func sortValue(c tixer.Cursor, order tixer.TicketSort) any {
+	field, _ := order.Field()
+	switch field {
+	case "price":
+		return c.Price
+	case "title":
+		return c.Title
+	default:
+		return c.DateCreated.UnixNano()
+	}
+}
+
+// updateTicket checks the version of a ticket and applies the fields provided by the update.
+func (s *Storer) updateTicket(ctx context.Context, tx *sql.Tx, update tixer.TicketUpdate) (tixer.Ticket, error) {
+	if err := s.checkVersion(ctx, tx, update.ID, update.Version, false); err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	// A date cannot be told apart from a missing one by COALESCE,
+	// as setting it to its zero value clears it.
+	var startsAt sql.NullInt64
+	if update.StartsAt != nil {
+		startsAt = nullTime(*update.StartsAt)
+	}
+
+	// The amount and the currency of a price are updated together.
+	var (
+		price    sql.NullInt64
+		currency sql.NullString
+	)
+	if update.Price != nil {
+		price = sql.NullInt64{Int64: update.Price.Amount, Valid: true}
+		currency = sql.NullString{String: string(update.Price.Currency), Valid: true}
+	}
+
+	row := tx.QueryRowContext(ctx, `
+		UPDATE tickets
+		SET event_id     = COALESCE(?2, event_id),
+		    title        = COALESCE(?3, title),
+		    description  = COALESCE(?4, description),
+		    venue        = COALESCE(?5, venue),
+		    starts_at    = CASE WHEN ?6 THEN ?7 ELSE starts_at END,
+		    section      = COALESCE(?8, section),
+		    seat_row     = COALESCE(?9, seat_row),
+		    seat         = COALESCE(?10, seat),
+		    price        = COALESCE(?11, price),
+		    currency     = COALESCE(?12, currency),
+		    version      = version + 1,
+		    date_updated = ?13
+		WHERE id = ?1
+		RETURNING `+ticketColumns,
+		update.ID.String(), update.EventID, update.Title, update.Description, update.Venue,
+		update.StartsAt != nil, startsAt, update.Section, update.Row, update.Seat,
+		price, currency, time.Now().UnixNano(),
+	)
+
+	return scanTicket(row)
+}
+
+// deleteTicket soft deletes a ticket, without maintaining the total tickets counter.
+func (s *Storer) deleteTicket(ctx context.Context, tx *sql.Tx, id tixer.TicketID) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE tickets
+		SET date_deleted = ?2,
+		    version      = version + 1
+		WHERE id = ?1 AND date_deleted IS NULL`,
+		id.String(), time.Now().UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return tixer.ErrTicketNotFound
+	}
+
+	return nil
+}
+
+// checkVersion reports ErrVersionConflict when the stored version of the
+// ticket differs from the expected one.
+//
+// It reports ErrTicketNotFound when the ticket is deleted, or when
+// it is not deleted and deleted is set.
+func (s *Storer) checkVersion(ctx context.Context, tx *sql.Tx, id tixer.TicketID, expected int, deleted bool) error {
+	var tck tixer.Ticket
+	err := tx.QueryRowContext(ctx, `
+		SELECT version
+		FROM tickets
+		WHERE id = ?1 AND (date_deleted IS NOT NULL) = ?2`,
+		id.String(), deleted,
+	).Scan(&tck.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return tixer.ErrTicketNotFound
+		default:
+			return err
+		}
+	}
+
+	return tck.CheckVersion(expected)
+}
+
+// insertTicket inserts a new ticket, without maintaining the total tickets counter.
+func (s *Storer) insertTicket(ctx context.Context, tx *sql.Tx, ticket tixer.Ticket) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tickets (`+insertColumns+`)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14)`,
+		append(ticketArgs(ticket), string(ticket.Status.OrDefault()), ticket.CapacityOrDefault(), time.Now().UnixNano())...,
+	)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		switch {
+		case errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return tixer.ErrTicketAlreadyExists
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storer) incrementTotal(ctx context.Context, tx *sql.Tx, delta int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE ticket_stats SET total_tickets = total_tickets + ?`, delta)
+	return err
+}
+
+// withTx runs fn inside a transaction, committing it when fn succeeds.
+func (s *Storer) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ticketArgs returns the values of the insertColumns of the ticket, but the status, the capacity
+// and the creation date, which are not replaced by ReplaceTicket.
+func ticketArgs(ticket tixer.Ticket) []any {
+	return []any{
+		ticket.ID.String(),
+		ticket.EventID,
+		ticket.Title,
+		ticket.Description,
+		ticket.Venue,
+		nullTime(ticket.StartsAt),
+		ticket.Section,
+		ticket.Row,
+		ticket.Seat,
+		ticket.Price.Amount,
+		string(ticket.Price.Currency),
+	}
+}
+
+// nullTime returns the value of an optional date, which is NULL when it is not set.
+func nullTime(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: !t.IsZero()}
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTicket(row scanner) (tixer.Ticket, error) {
+	var (
+		id          string
+		tck         tixer.Ticket
+		startsAt    sql.NullInt64
+		dateCreated int64
+		dateUpdated sql.NullInt64
+		dateDeleted sql.NullInt64
+	)
+
+	err := row.Scan(
+		&id, &tck.EventID, &tck.Title, &tck.Description, &tck.Venue, &startsAt,
+		&tck.Section, &tck.Row, &tck.Seat, &tck.Price.Amount, &tck.Price.Currency,
+		&tck.Status, &tck.Capacity, &tck.Version, &dateCreated, &dateUpdated, &dateDeleted,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return tixer.Ticket{}, tixer.ErrTicketNotFound
+		default:
+			return tixer.Ticket{}, err
+		}
+	}
+
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return tixer.Ticket{}, err
+	}
+
+	tck.ID = tixer.TicketID(uid)
+	tck.DateCreated = time.Unix(0, dateCreated).UTC()
+	if startsAt.Valid {
+		tck.StartsAt = time.Unix(0, startsAt.Int64).UTC()
+	}
+	if dateUpdated.Valid {
+		tck.DateUpdated = time.Unix(0, dateUpdated.Int64).UTC()
+	}
+	if dateDeleted.Valid {
+		tck.DateDeleted = time.Unix(0, dateDeleted.Int64).UTC()
+	}
+
+	return tck, nil
+}
@@ -0,0 +1,28 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+	"github.com/mroobert/tixer-tickets/sqlite"
+	"github.com/mroobert/tixer-tickets/tixertest"
+)
+
+func TestStorer(t *testing.T) {
+	t.Parallel()
+
+	tixertest.RunTicketServiceSuite(t, func(t *testing.T) tixer.TicketService {
+		db, err := sqlite.Open(":memory:")
+		if err != nil {
+			t.Fatalf("could not open the database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		if err := sqlite.Migrate(context.Background(), db); err != nil {
+			t.Fatalf("could not migrate the database: %v", err)
+		}
+
+		return sqlite.NewStorer(db)
+	})
+}
@@ -0,0 +1,69 @@
+package tixer
+
+import "fmt"
+
+// TicketStatus represents the stage of the lifecycle of a ticket.
+//
+// A ticket is created on sale, unless it is created as a draft, and only moves
+// through the transitions of ticketTransitions. The tickets created before
+// statuses existed have an empty status, and are on sale, see OrDefault.
+type TicketStatus string
+
+// The statuses of a ticket.
+const (
+	StatusDraft     TicketStatus = "draft"
+	StatusOnSale    TicketStatus = "on_sale"
+	StatusSoldOut   TicketStatus = "sold_out"
+	StatusCancelled TicketStatus = "cancelled"
+	StatusArchived  TicketStatus = "archived"
+)
+
+// TicketStatuses lists the statuses of a ticket.
+var TicketStatuses = []TicketStatus{StatusDraft, StatusOnSale, StatusSoldOut, StatusCancelled, StatusArchived}
+
+// ticketTransitions lists the statuses a ticket can move to from each status.
+//
+// A cancelled ticket can only be archived, and an archived ticket is final.
+var ticketTransitions = map[TicketStatus][]TicketStatus{
+	StatusDraft:     {StatusOnSale, StatusCancelled},
+	StatusOnSale:    {StatusSoldOut, StatusCancelled, StatusArchived},
+	StatusSoldOut:   {StatusOnSale, StatusCancelled, StatusArchived},
+	StatusCancelled: {StatusArchived},
+	StatusArchived:  {},
+}
+
+// OrDefault returns the status, or StatusOnSale when it is not set.
+func (s TicketStatus) OrDefault() TicketStatus {
+	if s == "" {
+		return StatusOnSale
+	}
+
+	return s
+}
+
+// Valid reports whether the status is one of TicketStatuses.
+func (s TicketStatus) Valid() bool {
+	_, ok := ticketTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a ticket can move from the status to the next one.
+func (s TicketStatus) CanTransitionTo(next TicketStatus) bool {
+	for _, to := range ticketTransitions[s.OrDefault()] {
+		if to == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTransition reports ErrInvalidTransition when the ticket
+// cannot move from its current status to the next one.
+func (t Ticket) CheckTransition(next TicketStatus) error {
+	if !t.Status.CanTransitionTo(next) {
+		return fmt.Errorf("from %s to %s: %w", t.Status.OrDefault(), next, ErrInvalidTransition)
+	}
+
+	return nil
+}
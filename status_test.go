@@ -0,0 +1,52 @@
+package tixer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+func TestTicketStatus_CanTransitionTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		from, to tixer.TicketStatus
+		want     bool
+	}{
+		{tixer.StatusDraft, tixer.StatusOnSale, true},
+		{tixer.StatusDraft, tixer.StatusCancelled, true},
+		{tixer.StatusDraft, tixer.StatusSoldOut, false},
+		{tixer.StatusDraft, tixer.StatusArchived, false},
+		{tixer.StatusOnSale, tixer.StatusSoldOut, true},
+		{tixer.StatusOnSale, tixer.StatusDraft, false},
+		{tixer.StatusSoldOut, tixer.StatusOnSale, true},
+		{tixer.StatusCancelled, tixer.StatusOnSale, false},
+		{tixer.StatusCancelled, tixer.StatusArchived, true},
+		{tixer.StatusArchived, tixer.StatusOnSale, false},
+		{tixer.StatusOnSale, tixer.StatusOnSale, false},
+		// The tickets created before statuses existed are on sale.
+		{"", tixer.StatusSoldOut, true},
+		{"", tixer.StatusOnSale, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("Got %t from %q to %q, want %t", got, tt.from, tt.to, tt.want)
+		}
+	}
+}
+
+func TestTicket_CheckTransition(t *testing.T) {
+	t.Parallel()
+
+	tck := tixer.Ticket{Status: tixer.StatusArchived}
+	if err := tck.CheckTransition(tixer.StatusOnSale); !errors.Is(err, tixer.ErrInvalidTransition) {
+		t.Errorf("Got error %v, want ErrInvalidTransition", err)
+	}
+
+	tck.Status = tixer.StatusDraft
+	if err := tck.CheckTransition(tixer.StatusOnSale); err != nil {
+		t.Errorf("Got error %v, want none", err)
+	}
+}
@@ -2,43 +2,161 @@ package tixer
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// MaxBatchSize is the maximum number of tickets created or updated by a batch operation.
+//
+// It keeps batches under the Firestore limit of 500 writes per commit,
+// with a write per ticket and one for the total tickets counter.
+const MaxBatchSize = 250
+
+// MaxDeleteBatchSize is the maximum number of tickets deleted by a batch operation.
+// Deleting a ticket takes two writes in Firestore, see MaxBatchSize.
+const MaxDeleteBatchSize = 249
+
+// AnyVersion is used as the expected version of a ticket to skip the version check.
+const AnyVersion = 0
+
+// DefaultCapacity is the capacity of the tickets created without one,
+// and of the tickets created before capacities existed: a single seat.
+const DefaultCapacity = 1
+
+// The orders a list of tickets can be sorted in.
+// The zero value of TicketSort is SortByDateCreatedDesc.
+const (
+	SortByDateCreatedDesc TicketSort = "-dateCreated"
+	SortByPrice           TicketSort = "price"
+	SortByPriceDesc       TicketSort = "-price"
+	SortByTitle           TicketSort = "title"
+)
+
+// TicketSorts lists the orders a list of tickets can be sorted in.
+var TicketSorts = []TicketSort{SortByDateCreatedDesc, SortByPrice, SortByPriceDesc, SortByTitle}
+
 type (
 
 	// TicketID represents a unique identifier for a ticket.
 	TicketID uuid.UUID
 
 	// Ticket represents an individual ticket in the system.
+	//
+	// Capacity is the number of units which can be sold, and Sold and Held
+	// count the units sold and held through a ReservationService.
+	// Version starts at 1 and is incremented on every update.
 	Ticket struct {
 		ID          TicketID
+		EventID     string
 		Title       string
-		Price       float64
+		Description string
+		Venue       string
+		StartsAt    time.Time
+		Section     string
+		Row         string
+		Seat        string
+		Price       Money
+		Status      TicketStatus
+		Capacity    int
+		Sold        int
+		Held        int
+		Version     int
 		DateCreated time.Time
 		DateUpdated time.Time
+		DateDeleted time.Time
 	}
 
+	// TicketUpdate represents a partial update of a ticket.
+	//
+	// Only the non-nil fields are applied, so a field can be
+	// explicitly set to its zero value. Version is the expected
+	// version of the ticket, see TicketService.
+	TicketUpdate struct {
+		ID          TicketID
+		EventID     *string
+		Title       *string
+		Description *string
+		Venue       *string
+		StartsAt    *time.Time
+		Section     *string
+		Row         *string
+		Seat        *string
+		Price       *Money
+		Version     int
+	}
+
+	// TicketResult represents the outcome of a single item of a batch operation.
+	// Err is nil when the item was applied.
+	TicketResult struct {
+		Ticket Ticket
+		Err    error
+	}
+
+	// TicketSort represents the order of a list of tickets.
+	//
+	// Ties are broken by ticket ID, in the same direction.
+	TicketSort string
+
+	// Cursor represents the position of a ticket in a list of tickets,
+	// with the values the list can be sorted by. Score is only set by a search.
+	Cursor struct {
+		ID          TicketID
+		Title       string
+		Price       int64
+		DateCreated time.Time
+		Score       float64
+	}
+
+	// Filter represents the criteria used to read a page of tickets.
+	//
+	// The price bounds are inclusive amounts in minor units of Currency,
+	// and the creation date bounds are exclusive.
 	Filter struct {
-		Before TicketID
-		After  TicketID
+		Before *Cursor
+		After  *Cursor
 		Limit  int
+
+		TitlePrefix   string
+		Status        TicketStatus
+		Currency      Currency
+		PriceMin      *int64
+		PriceMax      *int64
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+		Sort          TicketSort
+
+		IncludeDeleted bool
 	}
 
+	// Metadata represents the pagination information of a page of tickets.
+	// Total counts the tickets which are not deleted, regardless of the filter.
 	Metadata struct {
-		Before TicketID
-		After  TicketID
+		Before *Cursor
+		After  *Cursor
 		Total  int
 	}
 
 	// TicketService represents a service for managing tickets.
+	//
+	// The writes return ErrVersionConflict when the stored version differs from
+	// the expected one, unless it is AnyVersion. The deleted tickets are kept
+	// until PurgeTickets removes them, and are only read by ReadDeletedTicket.
+	// The batches report the outcome of every item, in the order of the input.
 	TicketService interface {
 		CreateTicket(ctx context.Context, ticket Ticket) error
+		CreateTickets(ctx context.Context, tickets []Ticket) error
 		ReadTicket(ctx context.Context, id TicketID) (Ticket, error)
-		UpdateTicket(ctx context.Context, ticket Ticket) (Ticket, error)
-		DeleteTicket(ctx context.Context, id TicketID) error
+		UpdateTicket(ctx context.Context, update TicketUpdate) (Ticket, error)
+		UpdateTickets(ctx context.Context, updates []TicketUpdate) ([]TicketResult, error)
+		ReplaceTicket(ctx context.Context, ticket Ticket) (Ticket, bool, error)
+		TransitionTicket(ctx context.Context, id TicketID, next TicketStatus, version int) (Ticket, error)
+		DeleteTicket(ctx context.Context, id TicketID, version int) error
+		DeleteTickets(ctx context.Context, ids []TicketID) ([]error, error)
+		ReadDeletedTicket(ctx context.Context, id TicketID) (Ticket, error)
+		RestoreTicket(ctx context.Context, id TicketID, version int) (Ticket, error)
+		PurgeTickets(ctx context.Context, deletedBefore time.Time) (int, error)
 		ReadTickets(ctx context.Context, filter Filter) ([]Ticket, Metadata, error)
 	}
 
@@ -50,8 +168,21 @@ type (
 )
 
 func (t Ticket) Validate(vld Validator) {
+	t.ValidateEventID(vld)
 	t.ValidateTitle(vld)
+	t.ValidateDescription(vld)
+	t.ValidateVenue(vld)
+	t.ValidateStartsAt(vld)
+	t.ValidateSection(vld)
+	t.ValidateRow(vld)
+	t.ValidateSeat(vld)
+	t.ValidateSeating(vld)
 	t.ValidatPrice(vld)
+	t.ValidateCapacity(vld)
+}
+
+func (t Ticket) ValidateEventID(vld Validator) {
+	vld.Check(len(t.EventID) <= 64, "event_id", "must not be longer than 64 characters")
 }
 
 func (t Ticket) ValidateTitle(vld Validator) {
@@ -59,8 +190,227 @@ func (t Ticket) ValidateTitle(vld Validator) {
 	vld.Check(len(t.Title) <= 50, "title", "must not be longer than 50 characters")
 }
 
+func (t Ticket) ValidateDescription(vld Validator) {
+	vld.Check(len(t.Description) <= 1000, "description", "must not be longer than 1000 characters")
+}
+
+func (t Ticket) ValidateVenue(vld Validator) {
+	vld.Check(len(t.Venue) <= 100, "venue", "must not be longer than 100 characters")
+}
+
+func (t Ticket) ValidateStartsAt(vld Validator) {
+	vld.Check(t.StartsAt.IsZero() || (t.StartsAt.Year() >= 2000 && t.StartsAt.Year() < 2100), "starts_at", "must be between the years 2000 and 2100")
+}
+
+func (t Ticket) ValidateSection(vld Validator) {
+	vld.Check(len(t.Section) <= 10, "section", "must not be longer than 10 characters")
+}
+
+func (t Ticket) ValidateRow(vld Validator) {
+	vld.Check(len(t.Row) <= 10, "row", "must not be longer than 10 characters")
+}
+
+func (t Ticket) ValidateSeat(vld Validator) {
+	vld.Check(len(t.Seat) <= 10, "seat", "must not be longer than 10 characters")
+}
+
+// ValidateSeating checks that a seat is located in a row, and a row in a section.
+func (t Ticket) ValidateSeating(vld Validator) {
+	vld.Check(t.Row == "" || t.Section != "", "section", "must be provided along with the row")
+	vld.Check(t.Seat == "" || t.Row != "", "row", "must be provided along with the seat")
+}
+
+// ValidatPrice checks the price in major units of its currency,
+// which must be supported.
 func (t Ticket) ValidatPrice(vld Validator) {
-	vld.Check(t.Price > 0 && t.Price <= 100_000, "price", "must be in the range [0, 100 000]")
+	vld.Check(t.Price.Currency.Valid(), "currency", "must be a supported ISO 4217 currency code")
+	vld.Check(t.Price.Amount > 0 && t.Price.Amount <= 100_000*t.Price.Currency.scale(), "price", "must be in the range [0, 100 000]")
+}
+
+// ValidateCapacity checks the capacity, which must be set.
+func (t Ticket) ValidateCapacity(vld Validator) {
+	vld.Check(t.Capacity >= 1 && t.Capacity <= 100_000, "capacity", "must be in the range [1, 100 000]")
+}
+
+// CapacityOrDefault returns the capacity, or DefaultCapacity when it is not set.
+func (t Ticket) CapacityOrDefault() int {
+	if t.Capacity == 0 {
+		return DefaultCapacity
+	}
+
+	return t.Capacity
+}
+
+// Available returns the number of units of the ticket which are neither sold nor held.
+//
+// The units of the expired holds which were not released yet are still counted
+// as held, so it can be lower than what can actually be held.
+func (t Ticket) Available() int {
+	return t.CapacityOrDefault() - t.Sold - t.Held
+}
+
+// Validate validates the fields provided by the update.
+//
+// The seating cannot be checked as a whole, as it depends on
+// the fields of the stored ticket which are not updated.
+func (u TicketUpdate) Validate(vld Validator) {
+	t := u.Apply(Ticket{})
+
+	if u.EventID != nil {
+		t.ValidateEventID(vld)
+	}
+	if u.Title != nil {
+		t.ValidateTitle(vld)
+	}
+	if u.Description != nil {
+		t.ValidateDescription(vld)
+	}
+	if u.Venue != nil {
+		t.ValidateVenue(vld)
+	}
+	if u.StartsAt != nil {
+		t.ValidateStartsAt(vld)
+	}
+	if u.Section != nil {
+		t.ValidateSection(vld)
+	}
+	if u.Row != nil {
+		t.ValidateRow(vld)
+	}
+	if u.Seat != nil {
+		t.ValidateSeat(vld)
+	}
+	if u.Price != nil {
+		t.ValidatPrice(vld)
+	}
+}
+
+// Apply returns a copy of the ticket with the fields provided by the update.
+func (u TicketUpdate) Apply(t Ticket) Ticket {
+	if u.EventID != nil {
+		t.EventID = *u.EventID
+	}
+	if u.Title != nil {
+		t.Title = *u.Title
+	}
+	if u.Description != nil {
+		t.Description = *u.Description
+	}
+	if u.Venue != nil {
+		t.Venue = *u.Venue
+	}
+	if u.StartsAt != nil {
+		t.StartsAt = *u.StartsAt
+	}
+	if u.Section != nil {
+		t.Section = *u.Section
+	}
+	if u.Row != nil {
+		t.Row = *u.Row
+	}
+	if u.Seat != nil {
+		t.Seat = *u.Seat
+	}
+	if u.Price != nil {
+		t.Price = *u.Price
+	}
+
+	return t
+}
+
+// Cursor returns the cursor positioned at the ticket.
+func (t Ticket) Cursor() *Cursor {
+	return &Cursor{
+		ID:          t.ID,
+		Title:       t.Title,
+		Price:       t.Price.Amount,
+		DateCreated: t.DateCreated,
+	}
+}
+
+// Ticket returns a ticket holding the values of the cursor.
+func (c Cursor) Ticket() Ticket {
+	return Ticket{
+		ID:          c.ID,
+		Title:       c.Title,
+		Price:       Money{Amount: c.Price},
+		DateCreated: c.DateCreated,
+	}
+}
+
+// PageCursors returns the cursors of the first and the last tickets of a page.
+func PageCursors(tt []Ticket) (before, after *Cursor) {
+	if len(tt) == 0 {
+		return nil, nil
+	}
+
+	return tt[0].Cursor(), tt[len(tt)-1].Cursor()
+}
+
+// CheckVersion reports ErrVersionConflict when the expected version
+// is set and differs from the current version of the ticket.
+func (t Ticket) CheckVersion(expected int) error {
+	if expected != AnyVersion && expected != t.Version {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// OrDefault returns the sort, or SortByDateCreatedDesc when it is not set.
+func (s TicketSort) OrDefault() TicketSort {
+	if s == "" {
+		return SortByDateCreatedDesc
+	}
+
+	return s
+}
+
+// Valid reports whether the sort is one of TicketSorts, or is not set.
+func (s TicketSort) Valid() bool {
+	for _, sort := range TicketSorts {
+		if s.OrDefault() == sort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Field returns the ticket field the sort orders by, and whether the order is descending.
+func (s TicketSort) Field() (string, bool) {
+	s = s.OrDefault()
+	if s[0] == '-' {
+		return string(s[1:]), true
+	}
+
+	return string(s), false
+}
+
+// Match reports whether the ticket meets the criteria of the filter.
+//
+// The cursors and the limit are not criteria, they apply to the list of tickets.
+func (f Filter) Match(t Ticket) bool {
+	switch {
+	case !f.IncludeDeleted && !t.DateDeleted.IsZero():
+		return false
+	case !strings.HasPrefix(t.Title, f.TitlePrefix):
+		return false
+	case f.Status != "" && t.Status.OrDefault() != f.Status:
+		return false
+	case f.Currency != "" && t.Price.Currency != f.Currency:
+		return false
+	case f.PriceMin != nil && t.Price.Amount < *f.PriceMin:
+		return false
+	case f.PriceMax != nil && t.Price.Amount > *f.PriceMax:
+		return false
+	case !f.CreatedAfter.IsZero() && !t.DateCreated.After(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !t.DateCreated.Before(f.CreatedBefore):
+		return false
+	}
+
+	return true
 }
 
 func NewTicketID() TicketID {
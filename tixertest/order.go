@@ -0,0 +1,814 @@
+package tixertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// OrderServiceFactory returns a new, empty OrderService, along with
+// the TicketService storing the tickets it holds.
+//
+// It is called once for every test of the suite, the same way TicketServiceFactory is.
+type OrderServiceFactory func(t *testing.T) (tixer.TicketService, tixer.OrderService)
+
+// RunOrderServiceSuite runs the conformance tests against the OrderService
+// implementations created by factory.
+func RunOrderServiceSuite(t *testing.T, factory OrderServiceFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService)
+	}{
+		{"CreateOrder_HoldsTheTicketsAndComputesTheTotal", testCreateOrderHoldsTheTickets},
+		{"CreateOrder_HoldsNothingWhenATicketCannotBeHeld", testCreateOrderHoldsNothingWhenATicketCannotBeHeld},
+		{"CreateOrder_ReturnsNotFoundForAMissingTicket", testCreateOrderReturnsNotFoundForAMissingTicket},
+		{"CreateOrder_RejectsMixedCurrencies", testCreateOrderRejectsMixedCurrencies},
+		{"CreateOrder_FailsForAnExistingID", testCreateOrderFailsForAnExistingID},
+		{"CreateOrder_NeverOversellsUnderConcurrentOrders", testCreateOrderNeverOversellsUnderConcurrentOrders},
+		{"ReadOrder_ReturnsNotFound", testReadOrderReturnsNotFound},
+		{"CancelOrder_ReleasesTheTickets", testCancelOrderReleasesTheTickets},
+		{"CancelOrder_RejectsAnOrderNotPending", testCancelOrderRejectsAnOrderNotPending},
+		{"PayOrder_SellsTheHeldTickets", testPayOrderSellsTheHeldTickets},
+		{"PayOrder_IsIdempotentForTheSamePayment", testPayOrderIsIdempotentForTheSamePayment},
+		{"PayOrder_RejectsAnExpiredOrder", testPayOrderRejectsAnExpiredOrder},
+		{"PayOrder_RejectsAWrongAmount", testPayOrderRejectsAWrongAmount},
+		{"PayOrder_RejectsAnOrderNotPending", testPayOrderRejectsAnOrderNotPending},
+		{"RefundOrder_ReleasesTheRefundedUnitsOnceConfirmed", testRefundOrderReleasesTheRefundedUnits},
+		{"RefundOrder_MovesTheOrderToRefundedOnceAllIsRefunded", testRefundOrderMovesTheOrderToRefunded},
+		{"RefundOrder_ReleasesAllTheUnitsLeftWhenRefundedInFull", testRefundOrderReleasesAllTheUnitsLeft},
+		{"RefundOrder_IsIdempotentForTheSameRefund", testRefundOrderIsIdempotentForTheSameRefund},
+		{"RefundOrder_RejectsMoreThanRefundable", testRefundOrderRejectsMoreThanRefundable},
+		{"RefundOrder_RejectsAnOrderNotPaid", testRefundOrderRejectsAnOrderNotPaid},
+		{"RefundOrder_NeverReleasesMoreThanBoughtUnderConcurrentRefunds", testRefundOrderNeverReleasesMoreThanBought},
+		{"CancelRefund_GivesTheReservationBack", testCancelRefundGivesTheReservationBack},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tickets, svc := factory(t)
+			tt.fn(t, tickets, svc)
+		})
+	}
+}
+
+func testCreateOrderHoldsTheTickets(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order := newOrder(item(concert.ID, 3), item(opera.ID, 1))
+	created, err := svc.CreateOrder(ctx, order, time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if created.Status != tixer.OrderPending || created.Version != 1 || created.Expired(time.Now()) {
+		t.Errorf("Got status %q, version %d, expiring at %v, want an unexpired pending order at version 1", created.Status, created.Version, created.DateExpires)
+	}
+	if want := eur(530); created.Total != want {
+		t.Errorf("Got total %v, want %v", created.Total, want)
+	}
+	if created.Items[0].Price != concert.Price || created.Items[1].Price != opera.Price {
+		t.Errorf("Got items %+v, want the prices of the tickets", created.Items)
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if got.Total != created.Total || len(got.Items) != 2 || got.Items[0] != created.Items[0] || !got.DateExpires.Equal(created.DateExpires) {
+		t.Errorf("Got order %+v, want %+v", got, created)
+	}
+
+	assertInventory(t, tickets, concert.ID, 0, 3)
+	assertInventory(t, tickets, opera.ID, 0, 1)
+}
+
+func testCreateOrderHoldsNothingWhenATicketCannotBeHeld(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80), Capacity: 2}
+	draft := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Ballet", Price: eur(60), Status: tixer.StatusDraft}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+	mustCreate(t, tickets, draft)
+
+	order := newOrder(item(concert.ID, 2), item(opera.ID, 3))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); !errors.Is(err, tixer.ErrSoldOut) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrSoldOut)
+	}
+	if _, err := svc.ReadOrder(ctx, order.ID); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v reading the rejected order, want %v", err, tixer.ErrOrderNotFound)
+	}
+
+	order = newOrder(item(concert.ID, 2), item(draft.ID, 1))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); !errors.Is(err, tixer.ErrTicketNotOnSale) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrTicketNotOnSale)
+	}
+
+	assertInventory(t, tickets, concert.ID, 0, 0)
+	assertInventory(t, tickets, opera.ID, 0, 0)
+}
+
+func testCreateOrderReturnsNotFoundForAMissingTicket(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	deleted := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, deleted)
+	if err := tickets.DeleteTicket(ctx, deleted.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	for _, id := range []tixer.TicketID{tixer.NewTicketID(), deleted.ID} {
+		order := newOrder(item(concert.ID, 1), item(id, 1))
+		if _, err := svc.CreateOrder(ctx, order, time.Minute); !errors.Is(err, tixer.ErrTicketNotFound) {
+			t.Errorf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+		}
+	}
+
+	assertInventory(t, tickets, concert.ID, 0, 0)
+}
+
+func testCreateOrderRejectsMixedCurrencies(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: tixer.Money{Amount: 8000, Currency: "USD"}}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order := newOrder(item(concert.ID, 1), item(opera.ID, 1))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); !errors.Is(err, tixer.ErrCurrencyMismatch) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrCurrencyMismatch)
+	}
+
+	assertInventory(t, tickets, concert.ID, 0, 0)
+	assertInventory(t, tickets, opera.ID, 0, 0)
+}
+
+func testCreateOrderFailsForAnExistingID(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: 3}
+	mustCreate(t, tickets, tck)
+
+	order := newOrder(item(tck.ID, 1))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); !errors.Is(err, tixer.ErrOrderAlreadyExists) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrOrderAlreadyExists)
+	}
+
+	assertInventory(t, tickets, tck.ID, 0, 1)
+}
+
+// testCreateOrderNeverOversellsUnderConcurrentOrders checks that orders competing
+// for the units of a ticket never hold more than its capacity.
+func testCreateOrderNeverOversellsUnderConcurrentOrders(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	const capacity, buyers = 6, 20
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: capacity}
+	mustCreate(t, tickets, tck)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		placed int
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 2)), time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				placed++
+			case errors.Is(err, tixer.ErrAlreadyHeld):
+			default:
+				t.Errorf("Got error %v, want the order placed or the ticket already held", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if placed != capacity/2 {
+		t.Errorf("Got %d orders placed, want %d", placed, capacity/2)
+	}
+	assertInventory(t, tickets, tck.ID, 0, capacity)
+}
+
+func testReadOrderReturnsNotFound(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	if _, err := svc.ReadOrder(context.Background(), tixer.NewOrderID()); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrOrderNotFound)
+	}
+}
+
+func testCancelOrderReleasesTheTickets(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order := newOrder(item(concert.ID, 3), item(opera.ID, 1))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	cancelled, err := svc.CancelOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if cancelled.Status != tixer.OrderCancelled || cancelled.Version != 2 {
+		t.Errorf("Got status %q and version %d, want %q and 2", cancelled.Status, cancelled.Version, tixer.OrderCancelled)
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if got.Status != tixer.OrderCancelled {
+		t.Errorf("Got status %q, want %q", got.Status, tixer.OrderCancelled)
+	}
+
+	assertInventory(t, tickets, concert.ID, 0, 0)
+	assertInventory(t, tickets, opera.ID, 0, 0)
+
+	// The released units can be ordered again.
+	if _, err := svc.CreateOrder(ctx, newOrder(item(concert.ID, 4)), time.Minute); err != nil {
+		t.Errorf("Got error %v, want the released units ordered again", err)
+	}
+}
+
+func testCancelOrderRejectsAnOrderNotPending(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	order := newOrder(item(tck.ID, 1))
+	if _, err := svc.CreateOrder(ctx, order, time.Minute); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if _, err := svc.CancelOrder(ctx, order.ID); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+
+	if _, err := svc.CancelOrder(ctx, order.ID); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v cancelling twice, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+	if _, err := svc.CancelOrder(ctx, tixer.NewOrderID()); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v for a missing order, want %v", err, tixer.ErrOrderNotFound)
+	}
+}
+
+func testPayOrderSellsTheHeldTickets(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(concert.ID, 4), item(opera.ID, 1)), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	payment := capturedPayment(order, "pay_1")
+	paid, err := svc.PayOrder(ctx, order.ID, payment)
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if paid.Status != tixer.OrderPaid || paid.Version != 2 || paid.PaymentID != payment.ID || paid.Captured != order.Total {
+		t.Errorf("Got order %+v, want it paid by %s for %v at version 2", paid, payment.ID, order.Total)
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if got.Status != tixer.OrderPaid || got.PaymentID != payment.ID || got.Captured != order.Total {
+		t.Errorf("Got order %+v, want it paid by %s", got, payment.ID)
+	}
+
+	assertInventory(t, tickets, concert.ID, 4, 0)
+	assertInventory(t, tickets, opera.ID, 1, 0)
+
+	tck, err := tickets.ReadTicket(ctx, concert.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if tck.Status != tixer.StatusSoldOut {
+		t.Errorf("Got status %q, want %q once all its units are sold", tck.Status, tixer.StatusSoldOut)
+	}
+}
+
+func testPayOrderIsIdempotentForTheSamePayment(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 2)), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	payment := capturedPayment(order, "pay_1")
+	if _, err := svc.PayOrder(ctx, order.ID, payment); err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+
+	again, err := svc.PayOrder(ctx, order.ID, payment)
+	if err != nil {
+		t.Fatalf("Got error %v paying twice with the same payment, want the paid order", err)
+	}
+	if again.Status != tixer.OrderPaid || again.Version != 2 {
+		t.Errorf("Got status %q and version %d, want %q and 2", again.Status, again.Version, tixer.OrderPaid)
+	}
+	assertInventory(t, tickets, tck.ID, 2, 0)
+
+	// Another payment of a paid order is rejected.
+	if _, err := svc.PayOrder(ctx, order.ID, capturedPayment(order, "pay_2")); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v for another payment, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+}
+
+func testPayOrderRejectsAnExpiredOrder(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 2)), -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	if _, err := svc.PayOrder(ctx, order.ID, capturedPayment(order, "pay_1")); !errors.Is(err, tixer.ErrOrderExpired) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrOrderExpired)
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if got.Status != tixer.OrderPending || got.PaymentID != "" {
+		t.Errorf("Got status %q and payment %q, want an unpaid pending order", got.Status, got.PaymentID)
+	}
+
+	// The expired holds are left to be released by the sweeper.
+	stored, err := tickets.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if stored.Sold != 0 {
+		t.Errorf("Got %d sold, want none", stored.Sold)
+	}
+}
+
+func testPayOrderRejectsAWrongAmount(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 2)), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	short := capturedPayment(order, "pay_1")
+	short.Captured = eur(150)
+	authorized := capturedPayment(order, "pay_2")
+	authorized.Captured = tixer.Money{Currency: "EUR"}
+	authorized.Status = tixer.PaymentAuthorized
+
+	for _, payment := range []tixer.Payment{short, authorized} {
+		if _, err := svc.PayOrder(ctx, order.ID, payment); !errors.Is(err, tixer.ErrInvalidPaymentAmount) {
+			t.Errorf("Got error %v for %s captured, want %v", err, payment.Captured, tixer.ErrInvalidPaymentAmount)
+		}
+	}
+	assertInventory(t, tickets, tck.ID, 0, 2)
+}
+
+func testPayOrderRejectsAnOrderNotPending(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 1)), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if _, err := svc.CancelOrder(ctx, order.ID); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+
+	if _, err := svc.PayOrder(ctx, order.ID, capturedPayment(order, "pay_1")); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v for a cancelled order, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+	if _, err := svc.PayOrder(ctx, tixer.NewOrderID(), capturedPayment(order, "pay_2")); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v for a missing order, want %v", err, tixer.ErrOrderNotFound)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 0)
+}
+
+func testRefundOrderReleasesTheRefundedUnits(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order := mustPayOrder(t, svc, newOrder(item(concert.ID, 4), item(opera.ID, 1)))
+	sold, err := tickets.ReadTicket(ctx, concert.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+
+	refund := tixer.OrderRefund{
+		ID:     "re_1",
+		Amount: eur(150),
+		Items:  []tixer.OrderItem{{TicketID: concert.ID, Quantity: 1, Price: eur(150)}},
+	}
+	pending, err := svc.RefundOrder(ctx, order.ID, refund)
+	if err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+	if pending.Status != tixer.OrderPaid || pending.Version != order.Version+1 || pending.Refunded() != eur(0) || pending.Refunding() != eur(150) {
+		t.Errorf("Got status %q, version %d, %v refunded and %v refunding, want %q, %d, nothing refunded and %v", pending.Status, pending.Version, pending.Refunded(), pending.Refunding(), tixer.OrderPaid, order.Version+1, eur(150))
+	}
+
+	// The units of a pending refund are still sold.
+	assertInventory(t, tickets, concert.ID, 4, 0)
+
+	refunded, err := svc.ConfirmRefund(ctx, order.ID, refund.ID, "pay_re_1")
+	if err != nil {
+		t.Fatalf("ConfirmRefund: %v", err)
+	}
+	if refunded.Status != tixer.OrderPaid || refunded.Version != order.Version+2 || refunded.Refunded() != eur(150) {
+		t.Errorf("Got status %q, version %d and %v refunded, want %q, %d and %v", refunded.Status, refunded.Version, refunded.Refunded(), tixer.OrderPaid, order.Version+2, eur(150))
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if len(got.Refunds) != 1 || got.Refunds[0].ID != refund.ID || got.Refunds[0].Status != tixer.RefundSucceeded ||
+		got.Refunds[0].PaymentRefundID != "pay_re_1" || got.Refunds[0].Amount != refund.Amount ||
+		len(got.Refunds[0].Items) != 1 || got.Refunds[0].Items[0] != refund.Items[0] || got.Refunds[0].DateCreated.IsZero() {
+		t.Errorf("Got refunds %+v, want the refund %+v succeeded in the ledger", got.Refunds, refund)
+	}
+
+	assertInventory(t, tickets, concert.ID, 3, 0)
+	assertInventory(t, tickets, opera.ID, 1, 0)
+
+	tck, err := tickets.ReadTicket(ctx, concert.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if tck.Status != tixer.StatusOnSale || tck.Version != sold.Version+1 {
+		t.Errorf("Got status %q and version %d, want %q and %d once a unit is released", tck.Status, tck.Version, tixer.StatusOnSale, sold.Version+1)
+	}
+
+	// The released unit can be ordered again.
+	if _, err := svc.CreateOrder(ctx, newOrder(item(concert.ID, 1)), time.Minute); err != nil {
+		t.Errorf("Got error %v, want the released unit ordered again", err)
+	}
+}
+
+func testRefundOrderMovesTheOrderToRefunded(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	order := mustPayOrder(t, svc, newOrder(item(tck.ID, 2)))
+
+	// An amount alone releases no units.
+	mustRefund(t, svc, order.ID, tixer.OrderRefund{ID: "re_1", Amount: eur(20)})
+	assertInventory(t, tickets, tck.ID, 2, 0)
+
+	refunded := mustRefund(t, svc, order.ID, tixer.OrderRefund{
+		ID:     "re_2",
+		Amount: eur(280),
+		Items:  []tixer.OrderItem{{TicketID: tck.ID, Quantity: 2, Price: eur(150)}},
+	})
+	if refunded.Status != tixer.OrderRefunded || refunded.Refunded() != order.Total {
+		t.Errorf("Got status %q and %v refunded, want %q and %v", refunded.Status, refunded.Refunded(), tixer.OrderRefunded, order.Total)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 0)
+
+	if _, err := svc.RefundOrder(ctx, order.ID, tixer.OrderRefund{ID: "re_3", Amount: eur(1)}); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v for a refunded order, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+}
+
+func testRefundOrderReleasesAllTheUnitsLeft(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	concert := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	opera := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, concert)
+	mustCreate(t, tickets, opera)
+
+	order := mustPayOrder(t, svc, newOrder(item(concert.ID, 3), item(opera.ID, 1)))
+	mustRefund(t, svc, order.ID, tixer.OrderRefund{
+		ID:     "re_1",
+		Amount: eur(150),
+		Items:  []tixer.OrderItem{{TicketID: concert.ID, Quantity: 1, Price: eur(150)}},
+	})
+
+	// An amount alone which refunds all that is left releases all the units left.
+	refunded := mustRefund(t, svc, order.ID, tixer.OrderRefund{ID: "re_2", Amount: eur(380)})
+	if refunded.Status != tixer.OrderRefunded || refunded.Refunded() != order.Total {
+		t.Errorf("Got status %q and %v refunded, want %q and %v", refunded.Status, refunded.Refunded(), tixer.OrderRefunded, order.Total)
+	}
+	assertInventory(t, tickets, concert.ID, 0, 0)
+	assertInventory(t, tickets, opera.ID, 0, 0)
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if len(got.Refunds) != 2 || len(got.Refunds[1].Items) != 2 {
+		t.Errorf("Got refunds %+v, want the last one to list the units left", got.Refunds)
+	}
+}
+
+func testRefundOrderIsIdempotentForTheSameRefund(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	order := mustPayOrder(t, svc, newOrder(item(tck.ID, 3)))
+	refund := tixer.OrderRefund{
+		ID:     "re_1",
+		Amount: eur(150),
+		Items:  []tixer.OrderItem{{TicketID: tck.ID, Quantity: 1, Price: eur(150)}},
+	}
+
+	first, err := svc.RefundOrder(ctx, order.ID, refund)
+	if err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+	again, err := svc.RefundOrder(ctx, order.ID, refund)
+	if err != nil {
+		t.Fatalf("Got error %v refunding twice with the same refund, want the refunded order", err)
+	}
+	if again.Version != first.Version || len(again.Refunds) != 1 {
+		t.Errorf("Got version %d and %d refunds, want %d and 1", again.Version, len(again.Refunds), first.Version)
+	}
+
+	confirmed, err := svc.ConfirmRefund(ctx, order.ID, refund.ID, "pay_re_1")
+	if err != nil {
+		t.Fatalf("ConfirmRefund: %v", err)
+	}
+	again, err = svc.ConfirmRefund(ctx, order.ID, refund.ID, "pay_re_1")
+	if err != nil {
+		t.Fatalf("Got error %v confirming twice the same refund, want the refunded order", err)
+	}
+	if again.Version != confirmed.Version {
+		t.Errorf("Got version %d, want %d", again.Version, confirmed.Version)
+	}
+	assertInventory(t, tickets, tck.ID, 2, 0)
+}
+
+func testRefundOrderRejectsMoreThanRefundable(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 4}
+	mustCreate(t, tickets, tck)
+
+	// The first refund is left pending, which reserves its amount and its units all the same.
+	order := mustPayOrder(t, svc, newOrder(item(tck.ID, 2)))
+	if _, err := svc.RefundOrder(ctx, order.ID, tixer.OrderRefund{
+		ID:     "re_1",
+		Amount: eur(150),
+		Items:  []tixer.OrderItem{{TicketID: tck.ID, Quantity: 1, Price: eur(150)}},
+	}); err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+
+	tests := []struct {
+		refund tixer.OrderRefund
+		want   error
+	}{
+		{tixer.OrderRefund{ID: "re_2", Amount: eur(151)}, tixer.ErrInvalidPaymentAmount},
+		{tixer.OrderRefund{ID: "re_3", Amount: eur(150), Items: []tixer.OrderItem{{TicketID: tck.ID, Quantity: 2, Price: eur(150)}}}, tixer.ErrInvalidQuantity},
+		{tixer.OrderRefund{ID: "re_4", Amount: eur(150), Items: []tixer.OrderItem{{TicketID: tixer.NewTicketID(), Quantity: 1}}}, tixer.ErrInvalidQuantity},
+	}
+	for _, tt := range tests {
+		if _, err := svc.RefundOrder(ctx, order.ID, tt.refund); !errors.Is(err, tt.want) {
+			t.Errorf("Got error %v for refund %s, want %v", err, tt.refund.ID, tt.want)
+		}
+	}
+
+	got, err := svc.ReadOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ReadOrder: %v", err)
+	}
+	if len(got.Refunds) != 1 {
+		t.Errorf("Got %d refunds, want only the first one", len(got.Refunds))
+	}
+	assertInventory(t, tickets, tck.ID, 2, 0)
+}
+
+func testRefundOrderRejectsAnOrderNotPaid(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	order, err := svc.CreateOrder(ctx, newOrder(item(tck.ID, 1)), time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	refund := tixer.OrderRefund{ID: "re_1", Amount: eur(150), Items: []tixer.OrderItem{{TicketID: tck.ID, Quantity: 1, Price: eur(150)}}}
+	if _, err := svc.RefundOrder(ctx, order.ID, refund); !errors.Is(err, tixer.ErrInvalidOrderTransition) {
+		t.Errorf("Got error %v for a pending order, want %v", err, tixer.ErrInvalidOrderTransition)
+	}
+	if _, err := svc.RefundOrder(ctx, tixer.NewOrderID(), refund); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v for a missing order, want %v", err, tixer.ErrOrderNotFound)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 1)
+}
+
+func testRefundOrderNeverReleasesMoreThanBought(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	const bought, refunds = 4, 10
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: bought}
+	mustCreate(t, tickets, tck)
+
+	order := mustPayOrder(t, svc, newOrder(item(tck.ID, bought)))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		refunded int
+	)
+	for i := 0; i < refunds; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id := fmt.Sprintf("re_%d", i)
+			_, err := svc.RefundOrder(ctx, order.ID, tixer.OrderRefund{
+				ID:     id,
+				Amount: eur(80),
+				Items:  []tixer.OrderItem{{TicketID: tck.ID, Quantity: 1, Price: eur(80)}},
+			})
+			if err == nil {
+				_, err = svc.ConfirmRefund(ctx, order.ID, id, "pay_"+id)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				refunded++
+			case errors.Is(err, tixer.ErrInvalidQuantity), errors.Is(err, tixer.ErrInvalidPaymentAmount), errors.Is(err, tixer.ErrInvalidOrderTransition):
+			default:
+				t.Errorf("Got error %v, want the unit refunded or nothing left to refund", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refunded != bought {
+		t.Errorf("Got %d refunds, want %d", refunded, bought)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 0)
+}
+
+func testCancelRefundGivesTheReservationBack(t *testing.T, tickets tixer.TicketService, svc tixer.OrderService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Capacity: 2}
+	mustCreate(t, tickets, tck)
+
+	order := mustPayOrder(t, svc, newOrder(item(tck.ID, 2)))
+	refund := tixer.OrderRefund{
+		ID:     "re_1",
+		Amount: eur(300),
+		Items:  []tixer.OrderItem{{TicketID: tck.ID, Quantity: 2, Price: eur(150)}},
+	}
+	if _, err := svc.RefundOrder(ctx, order.ID, refund); err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+
+	cancelled, err := svc.CancelRefund(ctx, order.ID, refund.ID)
+	if err != nil {
+		t.Fatalf("CancelRefund: %v", err)
+	}
+	if cancelled.Status != tixer.OrderPaid || len(cancelled.Refunds) != 1 || cancelled.Refunds[0].Status != tixer.RefundFailed {
+		t.Errorf("Got status %q and refunds %+v, want %q with the refund failed", cancelled.Status, cancelled.Refunds, tixer.OrderPaid)
+	}
+	again, err := svc.CancelRefund(ctx, order.ID, refund.ID)
+	if err != nil || again.Version != cancelled.Version {
+		t.Errorf("Got version %d and error %v cancelling twice, want version %d", again.Version, err, cancelled.Version)
+	}
+	assertInventory(t, tickets, tck.ID, 2, 0)
+
+	if _, err := svc.ConfirmRefund(ctx, order.ID, refund.ID, "pay_re_1"); !errors.Is(err, tixer.ErrInvalidRefundTransition) {
+		t.Errorf("Got error %v confirming a failed refund, want %v", err, tixer.ErrInvalidRefundTransition)
+	}
+	if _, err := svc.ConfirmRefund(ctx, order.ID, "re_2", "pay_re_2"); !errors.Is(err, tixer.ErrRefundNotFound) {
+		t.Errorf("Got error %v for a missing refund, want %v", err, tixer.ErrRefundNotFound)
+	}
+	if _, err := svc.CancelRefund(ctx, tixer.NewOrderID(), refund.ID); !errors.Is(err, tixer.ErrOrderNotFound) {
+		t.Errorf("Got error %v for a missing order, want %v", err, tixer.ErrOrderNotFound)
+	}
+
+	// The amount and the units of the failed refund can be refunded again.
+	refund.ID = "re_2"
+	refunded := mustRefund(t, svc, order.ID, refund)
+	if refunded.Status != tixer.OrderRefunded {
+		t.Errorf("Got status %q, want %q", refunded.Status, tixer.OrderRefunded)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 0)
+}
+
+// newOrder returns a new order of the items.
+func newOrder(items ...tixer.OrderItem) tixer.Order {
+	return tixer.Order{ID: tixer.NewOrderID(), Items: items}
+}
+
+// item returns a line item of quantity units of the ticket.
+func item(id tixer.TicketID, quantity int) tixer.OrderItem {
+	return tixer.OrderItem{TicketID: id, Quantity: quantity}
+}
+
+// capturedPayment returns a payment with the given ID which captured the total of the order.
+func capturedPayment(order tixer.Order, id tixer.PaymentID) tixer.Payment {
+	return tixer.Payment{
+		ID:       id,
+		OrderID:  order.ID,
+		Amount:   order.Total,
+		Captured: order.Total,
+		Refunded: tixer.Money{Currency: order.Total.Currency},
+		Status:   tixer.PaymentCaptured,
+	}
+}
+
+// mustPayOrder creates the order, and pays it with a payment which captured its total,
+// or fails the test. It returns the paid order.
+func mustPayOrder(t *testing.T, svc tixer.OrderService, order tixer.Order) tixer.Order {
+	t.Helper()
+
+	created, err := svc.CreateOrder(context.Background(), order, time.Minute)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	paid, err := svc.PayOrder(context.Background(), created.ID, capturedPayment(created, "pay_1"))
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+
+	return paid
+}
+
+// mustRefund adds the refund to the ledger of the order and confirms it, as refunded by
+// the payment gateway under the ID of the refund prefixed with "pay_", or fails the test.
+// It returns the refunded order.
+func mustRefund(t *testing.T, svc tixer.OrderService, id tixer.OrderID, refund tixer.OrderRefund) tixer.Order {
+	t.Helper()
+
+	if _, err := svc.RefundOrder(context.Background(), id, refund); err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+	refunded, err := svc.ConfirmRefund(context.Background(), id, refund.ID, "pay_"+refund.ID)
+	if err != nil {
+		t.Fatalf("ConfirmRefund: %v", err)
+	}
+
+	return refunded
+}
@@ -0,0 +1,355 @@
+package tixertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// ReservationServiceFactory returns a new, empty ReservationService, along with
+// the TicketService storing the tickets it holds.
+//
+// It is called once for every test of the suite, the same way TicketServiceFactory is.
+type ReservationServiceFactory func(t *testing.T) (tixer.TicketService, tixer.ReservationService)
+
+// RunReservationServiceSuite runs the conformance tests against the ReservationService
+// implementations created by factory.
+func RunReservationServiceSuite(t *testing.T, factory ReservationServiceFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService)
+	}{
+		{"Hold_HoldsTheTicketForASingleHolder", testHoldHoldsTheTicketForASingleHolder},
+		{"Hold_HoldsATicketWhoseHoldExpired", testHoldHoldsATicketWhoseHoldExpired},
+		{"Hold_RejectsATicketNotOnSale", testHoldRejectsATicketNotOnSale},
+		{"Hold_ReturnsNotFoundForAMissingTicket", testHoldReturnsNotFound},
+		{"Hold_HoldsUnitsUpToTheCapacity", testHoldHoldsUnitsUpToTheCapacity},
+		{"Hold_RejectsAnInvalidQuantity", testHoldRejectsAnInvalidQuantity},
+		{"Release_LetsAnotherHolderHoldTheTicket", testReleaseLetsAnotherHolderHoldTheTicket},
+		{"Confirm_SellsTheHeldTicket", testConfirmSellsTheHeldTicket},
+		{"Confirm_ReturnsHoldNotFoundForAnExpiredHold", testConfirmReturnsHoldNotFoundForAnExpiredHold},
+		{"Confirm_NeverOversellsUnderConcurrentBuyers", testConfirmNeverOversellsUnderConcurrentBuyers},
+		{"ReleaseExpired_RemovesOnlyTheExpiredHolds", testReleaseExpiredRemovesOnlyTheExpiredHolds},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tickets, svc := factory(t)
+			tt.fn(t, tickets, svc)
+		})
+	}
+}
+
+func testHoldHoldsTheTicketForASingleHolder(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	first, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if first.TicketID != tck.ID || first.Holder != "alice" || first.Expired(time.Now()) {
+		t.Errorf("Got hold %+v, want an unexpired hold of alice", first)
+	}
+
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 1, time.Minute); !errors.Is(err, tixer.ErrAlreadyHeld) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrAlreadyHeld)
+	}
+
+	extended, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Hour)
+	if err != nil {
+		t.Fatalf("Hold again: %v", err)
+	}
+	if !extended.DateExpires.After(first.DateExpires) {
+		t.Errorf("Got the hold expiring at %v, want it extended past %v", extended.DateExpires, first.DateExpires)
+	}
+
+	// Holding a ticket is not a change of the ticket.
+	got, err := tickets.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Version != 1 {
+		t.Errorf("Got version %d, want 1", got.Version)
+	}
+}
+
+func testHoldHoldsATicketWhoseHoldExpired(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, -time.Second); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 1, time.Minute); err != nil {
+		t.Fatalf("Got error %v, want the ticket held by bob once the hold of alice expired", err)
+	}
+
+	// The expired hold was replaced, so it cannot be confirmed.
+	if _, err := svc.Confirm(ctx, tck.ID, "alice"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrHoldNotFound)
+	}
+}
+
+func testHoldRejectsATicketNotOnSale(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Status: tixer.StatusDraft}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Minute); !errors.Is(err, tixer.ErrTicketNotOnSale) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrTicketNotOnSale)
+	}
+}
+
+func testHoldReturnsNotFound(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	if _, err := svc.Hold(ctx, tixer.NewTicketID(), "alice", 1, time.Minute); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v for a missing ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+	if err := tickets.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Minute); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v for a deleted ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testHoldHoldsUnitsUpToTheCapacity(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: 3}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 2, time.Minute); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 2, time.Minute); !errors.Is(err, tixer.ErrAlreadyHeld) {
+		t.Errorf("Got error %v holding more than the units left, want %v", err, tixer.ErrAlreadyHeld)
+	}
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 1, time.Minute); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	assertInventory(t, tickets, tck.ID, 0, 3)
+
+	sold, err := svc.Confirm(ctx, tck.ID, "alice")
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if sold.Sold != 2 || sold.Held != 1 || sold.Status != tixer.StatusOnSale {
+		t.Errorf("Got %d sold, %d held and status %q, want 2 sold, 1 held and %q", sold.Sold, sold.Held, sold.Status, tixer.StatusOnSale)
+	}
+
+	// The units sold are not available anymore, whoever holds the others.
+	if _, err := svc.Hold(ctx, tck.ID, "carol", 2, time.Minute); !errors.Is(err, tixer.ErrSoldOut) {
+		t.Errorf("Got error %v holding more than the units unsold, want %v", err, tixer.ErrSoldOut)
+	}
+	if err := svc.Release(ctx, tck.ID, "bob"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	assertInventory(t, tickets, tck.ID, 2, 0)
+}
+
+func testHoldRejectsAnInvalidQuantity(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: 3}
+	mustCreate(t, tickets, tck)
+
+	for _, quantity := range []int{0, -1} {
+		if _, err := svc.Hold(ctx, tck.ID, "alice", quantity, time.Minute); !errors.Is(err, tixer.ErrInvalidQuantity) {
+			t.Errorf("Got error %v holding %d units, want %v", err, quantity, tixer.ErrInvalidQuantity)
+		}
+	}
+}
+
+func testReleaseLetsAnotherHolderHoldTheTicket(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Minute); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if err := svc.Release(ctx, tck.ID, "alice"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := svc.Release(ctx, tck.ID, "alice"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v releasing twice, want %v", err, tixer.ErrHoldNotFound)
+	}
+
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 1, time.Minute); err != nil {
+		t.Errorf("Got error %v, want the released ticket held by bob", err)
+	}
+}
+
+func testConfirmSellsTheHeldTicket(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, time.Minute); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if _, err := svc.Confirm(ctx, tck.ID, "bob"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v confirming without a hold, want %v", err, tixer.ErrHoldNotFound)
+	}
+
+	sold, err := svc.Confirm(ctx, tck.ID, "alice")
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if sold.Status != tixer.StatusSoldOut || sold.Version != 2 {
+		t.Errorf("Got status %q and version %d, want %q and 2", sold.Status, sold.Version, tixer.StatusSoldOut)
+	}
+
+	got, err := tickets.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Status != tixer.StatusSoldOut {
+		t.Errorf("Got status %q, want %q", got.Status, tixer.StatusSoldOut)
+	}
+
+	// The hold was consumed by the confirmation.
+	if err := svc.Release(ctx, tck.ID, "alice"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v releasing a confirmed hold, want %v", err, tixer.ErrHoldNotFound)
+	}
+	if _, err := svc.Hold(ctx, tck.ID, "bob", 1, time.Minute); !errors.Is(err, tixer.ErrTicketNotOnSale) {
+		t.Errorf("Got error %v holding a sold ticket, want %v", err, tixer.ErrTicketNotOnSale)
+	}
+}
+
+func testConfirmReturnsHoldNotFoundForAnExpiredHold(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, tickets, tck)
+
+	if _, err := svc.Hold(ctx, tck.ID, "alice", 1, -time.Second); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if _, err := svc.Confirm(ctx, tck.ID, "alice"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v, want %v", err, tixer.ErrHoldNotFound)
+	}
+
+	got, err := tickets.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Status != tixer.StatusOnSale {
+		t.Errorf("Got status %q, want %q", got.Status, tixer.StatusOnSale)
+	}
+}
+
+// testConfirmNeverOversellsUnderConcurrentBuyers checks that buyers competing
+// for the units of a ticket never buy more than its capacity.
+func testConfirmNeverOversellsUnderConcurrentBuyers(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	const capacity, buyers = 5, 20
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: capacity}
+	mustCreate(t, tickets, tck)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		bought int
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(holder string) {
+			defer wg.Done()
+
+			_, err := svc.Hold(ctx, tck.ID, holder, 1, time.Minute)
+			if err == nil {
+				_, err = svc.Confirm(ctx, tck.ID, holder)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				bought++
+			case errors.Is(err, tixer.ErrAlreadyHeld), errors.Is(err, tixer.ErrSoldOut), errors.Is(err, tixer.ErrTicketNotOnSale):
+			default:
+				t.Errorf("Got error %v for %s, want the ticket bought or sold out", err, holder)
+			}
+		}(fmt.Sprintf("buyer-%d", i))
+	}
+	wg.Wait()
+
+	if bought != capacity {
+		t.Errorf("Got %d tickets bought, want %d", bought, capacity)
+	}
+
+	got, err := tickets.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Sold != capacity || got.Held != 0 || got.Status != tixer.StatusSoldOut {
+		t.Errorf("Got %d sold, %d held and status %q, want %d sold, none held and %q", got.Sold, got.Held, got.Status, capacity, tixer.StatusSoldOut)
+	}
+}
+
+func testReleaseExpiredRemovesOnlyTheExpiredHolds(t *testing.T, tickets tixer.TicketService, svc tixer.ReservationService) {
+	ctx := context.Background()
+
+	expired := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	held := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(80)}
+	mustCreate(t, tickets, expired)
+	mustCreate(t, tickets, held)
+
+	if _, err := svc.Hold(ctx, expired.ID, "alice", 1, -time.Second); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if _, err := svc.Hold(ctx, held.ID, "bob", 1, time.Hour); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+
+	released, err := svc.ReleaseExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("ReleaseExpired: %v", err)
+	}
+	if released != 1 {
+		t.Errorf("Got %d holds released, want 1", released)
+	}
+
+	if err := svc.Release(ctx, expired.ID, "alice"); !errors.Is(err, tixer.ErrHoldNotFound) {
+		t.Errorf("Got error %v, want the expired hold removed", err)
+	}
+	if err := svc.Release(ctx, held.ID, "bob"); err != nil {
+		t.Errorf("Got error %v, want the unexpired hold kept", err)
+	}
+}
+
+// assertInventory checks the units of a ticket sold and held.
+func assertInventory(t *testing.T, tickets tixer.TicketService, id tixer.TicketID, sold, held int) {
+	t.Helper()
+
+	got, err := tickets.ReadTicket(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Sold != sold || got.Held != held {
+		t.Errorf("Got %d sold and %d held, want %d sold and %d held", got.Sold, got.Held, sold, held)
+	}
+}
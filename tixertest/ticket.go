@@ -0,0 +1,1100 @@
+// Package tixertest provides conformance test suites for the tixer.TicketService,
+// tixer.ReservationService and tixer.OrderService implementations.
+//
+// Every backend is expected to run the suite from its own tests so that
+// all of them behave the same way from the perspective of the HTTP layer.
+package tixertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/tixer-tickets"
+)
+
+// TicketServiceFactory returns a new, empty TicketService.
+//
+// It is called once for every test of the suite so that the tests
+// do not share any state. Cleanup should be registered through t.Cleanup.
+type TicketServiceFactory func(t *testing.T) tixer.TicketService
+
+// RunTicketServiceSuite runs the conformance tests against the TicketService
+// implementations created by factory.
+func RunTicketServiceSuite(t *testing.T, factory TicketServiceFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, svc tixer.TicketService)
+	}{
+		{"CreateTicket_StoresTheTicket", testCreateTicketStoresTheTicket},
+		{"CreateTicket_StoresTheEventAndSeating", testCreateTicketStoresTheEventAndSeating},
+		{"CreateTicket_FailsForAnExistingID", testCreateTicketFailsForAnExistingID},
+		{"CreateTickets_StoresAllTheTickets", testCreateTicketsStoresAllTheTickets},
+		{"CreateTickets_StoresNoTicketWhenOneFails", testCreateTicketsStoresNoTicketWhenOneFails},
+		{"ReadTicket_ReturnsNotFoundForAMissingTicket", testReadTicketReturnsNotFound},
+		{"UpdateTicket_UpdatesOnlyTheProvidedFields", testUpdateTicketUpdatesOnlyTheProvidedFields},
+		{"UpdateTicket_AppliesExplicitZeroValues", testUpdateTicketAppliesExplicitZeroValues},
+		{"UpdateTicket_UpdatesAndClearsTheEventFields", testUpdateTicketUpdatesAndClearsTheEventFields},
+		{"UpdateTicket_ReturnsNotFoundForAMissingTicket", testUpdateTicketReturnsNotFound},
+		{"UpdateTicket_IncrementsTheVersion", testUpdateTicketIncrementsTheVersion},
+		{"UpdateTicket_ReturnsVersionConflictForAStaleVersion", testUpdateTicketReturnsVersionConflict},
+		{"UpdateTickets_ReportsTheOutcomeOfEveryUpdate", testUpdateTicketsReportsTheOutcomeOfEveryUpdate},
+		{"ReplaceTicket_CreatesAMissingTicket", testReplaceTicketCreatesAMissingTicket},
+		{"ReplaceTicket_ReplacesAnExistingTicket", testReplaceTicketReplacesAnExistingTicket},
+		{"ReplaceTicket_ResetsTheFieldsNotProvided", testReplaceTicketResetsTheFieldsNotProvided},
+		{"ReplaceTicket_ReturnsVersionConflictForAStaleVersion", testReplaceTicketReturnsVersionConflict},
+		{"ReplaceTicket_KeepsTheStatus", testReplaceTicketKeepsTheStatus},
+		{"ReplaceTicket_KeepsTheCapacity", testReplaceTicketKeepsTheCapacity},
+		{"TransitionTicket_MovesThroughTheLifecycle", testTransitionTicketMovesThroughTheLifecycle},
+		{"TransitionTicket_RejectsAnInvalidTransition", testTransitionTicketRejectsAnInvalidTransition},
+		{"TransitionTicket_ReturnsVersionConflictForAStaleVersion", testTransitionTicketReturnsVersionConflict},
+		{"TransitionTicket_ReturnsNotFoundForAMissingTicket", testTransitionTicketReturnsNotFound},
+		{"DeleteTicket_RemovesTheTicket", testDeleteTicketRemovesTheTicket},
+		{"DeleteTicket_ReturnsVersionConflictForAStaleVersion", testDeleteTicketReturnsVersionConflict},
+		{"DeleteTicket_ReturnsNotFoundForAMissingTicket", testDeleteTicketReturnsNotFound},
+		{"DeleteTickets_DecrementsTheTotalByTheDeletedTickets", testDeleteTicketsDecrementsTheTotalByTheDeletedTickets},
+		{"DeleteTicket_KeepsTheTicketUntilItIsRestored", testDeleteTicketKeepsTheTicketUntilItIsRestored},
+		{"DeleteTicket_LeavesTheTicketOutOfTheWrites", testDeleteTicketLeavesTheTicketOutOfTheWrites},
+		{"ReadTickets_IncludesTheDeletedTicketsOnRequest", testReadTicketsIncludesTheDeletedTickets},
+		{"RestoreTicket_ReturnsVersionConflictForAStaleVersion", testRestoreTicketReturnsVersionConflict},
+		{"RestoreTicket_ReturnsNotFoundForATicketNotDeleted", testRestoreTicketReturnsNotFound},
+		{"PurgeTickets_RemovesTheTicketsDeletedBeforeTheDate", testPurgeTicketsRemovesTheTicketsDeletedBefore},
+		{"ReadTickets_OrdersByCreationDateNewestFirst", testReadTicketsOrdersNewestFirst},
+		{"ReadTickets_PagesWithTheAfterCursor", testReadTicketsPagesWithAfter},
+		{"ReadTickets_PagesWithTheBeforeCursor", testReadTicketsPagesWithBefore},
+		{"ReadTickets_PagesFromTheCursorOfADeletedTicket", testReadTicketsPagesFromADeletedTicket},
+		{"ReadTickets_ReturnsAnAccurateTotal", testReadTicketsReturnsAnAccurateTotal},
+		{"ReadTickets_FiltersByTitlePrefixAndPrice", testReadTicketsFiltersByTitlePrefixAndPrice},
+		{"ReadTickets_FiltersByCreationDate", testReadTicketsFiltersByCreationDate},
+		{"ReadTickets_FiltersByCurrency", testReadTicketsFiltersByCurrency},
+		{"ReadTickets_FiltersByStatus", testReadTicketsFiltersByStatus},
+		{"ReadTickets_SortsAndPagesByPrice", testReadTicketsSortsAndPagesByPrice},
+		{"ReadTickets_SortsByTitle", testReadTicketsSortsByTitle},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+func testCreateTicketStoresTheTicket(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	want := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, want)
+
+	got, err := svc.ReadTicket(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.ID != want.ID || got.Title != want.Title || got.Price != want.Price {
+		t.Errorf("Got ticket %+v, want %+v", got, want)
+	}
+	if got.DateCreated.IsZero() {
+		t.Error("DateCreated should be set by the service")
+	}
+	if got.Version != 1 {
+		t.Errorf("Got version %d, want 1", got.Version)
+	}
+	if got.Status != tixer.StatusOnSale {
+		t.Errorf("Got status %q, want the default %q", got.Status, tixer.StatusOnSale)
+	}
+	if got.Capacity != tixer.DefaultCapacity {
+		t.Errorf("Got capacity %d, want the default %d", got.Capacity, tixer.DefaultCapacity)
+	}
+}
+
+func testCreateTicketStoresTheEventAndSeating(t *testing.T, svc tixer.TicketService) {
+	want := seatedTicket()
+	mustCreate(t, svc, want)
+
+	got, err := svc.ReadTicket(context.Background(), want.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	assertEventAndSeating(t, got, want)
+}
+
+func testCreateTicketFailsForAnExistingID(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	err := svc.CreateTicket(ctx, tixer.Ticket{ID: tck.ID, Title: "Opera", Price: eur(10)})
+	if !errors.Is(err, tixer.ErrTicketAlreadyExists) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketAlreadyExists)
+	}
+
+	got, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Title != tck.Title || got.Price != tck.Price {
+		t.Errorf("The existing ticket was overwritten: got %+v, want %+v", got, tck)
+	}
+}
+
+func testCreateTicketsStoresAllTheTickets(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)},
+		{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(10)},
+	}
+	if err := svc.CreateTickets(ctx, tt); err != nil {
+		t.Fatalf("CreateTickets: %v", err)
+	}
+
+	for _, want := range tt {
+		got, err := svc.ReadTicket(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("ReadTicket: %v", err)
+		}
+		if got.Title != want.Title || got.Price != want.Price || got.Version != 1 {
+			t.Errorf("Got ticket %+v, want %+v at version 1", got, want)
+		}
+	}
+	assertTotal(t, svc, 2)
+}
+
+func testCreateTicketsStoresNoTicketWhenOneFails(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	existing := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, existing)
+
+	fresh := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(10)}
+	err := svc.CreateTickets(ctx, []tixer.Ticket{fresh, existing})
+	if !errors.Is(err, tixer.ErrTicketAlreadyExists) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketAlreadyExists)
+	}
+
+	_, err = svc.ReadTicket(ctx, fresh.ID)
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v, want the batch to be rolled back", err)
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testReadTicketReturnsNotFound(t *testing.T, svc tixer.TicketService) {
+	_, err := svc.ReadTicket(context.Background(), tixer.NewTicketID())
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testUpdateTicketUpdatesOnlyTheProvidedFields(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	got, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: ptr("Opera")})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	if got.Title != "Opera" || got.Price != tck.Price {
+		t.Errorf("Got ticket %+v after updating the title", got)
+	}
+	if got.DateUpdated.IsZero() {
+		t.Error("DateUpdated should be set by the service")
+	}
+
+	got, err = svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Price: ptr(eur(99))})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	if got.Title != "Opera" || got.Price != eur(99) {
+		t.Errorf("Got ticket %+v after updating the price", got)
+	}
+
+	read, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if read.Title != got.Title || read.Price != got.Price {
+		t.Errorf("Got stored ticket %+v, want %+v", read, got)
+	}
+}
+
+func testUpdateTicketAppliesExplicitZeroValues(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	got, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: ptr(""), Price: ptr(tixer.Money{})})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	if got.Title != "" || got.Price != (tixer.Money{}) {
+		t.Errorf("Got ticket %+v, want the title and the price cleared", got)
+	}
+}
+
+func testUpdateTicketUpdatesAndClearsTheEventFields(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tck := seatedTicket()
+	mustCreate(t, svc, tck)
+
+	venue := "Royal Albert Hall"
+	var startsAt time.Time
+	got, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Venue: &venue, StartsAt: &startsAt})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+
+	want := tck
+	want.Venue = venue
+	want.StartsAt = time.Time{}
+	assertEventAndSeating(t, got, want)
+
+	got, err = svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	assertEventAndSeating(t, got, want)
+}
+
+func testUpdateTicketReturnsNotFound(t *testing.T, svc tixer.TicketService) {
+	_, err := svc.UpdateTicket(context.Background(), tixer.TicketUpdate{ID: tixer.NewTicketID(), Title: ptr("Opera")})
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testUpdateTicketIncrementsTheVersion(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	got, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: ptr("Opera"), Version: 1})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("Got version %d, want 2", got.Version)
+	}
+
+	got, err = svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Price: ptr(eur(99)), Version: tixer.AnyVersion})
+	if err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+	if got.Version != 3 {
+		t.Errorf("Got version %d, want 3", got.Version)
+	}
+}
+
+func testUpdateTicketReturnsVersionConflict(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	if _, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: ptr("Opera"), Version: 1}); err != nil {
+		t.Fatalf("UpdateTicket: %v", err)
+	}
+
+	_, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: ptr("Ballet"), Version: 1})
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrVersionConflict)
+	}
+
+	got, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Title != "Opera" || got.Version != 2 {
+		t.Errorf("The conflicting update was applied: got %+v", got)
+	}
+}
+
+func testDeleteTicketReturnsVersionConflict(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	err := svc.DeleteTicket(ctx, tck.ID, 2)
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrVersionConflict)
+	}
+
+	if _, err := svc.ReadTicket(ctx, tck.ID); err != nil {
+		t.Fatalf("The ticket should still exist: %v", err)
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testUpdateTicketsReportsTheOutcomeOfEveryUpdate(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 2)
+
+	results, err := svc.UpdateTickets(ctx, []tixer.TicketUpdate{
+		{ID: tt[0].ID, Title: ptr("Opera")},
+		{ID: tixer.NewTicketID(), Title: ptr("Ballet")},
+		{ID: tt[1].ID, Title: ptr("Ballet"), Version: 2},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTickets: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Got %d results, want 3", len(results))
+	}
+
+	if results[0].Err != nil || results[0].Ticket.Title != "Opera" || results[0].Ticket.Version != 2 {
+		t.Errorf("Got result %+v for the existing ticket", results[0])
+	}
+	if !errors.Is(results[1].Err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v for the missing ticket, want %v", results[1].Err, tixer.ErrTicketNotFound)
+	}
+	if !errors.Is(results[2].Err, tixer.ErrVersionConflict) {
+		t.Errorf("Got error %v for the stale version, want %v", results[2].Err, tixer.ErrVersionConflict)
+	}
+
+	got, err := svc.ReadTicket(ctx, tt[1].ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Title != tt[1].Title {
+		t.Errorf("The conflicting update was applied: got %+v", got)
+	}
+}
+
+func testReplaceTicketCreatesAMissingTicket(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	want := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	got, created, err := svc.ReplaceTicket(ctx, want)
+	if err != nil {
+		t.Fatalf("ReplaceTicket: %v", err)
+	}
+	if !created {
+		t.Error("ReplaceTicket should report the ticket as created")
+	}
+	if got.ID != want.ID || got.Title != want.Title || got.Price != want.Price || got.Version != 1 {
+		t.Errorf("Got ticket %+v, want %+v at version 1", got, want)
+	}
+	assertTotal(t, svc, 1)
+
+	_, _, err = svc.ReplaceTicket(ctx, tixer.Ticket{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(10), Version: 1})
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Errorf("Got error %v for an expected version of a missing ticket, want %v", err, tixer.ErrVersionConflict)
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testReplaceTicketReplacesAnExistingTicket(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	created, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+
+	got, isNew, err := svc.ReplaceTicket(ctx, tixer.Ticket{ID: tck.ID, Title: "Opera", Price: eur(10), Version: 1})
+	if err != nil {
+		t.Fatalf("ReplaceTicket: %v", err)
+	}
+	if isNew {
+		t.Error("ReplaceTicket should not report an existing ticket as created")
+	}
+	if got.Title != "Opera" || got.Price != eur(10) || got.Version != 2 {
+		t.Errorf("Got ticket %+v", got)
+	}
+	if !got.DateCreated.Equal(created.DateCreated) {
+		t.Errorf("Got creation date %s, want it preserved as %s", got.DateCreated, created.DateCreated)
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testReplaceTicketResetsTheFieldsNotProvided(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tck := seatedTicket()
+	mustCreate(t, svc, tck)
+
+	want := tixer.Ticket{ID: tck.ID, Title: "Opera", Price: eur(80)}
+	got, _, err := svc.ReplaceTicket(ctx, want)
+	if err != nil {
+		t.Fatalf("ReplaceTicket: %v", err)
+	}
+	assertEventAndSeating(t, got, want)
+
+	got, err = svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	assertEventAndSeating(t, got, want)
+}
+
+func testReplaceTicketReturnsVersionConflict(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	_, _, err := svc.ReplaceTicket(ctx, tixer.Ticket{ID: tck.ID, Title: "Opera", Price: eur(10), Version: 2})
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrVersionConflict)
+	}
+}
+
+func testReplaceTicketKeepsTheStatus(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Status: tixer.StatusDraft}
+	mustCreate(t, svc, tck)
+
+	got, _, err := svc.ReplaceTicket(ctx, tixer.Ticket{ID: tck.ID, Title: "Opera", Price: eur(10), Status: tixer.StatusArchived})
+	if err != nil {
+		t.Fatalf("ReplaceTicket: %v", err)
+	}
+	if got.Status != tixer.StatusDraft {
+		t.Errorf("Got status %q, want it kept as %q", got.Status, tixer.StatusDraft)
+	}
+}
+
+func testReplaceTicketKeepsTheCapacity(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Festival", Price: eur(80), Capacity: 500}
+	mustCreate(t, svc, tck)
+
+	got, _, err := svc.ReplaceTicket(ctx, tixer.Ticket{ID: tck.ID, Title: "Festival", Price: eur(90), Capacity: 10})
+	if err != nil {
+		t.Fatalf("ReplaceTicket: %v", err)
+	}
+	if got.Capacity != 500 {
+		t.Errorf("Got capacity %d, want it kept as 500", got.Capacity)
+	}
+}
+
+func testTransitionTicketMovesThroughTheLifecycle(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Status: tixer.StatusDraft}
+	mustCreate(t, svc, tck)
+
+	lifecycle := []tixer.TicketStatus{tixer.StatusOnSale, tixer.StatusSoldOut, tixer.StatusOnSale, tixer.StatusCancelled, tixer.StatusArchived}
+	for i, next := range lifecycle {
+		got, err := svc.TransitionTicket(ctx, tck.ID, next, i+1)
+		if err != nil {
+			t.Fatalf("TransitionTicket to %s: %v", next, err)
+		}
+		if got.Status != next || got.Version != i+2 {
+			t.Errorf("Got status %q at version %d, want %q at version %d", got.Status, got.Version, next, i+2)
+		}
+	}
+
+	got, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Status != tixer.StatusArchived {
+		t.Errorf("Got status %q, want %q", got.Status, tixer.StatusArchived)
+	}
+}
+
+func testTransitionTicketRejectsAnInvalidTransition(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Status: tixer.StatusDraft}
+	mustCreate(t, svc, tck)
+
+	_, err := svc.TransitionTicket(ctx, tck.ID, tixer.StatusSoldOut, tixer.AnyVersion)
+	if !errors.Is(err, tixer.ErrInvalidTransition) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrInvalidTransition)
+	}
+
+	got, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Status != tixer.StatusDraft || got.Version != 1 {
+		t.Errorf("The invalid transition was applied: got %+v", got)
+	}
+}
+
+func testTransitionTicketReturnsVersionConflict(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150), Status: tixer.StatusDraft}
+	mustCreate(t, svc, tck)
+
+	_, err := svc.TransitionTicket(ctx, tck.ID, tixer.StatusOnSale, 2)
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrVersionConflict)
+	}
+}
+
+func testTransitionTicketReturnsNotFound(t *testing.T, svc tixer.TicketService) {
+	_, err := svc.TransitionTicket(context.Background(), tixer.NewTicketID(), tixer.StatusOnSale, tixer.AnyVersion)
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testDeleteTicketRemovesTheTicket(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	if err := svc.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	_, err := svc.ReadTicket(ctx, tck.ID)
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testDeleteTicketReturnsNotFound(t *testing.T, svc tixer.TicketService) {
+	err := svc.DeleteTicket(context.Background(), tixer.NewTicketID(), tixer.AnyVersion)
+	if !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testDeleteTicketsDecrementsTheTotalByTheDeletedTickets(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 3)
+
+	results, err := svc.DeleteTickets(ctx, []tixer.TicketID{tt[0].ID, tixer.NewTicketID(), tt[2].ID})
+	if err != nil {
+		t.Fatalf("DeleteTickets: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Got %d results, want 3", len(results))
+	}
+	if results[0] != nil || results[2] != nil {
+		t.Errorf("Got errors %v and %v for the existing tickets", results[0], results[2])
+	}
+	if !errors.Is(results[1], tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v for the missing ticket, want %v", results[1], tixer.ErrTicketNotFound)
+	}
+
+	assertTotal(t, svc, 1)
+	if _, err := svc.ReadTicket(ctx, tt[1].ID); err != nil {
+		t.Errorf("The ticket left out of the batch should still exist: %v", err)
+	}
+}
+
+func testDeleteTicketKeepsTheTicketUntilItIsRestored(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	if err := svc.DeleteTicket(ctx, tck.ID, 1); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+	assertTotal(t, svc, 0)
+
+	deleted, err := svc.ReadDeletedTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadDeletedTicket: %v", err)
+	}
+	if deleted.Title != tck.Title || deleted.DateDeleted.IsZero() || deleted.Version != 2 {
+		t.Errorf("Got deleted ticket %+v, want the ticket at version 2 with its deletion date", deleted)
+	}
+
+	restored, err := svc.RestoreTicket(ctx, tck.ID, 2)
+	if err != nil {
+		t.Fatalf("RestoreTicket: %v", err)
+	}
+	if !restored.DateDeleted.IsZero() || restored.Version != 3 {
+		t.Errorf("Got restored ticket %+v, want the ticket at version 3 without a deletion date", restored)
+	}
+	assertTotal(t, svc, 1)
+
+	got, err := svc.ReadTicket(ctx, tck.ID)
+	if err != nil {
+		t.Fatalf("ReadTicket: %v", err)
+	}
+	if got.Title != tck.Title || got.Version != 3 {
+		t.Errorf("Got ticket %+v, want the restored ticket", got)
+	}
+	if _, err := svc.ReadDeletedTicket(ctx, tck.ID); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v reading the restored ticket as deleted, want %v", err, tixer.ErrTicketNotFound)
+	}
+}
+
+func testDeleteTicketLeavesTheTicketOutOfTheWrites(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+	if err := svc.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	title := "Opera"
+	if _, err := svc.UpdateTicket(ctx, tixer.TicketUpdate{ID: tck.ID, Title: &title}); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v updating the deleted ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+	if _, err := svc.TransitionTicket(ctx, tck.ID, tixer.StatusCancelled, tixer.AnyVersion); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v moving the deleted ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+	if err := svc.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v deleting the deleted ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+	if _, _, err := svc.ReplaceTicket(ctx, tck); !errors.Is(err, tixer.ErrTicketDeleted) {
+		t.Errorf("Got error %v replacing the deleted ticket, want %v", err, tixer.ErrTicketDeleted)
+	}
+	if err := svc.CreateTicket(ctx, tck); !errors.Is(err, tixer.ErrTicketAlreadyExists) {
+		t.Errorf("Got error %v creating the deleted ticket, want %v", err, tixer.ErrTicketAlreadyExists)
+	}
+	assertTotal(t, svc, 0)
+}
+
+func testReadTicketsIncludesTheDeletedTickets(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 4)
+
+	if _, err := svc.DeleteTickets(ctx, []tixer.TicketID{tt[1].ID, tt[2].ID}); err != nil {
+		t.Fatalf("DeleteTickets: %v", err)
+	}
+
+	got, _, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[3].ID, tt[0].ID)
+
+	page, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 3, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[3].ID, tt[2].ID, tt[1].ID)
+	if met.Total != 2 {
+		t.Errorf("Got total %d, want the 2 tickets which are not deleted", met.Total)
+	}
+
+	page, _, err = svc.ReadTickets(ctx, tixer.Filter{After: met.After, Limit: 3, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[0].ID)
+}
+
+func testRestoreTicketReturnsVersionConflict(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+	if err := svc.DeleteTicket(ctx, tck.ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	_, err := svc.RestoreTicket(ctx, tck.ID, 1)
+	if !errors.Is(err, tixer.ErrVersionConflict) {
+		t.Fatalf("Got error %v, want %v", err, tixer.ErrVersionConflict)
+	}
+}
+
+func testRestoreTicketReturnsNotFound(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(150)}
+	mustCreate(t, svc, tck)
+
+	for _, id := range []tixer.TicketID{tck.ID, tixer.NewTicketID()} {
+		_, err := svc.RestoreTicket(ctx, id, tixer.AnyVersion)
+		if !errors.Is(err, tixer.ErrTicketNotFound) {
+			t.Errorf("Got error %v for %s, want %v", err, id, tixer.ErrTicketNotFound)
+		}
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testPurgeTicketsRemovesTheTicketsDeletedBefore(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 3)
+
+	if _, err := svc.DeleteTickets(ctx, []tixer.TicketID{tt[0].ID, tt[1].ID}); err != nil {
+		t.Fatalf("DeleteTickets: %v", err)
+	}
+
+	purged, err := svc.PurgeTickets(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTickets: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("Got %d tickets purged, want none deleted an hour ago", purged)
+	}
+
+	purged, err = svc.PurgeTickets(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTickets: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Got %d tickets purged, want 2", purged)
+	}
+
+	if _, err := svc.ReadDeletedTicket(ctx, tt[0].ID); !errors.Is(err, tixer.ErrTicketNotFound) {
+		t.Errorf("Got error %v reading a purged ticket, want %v", err, tixer.ErrTicketNotFound)
+	}
+	if _, err := svc.ReadTicket(ctx, tt[2].ID); err != nil {
+		t.Errorf("The ticket which is not deleted should be kept: %v", err)
+	}
+	assertTotal(t, svc, 1)
+}
+
+func testReadTicketsOrdersNewestFirst(t *testing.T, svc tixer.TicketService) {
+	tt := mustCreateMany(t, svc, 3)
+
+	got, _, err := svc.ReadTickets(context.Background(), tixer.Filter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[2].ID, tt[1].ID, tt[0].ID)
+}
+
+func testReadTicketsPagesWithAfter(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 5)
+
+	page, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 2})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[4].ID, tt[3].ID)
+	if met.Before == nil || met.After == nil || met.Before.ID != tt[4].ID || met.After.ID != tt[3].ID {
+		t.Errorf("Got cursors before=%+v after=%+v, want before=%s after=%s", met.Before, met.After, tt[4].ID, tt[3].ID)
+	}
+
+	page, met, err = svc.ReadTickets(ctx, tixer.Filter{After: met.After, Limit: 2})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[2].ID, tt[1].ID)
+
+	page, _, err = svc.ReadTickets(ctx, tixer.Filter{After: met.After, Limit: 2})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[0].ID)
+}
+
+func testReadTicketsPagesWithBefore(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 5)
+
+	all, _, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	before := all[3].Cursor()
+
+	page, _, err := svc.ReadTickets(ctx, tixer.Filter{Before: before, Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[4].ID, tt[3].ID, tt[2].ID)
+
+	// The previous page holds the last tickets before the cursor.
+	page, _, err = svc.ReadTickets(ctx, tixer.Filter{Before: before, Limit: 2})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[3].ID, tt[2].ID)
+}
+
+func testReadTicketsPagesFromADeletedTicket(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 3)
+
+	_, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 1})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	if err := svc.DeleteTicket(ctx, tt[2].ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+
+	page, _, err := svc.ReadTickets(ctx, tixer.Filter{After: met.After, Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[1].ID, tt[0].ID)
+}
+
+func testReadTicketsReturnsAnAccurateTotal(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 3)
+	assertTotal(t, svc, 3)
+
+	_ = svc.CreateTicket(ctx, tt[0])
+	assertTotal(t, svc, 3)
+
+	if err := svc.DeleteTicket(ctx, tt[0].ID, tixer.AnyVersion); err != nil {
+		t.Fatalf("DeleteTicket: %v", err)
+	}
+	assertTotal(t, svc, 2)
+
+	_ = svc.DeleteTicket(ctx, tt[0].ID, tixer.AnyVersion)
+	assertTotal(t, svc, 2)
+}
+
+func testReadTicketsFiltersByTitlePrefixAndPrice(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Concert A", Price: eur(10)},
+		{ID: tixer.NewTicketID(), Title: "Concert B", Price: eur(20)},
+		{ID: tixer.NewTicketID(), Title: "Concert C", Price: eur(30)},
+		{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(20)},
+	}
+	for _, tck := range tt {
+		mustCreate(t, svc, tck)
+	}
+
+	got, met, err := svc.ReadTickets(ctx, tixer.Filter{TitlePrefix: "Concert", Limit: 10, Sort: tixer.SortByPrice})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[0].ID, tt[1].ID, tt[2].ID)
+	if met.Total != 4 {
+		t.Errorf("Got total %d, want all the tickets counted regardless of the filter", met.Total)
+	}
+
+	got, _, err = svc.ReadTickets(ctx, tixer.Filter{Currency: "EUR", PriceMin: ptr(int64(2000)), PriceMax: ptr(int64(2000)), Limit: 10, Sort: tixer.SortByTitle})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[1].ID, tt[3].ID)
+
+	got, _, err = svc.ReadTickets(ctx, tixer.Filter{TitlePrefix: "Concert", Currency: "EUR", PriceMin: ptr(int64(1500)), Limit: 10, Sort: tixer.SortByPriceDesc})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[2].ID, tt[1].ID)
+}
+
+func testReadTicketsFiltersByCurrency(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(20)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 2000, Currency: "USD"}},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: tixer.Money{Amount: 2000, Currency: "JPY"}},
+	}
+	for _, tck := range tt {
+		mustCreate(t, svc, tck)
+	}
+
+	got, _, err := svc.ReadTickets(ctx, tixer.Filter{Currency: "USD", Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[1].ID)
+
+	got, _, err = svc.ReadTickets(ctx, tixer.Filter{Currency: "JPY", PriceMin: ptr(int64(2000)), Limit: 10, Sort: tixer.SortByPrice})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[2].ID)
+	if got[0].Price != tt[2].Price {
+		t.Errorf("Got price %v, want %v", got[0].Price, tt[2].Price)
+	}
+}
+
+func testReadTicketsFiltersByStatus(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(20), Status: tixer.StatusDraft},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(20)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(20), Status: tixer.StatusDraft},
+	}
+	for _, tck := range tt {
+		mustCreate(t, svc, tck)
+	}
+	if _, err := svc.TransitionTicket(ctx, tt[2].ID, tixer.StatusCancelled, tixer.AnyVersion); err != nil {
+		t.Fatalf("TransitionTicket: %v", err)
+	}
+
+	for status, want := range map[tixer.TicketStatus]tixer.TicketID{
+		tixer.StatusDraft:     tt[0].ID,
+		tixer.StatusOnSale:    tt[1].ID,
+		tixer.StatusCancelled: tt[2].ID,
+	} {
+		got, _, err := svc.ReadTickets(ctx, tixer.Filter{Status: status, Limit: 10})
+		if err != nil {
+			t.Fatalf("ReadTickets: %v", err)
+		}
+		assertIDs(t, got, want)
+	}
+}
+
+func testReadTicketsFiltersByCreationDate(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+	tt := mustCreateMany(t, svc, 3)
+
+	created := make([]time.Time, 0, len(tt))
+	for _, tck := range tt {
+		got, err := svc.ReadTicket(ctx, tck.ID)
+		if err != nil {
+			t.Fatalf("ReadTicket: %v", err)
+		}
+		created = append(created, got.DateCreated)
+	}
+
+	got, _, err := svc.ReadTickets(ctx, tixer.Filter{CreatedAfter: created[0], CreatedBefore: created[2], Limit: 10})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[1].ID)
+}
+
+func testReadTicketsSortsAndPagesByPrice(t *testing.T, svc tixer.TicketService) {
+	ctx := context.Background()
+
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(30)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(10)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(40)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(20)},
+	}
+	for _, tck := range tt {
+		mustCreate(t, svc, tck)
+	}
+
+	page, met, err := svc.ReadTickets(ctx, tixer.Filter{Limit: 2, Sort: tixer.SortByPrice})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[1].ID, tt[3].ID)
+
+	page, _, err = svc.ReadTickets(ctx, tixer.Filter{After: met.After, Limit: 2, Sort: tixer.SortByPrice})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[0].ID, tt[2].ID)
+
+	page, _, err = svc.ReadTickets(ctx, tixer.Filter{Before: page[0].Cursor(), Limit: 10, Sort: tixer.SortByPrice})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, page, tt[1].ID, tt[3].ID)
+}
+
+func testReadTicketsSortsByTitle(t *testing.T, svc tixer.TicketService) {
+	tt := []tixer.Ticket{
+		{ID: tixer.NewTicketID(), Title: "Opera", Price: eur(10)},
+		{ID: tixer.NewTicketID(), Title: "Ballet", Price: eur(10)},
+		{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(10)},
+	}
+	for _, tck := range tt {
+		mustCreate(t, svc, tck)
+	}
+
+	got, _, err := svc.ReadTickets(context.Background(), tixer.Filter{Limit: 10, Sort: tixer.SortByTitle})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	assertIDs(t, got, tt[1].ID, tt[2].ID, tt[0].ID)
+}
+
+// seatedTicket returns a ticket with all its fields set.
+//
+// Its event starts on a whole second, as the stores may not keep
+// the precision of Go dates.
+func seatedTicket() tixer.Ticket {
+	return tixer.Ticket{
+		ID:          tixer.NewTicketID(),
+		EventID:     "rock-in-rio-2024",
+		Title:       "Rock in Rio",
+		Description: "Three days of rock music",
+		Venue:       "Cidade do Rock",
+		StartsAt:    time.Date(2030, time.September, 13, 18, 30, 0, 0, time.UTC),
+		Section:     "A",
+		Row:         "12",
+		Seat:        "7",
+		Price:       tixer.Money{Amount: 15000, Currency: "BRL"},
+	}
+}
+
+// assertEventAndSeating checks the fields of the ticket describing its event and seat.
+func assertEventAndSeating(t *testing.T, got, want tixer.Ticket) {
+	t.Helper()
+
+	if got.EventID != want.EventID || got.Title != want.Title || got.Description != want.Description ||
+		got.Venue != want.Venue || !got.StartsAt.Equal(want.StartsAt) ||
+		got.Section != want.Section || got.Row != want.Row || got.Seat != want.Seat ||
+		got.Price != want.Price {
+		t.Errorf("Got ticket %+v, want %+v", got, want)
+	}
+}
+
+// eur returns an amount of euros.
+func eur(units int64) tixer.Money {
+	return tixer.Money{Amount: units * 100, Currency: "EUR"}
+}
+
+// mustCreate creates the ticket or fails the test.
+func mustCreate(t *testing.T, svc tixer.TicketService, tck tixer.Ticket) {
+	t.Helper()
+
+	if err := svc.CreateTicket(context.Background(), tck); err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+}
+
+// mustCreateMany creates n tickets, oldest first.
+//
+// It waits a little between creations so that every ticket
+// gets a distinct creation date.
+func mustCreateMany(t *testing.T, svc tixer.TicketService, n int) []tixer.Ticket {
+	t.Helper()
+
+	tt := make([]tixer.Ticket, 0, n)
+	for i := 0; i < n; i++ {
+		tck := tixer.Ticket{ID: tixer.NewTicketID(), Title: "Concert", Price: eur(int64(i + 1))}
+		mustCreate(t, svc, tck)
+		tt = append(tt, tck)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return tt
+}
+
+func assertIDs(t *testing.T, got []tixer.Ticket, want ...tixer.TicketID) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("Got %d tickets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i] {
+			t.Errorf("Got ticket %s at index %d, want %s", got[i].ID, i, want[i])
+		}
+	}
+}
+
+func assertTotal(t *testing.T, svc tixer.TicketService, want int) {
+	t.Helper()
+
+	_, met, err := svc.ReadTickets(context.Background(), tixer.Filter{Limit: 1})
+	if err != nil {
+		t.Fatalf("ReadTickets: %v", err)
+	}
+	if met.Total != want {
+		t.Errorf("Got total %d, want %d", met.Total, want)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}